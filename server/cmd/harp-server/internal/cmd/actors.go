@@ -0,0 +1,68 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/oklog/run"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+
+	"github.com/elastic/harp/pkg/sdk/log"
+)
+
+// addHTTPServer registers an HTTP server actor to the goroutine group.
+func addHTTPServer(ctx context.Context, group *run.Group, name string, ln net.Listener, server *http.Server, useTLS bool, certificatePath, privateKeyPath string) {
+	group.Add(
+		func() error {
+			log.For(ctx).Info("Starting "+name+" server", zap.Stringer("address", ln.Addr()), zap.Bool("tls", useTLS))
+			if useTLS {
+				return server.ServeTLS(ln, certificatePath, privateKeyPath)
+			}
+
+			return server.Serve(ln)
+		},
+		func(e error) {
+			log.For(ctx).Info("Shutting " + name + " server down")
+
+			shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+			if err := server.Shutdown(shutdownCtx); err != nil {
+				log.For(ctx).Fatal("Server Shutdown Failed", zap.Error(err))
+			}
+		},
+	)
+}
+
+// addGRPCServer registers a gRPC server actor to the goroutine group.
+func addGRPCServer(ctx context.Context, group *run.Group, ln net.Listener, server *grpc.Server) {
+	group.Add(
+		func() error {
+			log.For(ctx).Info("Starting gRPC server", zap.Stringer("address", ln.Addr()))
+			return server.Serve(ln)
+		},
+		func(e error) {
+			log.For(ctx).Info("Shutting gRPC server down")
+			server.GracefulStop()
+		},
+	)
+}
@@ -0,0 +1,337 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gosimple/slug"
+	"github.com/spf13/cobra"
+
+	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/config"
+	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/dispatchers/core"
+	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/dispatchers/http/routes"
+	"github.com/elastic/harp-plugins/server/pkg/server/manager"
+	"github.com/elastic/harp-plugins/server/pkg/server/namespace"
+	"github.com/elastic/harp-plugins/server/pkg/server/storage"
+	"github.com/elastic/harp-plugins/server/pkg/server/storage/backends/container"
+	"github.com/elastic/harp/pkg/sdk/log"
+	"github.com/elastic/harp/pkg/sdk/tlsconfig"
+	"github.com/elastic/harp/pkg/sdk/value/encryption"
+)
+
+const (
+	checkOK      = "ok"
+	checkFailed  = "failed"
+	checkSkipped = "skipped"
+)
+
+type checkParams struct {
+	Namespaces   []string
+	Transformers []string
+	Output       string
+	Timeout      time.Duration
+}
+
+// checkResult describes a single validation result.
+type checkResult struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// namespaceReport describes validation results of a namespace.
+type namespaceReport struct {
+	Namespace string        `json:"namespace"`
+	URL       string        `json:"url,omitempty"`
+	Checks    []checkResult `json:"checks"`
+}
+
+// checkReport describes the configuration validation report.
+type checkReport struct {
+	Valid      bool              `json:"valid"`
+	Checks     []checkResult     `json:"checks"`
+	Namespaces []namespaceReport `json:"namespaces"`
+}
+
+// -----------------------------------------------------------------------------
+
+var checkCmd = func() *cobra.Command {
+	params := &checkParams{}
+
+	cmd := &cobra.Command{
+		Use:          "check",
+		Short:        "Validates settings, builds all backends and reports errors without serving",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCheck(cmd.Context(), cmd.OutOrStdout(), params)
+		},
+	}
+
+	// Parameters
+	cmd.Flags().StringSliceVarP(&params.Namespaces, "namespace", "n", nil, "namespace mapping (ns:url)")
+	cmd.Flags().StringSliceVarP(&params.Transformers, "transformer", "t", nil, "transformer mapping (keyName:key)")
+	cmd.Flags().StringVarP(&params.Output, "output", "o", "text", "report format (text, json)")
+	cmd.Flags().DurationVar(&params.Timeout, "timeout", 30*time.Second, "test read timeout per namespace")
+
+	return cmd
+}
+
+func runCheck(ctx context.Context, w io.Writer, params *checkParams) error {
+	// Check arguments
+	if params.Output != "text" && params.Output != "json" {
+		return fmt.Errorf("unsupported output format '%s'", params.Output)
+	}
+
+	// Initialize config
+	initConfig()
+	defer func() {
+		// Remove files created by secret reference resolution
+		log.CheckErrCtx(ctx, "Unable to remove resolved reference files", config.Cleanup())
+	}()
+
+	report := &checkReport{
+		Valid:      true,
+		Checks:     []checkResult{},
+		Namespaces: []namespaceReport{},
+	}
+
+	// Override config
+	report.add("namespace overrides", overrideBackendConfig(conf, params.Namespaces))
+	report.add("transformer overrides", overrideTransformerConfig(conf, params.Transformers))
+
+	// Resolve secret references
+	report.add("references", conf.ResolveReferences(ctx))
+
+	// Listener settings
+	checkTLS(report, "http", conf.HTTP.UseTLS, conf.HTTP.TLS.CertificatePath, conf.HTTP.TLS.PrivateKeyPath, conf.HTTP.TLS.CACertificatePath)
+	checkTLS(report, "vault", conf.Vault.UseTLS, conf.Vault.TLS.CertificatePath, conf.Vault.TLS.PrivateKeyPath, conf.Vault.TLS.CACertificatePath)
+	checkTLS(report, "grpc", conf.GRPC.UseTLS, conf.GRPC.TLS.CertificatePath, conf.GRPC.TLS.PrivateKeyPath, conf.GRPC.TLS.CACertificatePath)
+
+	// Dispatcher settings, validated as done at startup
+	report.add("http cache", routes.CheckCache(conf))
+	if conf.HTTP.Templates.Enabled {
+		report.add("http templates", routes.CheckTemplates(conf))
+	} else {
+		report.Checks = append(report.Checks, checkResult{Name: "http templates", Status: checkSkipped, Message: "template rendering is disabled"})
+	}
+	if conf.RateLimit.Enabled {
+		_, err := core.RateLimiter(conf)
+		report.add("rate limits", err)
+	} else {
+		report.Checks = append(report.Checks, checkResult{Name: "rate limits", Status: checkSkipped, Message: "rate limiting is disabled"})
+	}
+
+	// Transformer keys
+	transformers := map[string]string{}
+	for _, tr := range conf.Transformers {
+		name := fmt.Sprintf("transformer '%s'", tr.Name)
+		if previous, ok := transformers[slug.Make(tr.Name)]; ok {
+			report.add(name, fmt.Errorf("name collides with transformer '%s'", previous))
+			continue
+		}
+		transformers[slug.Make(tr.Name)] = tr.Name
+
+		_, err := encryption.FromKey(tr.Key)
+		report.add(name, err)
+	}
+
+	// Container keyring
+	for i, k := range conf.Keyring {
+		_, err := base64.RawURLEncoding.DecodeString(k)
+		if err != nil {
+			err = errors.New("invalid container key encoding")
+		}
+		report.add(fmt.Sprintf("keyring #%d", i), err)
+	}
+	container.SetKeyring(conf.Keyring)
+
+	// Backends
+	namespaces := map[string]string{}
+	for i := range conf.Backends {
+		b := &conf.Backends[i]
+
+		// Check namespace identifier
+		id, err := namespace.Parse(b.NS)
+		if err == nil {
+			if previous, ok := namespaces[id]; ok {
+				err = fmt.Errorf("namespace collides with '%s' as '%s'", previous, id)
+			}
+		}
+		if err != nil {
+			nr := namespaceReport{Namespace: b.NS}
+			nr.add(report, "namespace", err)
+			report.Namespaces = append(report.Namespaces, nr)
+			continue
+		}
+		namespaces[id] = b.NS
+
+		report.Namespaces = append(report.Namespaces, checkNamespace(ctx, report, b.NS, b.Probe, b.EngineURL, params.Timeout))
+	}
+
+	// Print report
+	if params.Output == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return fmt.Errorf("unable to encode report: %w", err)
+		}
+	} else {
+		report.print(w)
+	}
+
+	if !report.Valid {
+		return errors.New("invalid settings")
+	}
+
+	// No error
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+
+func checkTLS(report *checkReport, name string, enabled bool, certificatePath, privateKeyPath, caCertificatePath string) {
+	name = fmt.Sprintf("tls %s", name)
+	if !enabled {
+		report.Checks = append(report.Checks, checkResult{Name: name, Status: checkSkipped, Message: "tls is disabled"})
+		return
+	}
+
+	_, err := tlsconfig.Server(&tlsconfig.Options{
+		KeyFile:    privateKeyPath,
+		CertFile:   certificatePath,
+		CAFile:     caCertificatePath,
+		ClientAuth: tls.VerifyClientCertIfGiven,
+	})
+	report.add(name, err)
+}
+
+func checkNamespace(ctx context.Context, report *checkReport, ns, probe string, engineURL func() (string, error), timeout time.Duration) namespaceReport {
+	nr := namespaceReport{Namespace: ns}
+
+	// Build engine URL from settings
+	uri, err := engineURL()
+	if !nr.add(report, "settings", err) {
+		return nr
+	}
+	nr.URL = storage.RedactURL(uri)
+
+	// Build engine, containers are unsealed
+	var (
+		bm     = manager.Default()
+		engine storage.Engine
+	)
+	err = bm.Register(ctx, ns, uri)
+	if err == nil {
+		engine, err = bm.GetNameSpace(ctx, ns)
+	}
+	defer func() {
+		log.CheckErrCtx(ctx, "Unable to close secret backend", bm.Close())
+	}()
+	if !nr.add(report, "engine", err) {
+		return nr
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Read the probe path or the first listed secret
+	id := strings.TrimPrefix(probe, "/")
+	if probe == "" {
+		l, ok := engine.(storage.Lister)
+		if !ok {
+			nr.Checks = append(nr.Checks, checkResult{Name: "read", Status: checkSkipped, Message: "engine doesn't support listing, set a probe path"})
+			return nr
+		}
+
+		ids, err := l.List(ctx, "")
+		if !nr.add(report, "list", err) {
+			return nr
+		}
+		if len(ids) == 0 {
+			nr.Checks = append(nr.Checks, checkResult{Name: "read", Status: checkSkipped, Message: "no secret found"})
+			return nr
+		}
+		id = strings.TrimPrefix(ids[0], "/")
+	}
+
+	// Read secret
+	_, err = storage.GetWithMetadata(ctx, engine, "/"+id)
+	if nr.add(report, "read", err) {
+		nr.Checks[len(nr.Checks)-1].Message = fmt.Sprintf("'/%s' is readable", id)
+	}
+
+	return nr
+}
+
+// add records a global check result.
+func (r *checkReport) add(name string, err error) {
+	r.Checks = append(r.Checks, result(r, name, err))
+}
+
+// add records a namespace check result, it returns false on error.
+func (nr *namespaceReport) add(report *checkReport, name string, err error) bool {
+	nr.Checks = append(nr.Checks, result(report, name, err))
+	return err == nil
+}
+
+func (r *checkReport) print(w io.Writer) {
+	fmt.Fprintln(w, "Settings")
+	printResults(w, r.Checks)
+	for _, nr := range r.Namespaces {
+		if nr.URL != "" {
+			fmt.Fprintf(w, "Namespace '%s' (%s)\n", nr.Namespace, nr.URL)
+		} else {
+			fmt.Fprintf(w, "Namespace '%s'\n", nr.Namespace)
+		}
+		printResults(w, nr.Checks)
+	}
+
+	if r.Valid {
+		fmt.Fprintln(w, "Result: valid")
+	} else {
+		fmt.Fprintln(w, "Result: invalid")
+	}
+}
+
+func printResults(w io.Writer, results []checkResult) {
+	for _, c := range results {
+		if c.Message != "" {
+			fmt.Fprintf(w, "  %-9s %s: %s\n", "["+c.Status+"]", c.Name, c.Message)
+		} else {
+			fmt.Fprintf(w, "  %-9s %s\n", "["+c.Status+"]", c.Name)
+		}
+	}
+}
+
+func result(report *checkReport, name string, err error) checkResult {
+	if err != nil {
+		report.Valid = false
+		return checkResult{Name: name, Status: checkFailed, Message: err.Error()}
+	}
+
+	return checkResult{Name: name, Status: checkOK}
+}
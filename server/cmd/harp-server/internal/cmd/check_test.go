@@ -0,0 +1,200 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/config"
+	_ "github.com/elastic/harp-plugins/server/pkg/server/storage/backends/env"
+)
+
+const validCheckConfig = `
+[HTTP.Cache]
+  maxAge = "30s"
+  etagKey = "check-test-etag-key"
+
+[HTTP.Templates]
+  enabled = true
+
+  [[HTTP.Templates.Policies]]
+    template = "app/templates/*"
+    namespaces = ["app"]
+
+[RateLimit]
+  enabled = true
+
+  [[RateLimit.Namespaces]]
+    ns = "app"
+    rate = 10.0
+
+[[Backends]]
+  ns = "app"
+  url = "env://?prefix=CHECK_TEST_"
+`
+
+const invalidCheckConfig = `
+[HTTP.Cache]
+  maxAge = "30s"
+
+[HTTP.Templates]
+  enabled = true
+
+  [[HTTP.Templates.Policies]]
+    template = "app/templates/*"
+    namespaces = ["App"]
+
+[RateLimit]
+  enabled = true
+
+  [[RateLimit.Namespaces]]
+    ns = "app"
+    rate = 10.0
+
+  [[RateLimit.Namespaces]]
+    ns = "/app/"
+    rate = 20.0
+
+[[Backends]]
+  ns = "app"
+  url = "env://?prefix=CHECK_TEST_"
+`
+
+func runCheckConfig(t *testing.T, content, output string) (string, error) {
+	t.Helper()
+	t.Setenv("CHECK_TEST_DB_USER", "admin")
+
+	// Load settings from a dedicated file
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	previousFile, previousConf := cfgFile, conf
+	cfgFile, conf = path, &config.Configuration{}
+	defer func() {
+		cfgFile, conf = previousFile, previousConf
+	}()
+
+	var out bytes.Buffer
+	err := runCheck(context.Background(), &out, &checkParams{
+		Output:  output,
+		Timeout: time.Second,
+	})
+
+	return out.String(), err
+}
+
+func TestRunCheck_Text(t *testing.T) {
+	out, err := runCheckConfig(t, validCheckConfig, "text")
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, out)
+	}
+	for _, expected := range []string{
+		"[ok]      http cache\n",
+		"[ok]      http templates\n",
+		"[ok]      rate limits\n",
+		"Namespace 'app' (",
+		"[ok]      read: '/db' is readable\n",
+		"Result: valid\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in report:\n%s", expected, out)
+		}
+	}
+
+	out, err = runCheckConfig(t, invalidCheckConfig, "text")
+	if err == nil {
+		t.Fatalf("expected an error for invalid settings:\n%s", out)
+	}
+	for _, expected := range []string{
+		"[failed]  http cache: etagKey is mandatory when client cache is enabled\n",
+		"[failed]  http templates: invalid template policy 'app/templates/*' namespace",
+		"[failed]  rate limits: rate limit namespace 'app' is declared more than once",
+		"Result: invalid\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in report:\n%s", expected, out)
+		}
+	}
+}
+
+func TestRunCheck_JSON(t *testing.T) {
+	testCases := []struct {
+		name    string
+		config  string
+		valid   bool
+		results map[string]string
+	}{
+		{
+			name:   "valid",
+			config: validCheckConfig,
+			valid:  true,
+			results: map[string]string{
+				"http cache":     checkOK,
+				"http templates": checkOK,
+				"rate limits":    checkOK,
+			},
+		},
+		{
+			name:   "invalid",
+			config: invalidCheckConfig,
+			valid:  false,
+			results: map[string]string{
+				"http cache":     checkFailed,
+				"http templates": checkFailed,
+				"rate limits":    checkFailed,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := runCheckConfig(t, tc.config, "json")
+			if (err == nil) != tc.valid {
+				t.Fatalf("error = %v, valid %v", err, tc.valid)
+			}
+
+			var report checkReport
+			if err := json.Unmarshal([]byte(out), &report); err != nil {
+				t.Fatalf("unable to decode report: %v\n%s", err, out)
+			}
+			if report.Valid != tc.valid {
+				t.Errorf("report validity = %v, want %v", report.Valid, tc.valid)
+			}
+
+			statuses := map[string]string{}
+			for _, c := range report.Checks {
+				statuses[c.Name] = c.Status
+			}
+			for name, status := range tc.results {
+				if statuses[name] != status {
+					t.Errorf("check '%s' status = %q, want %q", name, statuses[name], status)
+				}
+			}
+			if len(report.Namespaces) != 1 || report.Namespaces[0].Namespace != "app" {
+				t.Errorf("unexpected namespace reports %+v", report.Namespaces)
+			}
+		})
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 
+	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/config"
 	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/dispatchers/grpc"
 	"github.com/elastic/harp/build/version"
 	"github.com/elastic/harp/pkg/sdk/log"
@@ -55,6 +56,10 @@ func runGRPCServer(cmd *cobra.Command, args []string) {
 
 	// Initialize config
 	initConfig()
+	defer func() {
+		// Remove files created by secret reference resolution
+		log.CheckErrCtx(ctx, "Unable to remove resolved reference files", config.Cleanup())
+	}()
 
 	// Starting banner
 	log.For(ctx).Info("Starting harp gRPC bundle server ...")
@@ -74,21 +79,20 @@ func runGRPCServer(cmd *cobra.Command, args []string) {
 				log.For(ctx).Fatal("Unable to parse backend mapping", zap.Error(err))
 			}
 
+			// Resolve secret references
+			if err := conf.ResolveReferences(ctx); err != nil {
+				log.For(ctx).Fatal("Unable to resolve configuration references", zap.Error(err))
+			}
+
 			server, err := grpc.New(ctx, conf)
 			if err != nil {
 				log.For(ctx).Fatal("Unable to start gRPC server", zap.Error(err))
 			}
 
-			group.Add(
-				func() error {
-					log.For(ctx).Info("Starting gRPC server", zap.Stringer("address", ln.Addr()))
-					return server.Serve(ln)
-				},
-				func(e error) {
-					log.For(ctx).Info("Shutting gRPC server down")
-					server.GracefulStop()
-				},
-			)
+			// Expose metrics
+			serveMetrics(ctx, conf, group)
+
+			addGRPCServer(ctx, group, ln, server)
 		},
 	})
 	log.CheckErrCtx(ctx, "Unable to run application", errServe)
@@ -22,7 +22,6 @@ import (
 	"fmt"
 	"net"
 	"strings"
-	"time"
 
 	"github.com/oklog/run"
 	"github.com/spf13/cobra"
@@ -30,6 +29,7 @@ import (
 
 	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/config"
 	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/dispatchers/http"
+	"github.com/elastic/harp-plugins/server/pkg/server/storage"
 	"github.com/elastic/harp/build/version"
 	"github.com/elastic/harp/pkg/sdk/log"
 	"github.com/elastic/harp/pkg/sdk/platform"
@@ -59,6 +59,10 @@ func runHTTPServer(cmd *cobra.Command, args []string) {
 
 	// Initialize config
 	initConfig()
+	defer func() {
+		// Remove files created by secret reference resolution
+		log.CheckErrCtx(ctx, "Unable to remove resolved reference files", config.Cleanup())
+	}()
 
 	// Starting banner
 	log.For(ctx).Info("Starting harp HTTP bundle server ...")
@@ -78,31 +82,20 @@ func runHTTPServer(cmd *cobra.Command, args []string) {
 				log.For(ctx).Fatal("Unable to parse namespace mapping", zap.Error(err))
 			}
 
+			// Resolve secret references
+			if err := conf.ResolveReferences(ctx); err != nil {
+				log.For(ctx).Fatal("Unable to resolve configuration references", zap.Error(err))
+			}
+
 			server, err := http.New(ctx, conf)
 			if err != nil {
 				log.For(ctx).Fatal("Unable to start HTTP server", zap.Error(err))
 			}
 
-			group.Add(
-				func() error {
-					if conf.HTTP.UseTLS {
-						log.For(ctx).Info("Starting HTTPS server", zap.Stringer("address", ln.Addr()))
-						return server.ServeTLS(ln, conf.HTTP.TLS.CertificatePath, conf.HTTP.TLS.PrivateKeyPath)
-					}
-
-					log.For(ctx).Info("Starting HTTP server", zap.Stringer("address", ln.Addr()))
-					return server.Serve(ln)
-				},
-				func(e error) {
-					log.For(ctx).Info("Shutting HTTP server down")
-
-					shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
-					defer cancel()
-					if err := server.Shutdown(shutdownCtx); err != nil {
-						log.For(ctx).Fatal("Server Shutdown Failed", zap.Error(err))
-					}
-				},
-			)
+			// Expose metrics
+			serveMetrics(ctx, conf, group)
+
+			addHTTPServer(ctx, group, "HTTP", ln, server, conf.HTTP.UseTLS, conf.HTTP.TLS.CertificatePath, conf.HTTP.TLS.PrivateKeyPath)
 		},
 	})
 	log.CheckErrCtx(ctx, "Unable to run application", err)
@@ -123,7 +116,7 @@ func overrideBackendConfig(cfg *config.Configuration, backends []string) error {
 		ns := parts[0]
 		engineURL := parts[1]
 
-		log.Bg().Debug("Backend override", zap.String("ns", ns), zap.String("url", engineURL))
+		log.Bg().Debug("Backend override", zap.String("ns", ns), zap.String("url", storage.RedactURL(engineURL)))
 
 		// Initialize backend list
 		if cfg.Backends == nil {
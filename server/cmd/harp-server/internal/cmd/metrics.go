@@ -0,0 +1,71 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/oklog/run"
+	"go.uber.org/zap"
+
+	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/config"
+	"github.com/elastic/harp-plugins/server/pkg/server/metrics"
+	"github.com/elastic/harp/pkg/sdk/log"
+)
+
+// serveMetrics registers the metrics server to the goroutine group if enabled.
+func serveMetrics(ctx context.Context, cfg *config.Configuration, group *run.Group) {
+	if !cfg.Metrics.Enabled {
+		return
+	}
+
+	// Prepare listener
+	ln, err := net.Listen(cfg.Metrics.Network, cfg.Metrics.Listen)
+	if err != nil {
+		log.For(ctx).Fatal("Unable to start metrics listener", zap.Error(err))
+	}
+
+	// Prepare server
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", metrics.Handler())
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 2 * time.Second,
+	}
+
+	group.Add(
+		func() error {
+			log.For(ctx).Info("Starting metrics server", zap.Stringer("address", ln.Addr()))
+			if err := server.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+			return nil
+		},
+		func(e error) {
+			log.For(ctx).Info("Shutting metrics server down")
+
+			shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+			log.CheckErrCtx(ctx, "Unable to shutdown metrics server", server.Shutdown(shutdownCtx))
+		},
+	)
+}
@@ -49,7 +49,7 @@ var mainCmd = func() *cobra.Command {
 	}
 
 	// Register falgs
-	cmd.Flags().StringVar(&cfgFile, "config", "", "config file")
+	cmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file")
 
 	// Register sub commands
 	cmd.AddCommand(version.Command())
@@ -58,6 +58,8 @@ var mainCmd = func() *cobra.Command {
 	cmd.AddCommand(httpCmd())
 	cmd.AddCommand(vaultCmd())
 	cmd.AddCommand(grpcCmd())
+	cmd.AddCommand(serveCmd())
+	cmd.AddCommand(checkCmd())
 
 	// Return command
 	return cmd
@@ -0,0 +1,210 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/oklog/run"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/config"
+	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/dispatchers/core"
+	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/dispatchers/grpc"
+	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/dispatchers/http"
+	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/dispatchers/vault"
+	"github.com/elastic/harp/build/version"
+	"github.com/elastic/harp/pkg/sdk/log"
+	"github.com/elastic/harp/pkg/sdk/platform"
+)
+
+const (
+	dispatcherHTTP  = "http"
+	dispatcherVault = "vault"
+	dispatcherGRPC  = "grpc"
+)
+
+type serveParams struct {
+	Dispatchers  []string
+	Namespaces   []string
+	Transformers []string
+}
+
+// -----------------------------------------------------------------------------
+
+var serveCmd = func() *cobra.Command {
+	params := &serveParams{}
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Starts selected container servers sharing the same backends",
+		Run: func(cmd *cobra.Command, args []string) {
+			runServe(cmd.Context(), params)
+		},
+	}
+
+	// Parameters
+	cmd.Flags().StringSliceVarP(&params.Dispatchers, "dispatcher", "d", []string{dispatcherHTTP, dispatcherVault, dispatcherGRPC}, "dispatchers to start (http, vault, grpc)")
+	cmd.Flags().StringSliceVarP(&params.Namespaces, "namespace", "n", nil, "namespace mapping (ns:url)")
+	cmd.Flags().StringSliceVarP(&params.Transformers, "transformer", "t", nil, "transformer mapping (keyName:key)")
+
+	return cmd
+}
+
+func runServe(ctx context.Context, params *serveParams) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Initialize config
+	initConfig()
+	defer func() {
+		// Remove files created by secret reference resolution
+		log.CheckErrCtx(ctx, "Unable to remove resolved reference files", config.Cleanup())
+	}()
+
+	// Check requirements
+	dispatchers, err := parseDispatchers(params.Dispatchers)
+	if err != nil {
+		log.For(ctx).Fatal("Invalid dispatcher selection", zap.Error(err))
+	}
+
+	// Starting banner
+	log.For(ctx).Info("Starting harp bundle servers ...", zap.Strings("dispatchers", dispatchers))
+
+	// The first dispatcher uses the platform listener
+	network, address := dispatcherListener(dispatchers[0])
+
+	// Start goroutine group
+	errServe := platform.Serve(ctx, &platform.Server{
+		Debug:           conf.Debug.Enable,
+		Name:            "harp-server",
+		Version:         version.Version,
+		Revision:        version.Commit,
+		Instrumentation: conf.Instrumentation,
+		Network:         network,
+		Address:         address,
+		Builder: func(ln net.Listener, group *run.Group) {
+			// Override config
+			if err := overrideBackendConfig(conf, params.Namespaces); err != nil {
+				log.For(ctx).Fatal("Unable to parse backend mapping", zap.Error(err))
+			}
+			if err := overrideTransformerConfig(conf, params.Transformers); err != nil {
+				log.For(ctx).Fatal("Unable to parse transformer mapping", zap.Error(err))
+			}
+
+			// Resolve secret references
+			if err := conf.ResolveReferences(ctx); err != nil {
+				log.For(ctx).Fatal("Unable to resolve configuration references", zap.Error(err))
+			}
+
+			// Build shared components, containers are loaded once
+			limiter, err := core.RateLimiter(conf)
+			if err != nil {
+				log.For(ctx).Fatal("Unable to initialize rate limits", zap.Error(err))
+			}
+			bm, err := core.BackendManager(ctx, conf, limiter)
+			if err != nil {
+				log.For(ctx).Fatal("Unable to initialize backends", zap.Error(err))
+			}
+
+			// Expose metrics
+			serveMetrics(ctx, conf, group)
+
+			for i, name := range dispatchers {
+				// Prepare additional listeners
+				listener := ln
+				if i > 0 {
+					network, address := dispatcherListener(name)
+					listener, err = net.Listen(network, address)
+					if err != nil {
+						log.For(ctx).Fatal("Unable to start listener", zap.String("dispatcher", name), zap.Error(err))
+					}
+				}
+
+				switch name {
+				case dispatcherHTTP:
+					server, err := http.NewWithBackend(ctx, conf, bm, limiter)
+					if err != nil {
+						log.For(ctx).Fatal("Unable to start HTTP server", zap.Error(err))
+					}
+					addHTTPServer(ctx, group, "HTTP", listener, server, conf.HTTP.UseTLS, conf.HTTP.TLS.CertificatePath, conf.HTTP.TLS.PrivateKeyPath)
+				case dispatcherVault:
+					server, err := vault.NewWithBackend(ctx, conf, bm, limiter)
+					if err != nil {
+						log.For(ctx).Fatal("Unable to start Vault API server", zap.Error(err))
+					}
+					addHTTPServer(ctx, group, "Vault API", listener, server, conf.Vault.UseTLS, conf.Vault.TLS.CertificatePath, conf.Vault.TLS.PrivateKeyPath)
+				case dispatcherGRPC:
+					server, err := grpc.NewWithBackend(ctx, conf, bm, limiter)
+					if err != nil {
+						log.For(ctx).Fatal("Unable to start gRPC server", zap.Error(err))
+					}
+					addGRPCServer(ctx, group, listener, server)
+				}
+			}
+		},
+	})
+	log.CheckErrCtx(ctx, "Unable to run application", errServe)
+}
+
+// -----------------------------------------------------------------------------
+
+func parseDispatchers(names []string) ([]string, error) {
+	res := []string{}
+	seen := map[string]struct{}{}
+
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		// Check dispatcher name
+		switch name {
+		case dispatcherHTTP, dispatcherVault, dispatcherGRPC:
+		default:
+			return nil, fmt.Errorf("unknown dispatcher '%s'", name)
+		}
+
+		// Ignore duplicates
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		res = append(res, name)
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("at least one dispatcher must be selected")
+	}
+
+	// No error
+	return res, nil
+}
+
+func dispatcherListener(name string) (network, address string) {
+	switch name {
+	case dispatcherVault:
+		return conf.Vault.Network, conf.Vault.Listen
+	case dispatcherGRPC:
+		return conf.GRPC.Network, conf.GRPC.Listen
+	default:
+	}
+
+	return conf.HTTP.Network, conf.HTTP.Listen
+}
@@ -20,12 +20,12 @@ package cmd
 import (
 	"context"
 	"net"
-	"time"
 
 	"github.com/oklog/run"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 
+	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/config"
 	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/dispatchers/vault"
 	"github.com/elastic/harp/build/version"
 	"github.com/elastic/harp/pkg/sdk/log"
@@ -63,6 +63,10 @@ func runVaultServer(ctx context.Context, params *vaultParams) {
 
 	// Initialize config
 	initConfig()
+	defer func() {
+		// Remove files created by secret reference resolution
+		log.CheckErrCtx(ctx, "Unable to remove resolved reference files", config.Cleanup())
+	}()
 
 	// Starting banner
 	log.For(ctx).Info("Starting harp Vault bundle server ...")
@@ -90,31 +94,20 @@ func runVaultServer(ctx context.Context, params *vaultParams) {
 				log.For(ctx).Fatal("Unable to parse transformer mapping", zap.Error(err))
 			}
 
+			// Resolve secret references
+			if err := conf.ResolveReferences(ctx); err != nil {
+				log.For(ctx).Fatal("Unable to resolve configuration references", zap.Error(err))
+			}
+
 			server, err := vault.New(ctx, conf)
 			if err != nil {
 				log.For(ctx).Fatal("Unable to start Vault API server", zap.Error(err))
 			}
 
-			group.Add(
-				func() error {
-					if conf.Vault.UseTLS {
-						log.For(ctx).Info("Starting Vault API HTTPS server", zap.Stringer("address", ln.Addr()))
-						return server.ServeTLS(ln, conf.Vault.TLS.CertificatePath, conf.Vault.TLS.PrivateKeyPath)
-					}
-
-					log.For(ctx).Info("Starting Vault API HTTP server", zap.Stringer("address", ln.Addr()))
-					return server.Serve(ln)
-				},
-				func(e error) {
-					log.For(ctx).Info("Shutting Vault API server down")
-
-					shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
-					defer cancel()
-					if err := server.Shutdown(shutdownCtx); err != nil {
-						log.For(ctx).Fatal("Server Shutdown Failed", zap.Error(err))
-					}
-				},
-			)
+			// Expose metrics
+			serveMetrics(ctx, conf, group)
+
+			addHTTPServer(ctx, group, "Vault API", ln, server, conf.Vault.UseTLS, conf.Vault.TLS.CertificatePath, conf.Vault.TLS.PrivateKeyPath)
 		},
 	})
 	log.CheckErrCtx(ctx, "Unable to run application", errServe)
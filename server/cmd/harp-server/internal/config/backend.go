@@ -0,0 +1,401 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/elastic/harp-plugins/server/pkg/server/storage"
+)
+
+// ContainerOptions represents container engine settings.
+type ContainerOptions struct {
+	ID     string `toml:"id" default:"" resolve:"value" comment:"Container identity private key (supports secret references)"`
+	Unlock string `toml:"unlock" default:"" resolve:"value" comment:"Bundle unlock pre-shared key (supports secret references)"`
+	Prefix string `toml:"prefix" default:"" comment:"Object key prefix for remote containers"`
+
+	LabelSelector string   `toml:"labelSelector" default:"" comment:"Package label selector"`
+	Annotations   []string `toml:"annotations" default:"" comment:"Package annotation matchers (<key>=<value>)"`
+	PackagePrefix string   `toml:"packagePrefix" default:"" comment:"Package name prefix"`
+	Query         string   `toml:"query" default:"" comment:"Package JMESPath query"`
+	Patch         string   `toml:"patch" default:"" resolve:"file" comment:"BundlePatch file path applied before selection"`
+
+	Conflict string `toml:"conflict" default:"" comment:"Package conflict resolution (bundle+multi - first-wins, last-wins, error)"`
+}
+
+// ContainerSource represents a merged container source settings.
+type ContainerSource struct {
+	URL string `toml:"url" default:"" comment:"Container settings url (bundle*)"`
+
+	Container ContainerOptions `toml:"Container" comment:"Container engine settings"`
+	Azure     AzureOptions     `toml:"Azure" comment:"Azure Blob Storage credentials (bundle+azblob)"`
+	GCS       GCSOptions       `toml:"GCS" comment:"Google Cloud Storage credentials (bundle+gcs)"`
+}
+
+// StorageOptions represents cloud storage engine settings.
+type StorageOptions struct {
+	Prefix         string `toml:"prefix" default:"" comment:"Object key prefix"`
+	SSECustomerKey string `toml:"sseCustomerKey" default:"" resolve:"value" comment:"Base64 encoded SSE-C key (s3, supports secret references)"`
+	SSEKMSKeyID    string `toml:"sseKMSKeyID" default:"" comment:"Expected SSE-KMS key identifier (s3)"`
+	VersionID      string `toml:"versionID" default:"" comment:"Pinned object version (s3)"`
+	RequesterPays  bool   `toml:"requesterPays" default:"false" comment:"Acknowledge requester-pays bucket charges (s3)"`
+}
+
+// KubernetesOptions represents Kubernetes engine settings.
+type KubernetesOptions struct {
+	Kubeconfig    string `toml:"kubeconfig" default:"" resolve:"file" comment:"Kubeconfig file path (in-cluster configuration if empty)"`
+	Context       string `toml:"context" default:"" comment:"Kubeconfig context"`
+	Kind          string `toml:"kind" default:"" comment:"Watched resource kind (secret, configmap, all)"`
+	LabelSelector string `toml:"labelSelector" default:"" comment:"Resource label selector"`
+	Resync        string `toml:"resync" default:"" comment:"Informer resync period"`
+	SyncTimeout   string `toml:"syncTimeout" default:"" comment:"Initial cache synchronization timeout"`
+}
+
+// DatabaseOptions represents database engine settings.
+type DatabaseOptions struct {
+	Table       string `toml:"table" default:"" comment:"Secret table name"`
+	KeyColumn   string `toml:"keyColumn" default:"" comment:"Secret path column name"`
+	ValueColumn string `toml:"valueColumn" default:"" comment:"Secret value column name"`
+	Timeout     string `toml:"timeout" default:"" comment:"Query timeout"`
+}
+
+// EnvOptions represents environment engine settings.
+type EnvOptions struct {
+	Prefix    string `toml:"prefix" default:"" comment:"Variable name prefix"`
+	Separator string `toml:"separator" default:"" comment:"Variable name path separator"`
+}
+
+// VaultOptions represents Vault engine settings.
+type VaultOptions struct {
+	Address    string `toml:"address" default:"" comment:"Vault server address (VAULT_ADDR if empty)"`
+	Namespace  string `toml:"namespace" default:"" comment:"Vault Enterprise namespace"`
+	CACert     string `toml:"caCert" default:"" resolve:"file" comment:"CA certificate path"`
+	ClientCert string `toml:"clientCert" default:"" resolve:"file" comment:"Client certificate path"`
+	ClientKey  string `toml:"clientKey" default:"" resolve:"file" comment:"Client private key path"`
+	ServerName string `toml:"serverName" default:"" comment:"TLS server name"`
+	Insecure   bool   `toml:"insecure" default:"false" comment:"Disable TLS certificate verification"`
+	Auth       string `toml:"auth" default:"" comment:"Authentication method (token, approle, kubernetes, aws - VAULT_TOKEN if empty)"`
+	AuthMount  string `toml:"authMount" default:"" comment:"Authentication method mount path"`
+	TokenFile  string `toml:"tokenFile" default:"" resolve:"file" comment:"Token file path (token)"`
+	RoleID     string `toml:"roleID" default:"" comment:"Role identifier (approle)"`
+	SecretID   string `toml:"secretID" default:"" resolve:"value" comment:"Secret identifier (approle, supports secret references)"`
+	Role       string `toml:"role" default:"" comment:"Role name (kubernetes, aws)"`
+	JWTFile    string `toml:"jwtFile" default:"" resolve:"file" comment:"Service account token path (kubernetes)"`
+	AWSRegion  string `toml:"awsRegion" default:"" comment:"STS region (aws)"`
+	AWSHeader  string `toml:"awsHeader" default:"" comment:"X-Vault-AWS-IAM-Server-ID header value (aws)"`
+	Version    int    `toml:"version" default:"0" comment:"Pinned secret version (0 for latest)"`
+	Field      string `toml:"field" default:"" comment:"Extracted secret field"`
+
+	CustomMetadata bool `toml:"customMetadata" default:"false" comment:"Read KV v2 custom metadata (requires Vault >= 1.9)"`
+}
+
+// AzureOptions represents Azure Blob Storage credential settings.
+type AzureOptions struct {
+	Auth               string `toml:"auth" default:"" comment:"Authentication method (connection-string, sas, managed-identity, workload-identity)"`
+	ConnectionString   string `toml:"connectionString" default:"" resolve:"value" comment:"Connection string (AZURE_CONNECTION_STRING if empty, supports secret references)"`
+	Account            string `toml:"account" default:"" comment:"Storage account name (sas, managed-identity, workload-identity)"`
+	EndpointSuffix     string `toml:"endpointSuffix" default:"" comment:"Storage endpoint suffix (core.windows.net if empty)"`
+	SASToken           string `toml:"sasToken" default:"" resolve:"value" comment:"Shared access signature token (sas, supports secret references)"`
+	ClientID           string `toml:"clientID" default:"" comment:"Identity client identifier (AZURE_CLIENT_ID if empty)"`
+	TenantID           string `toml:"tenantID" default:"" comment:"Identity tenant identifier (workload-identity, AZURE_TENANT_ID if empty)"`
+	FederatedTokenFile string `toml:"federatedTokenFile" default:"" resolve:"file" comment:"Federated token file path (workload-identity, AZURE_FEDERATED_TOKEN_FILE if empty)"`
+	AuthorityHost      string `toml:"authorityHost" default:"" comment:"Identity provider address (workload-identity, AZURE_AUTHORITY_HOST if empty)"`
+}
+
+// GCSOptions represents Google Cloud Storage credential settings.
+type GCSOptions struct {
+	CredentialsFile           string `toml:"credentialsFile" default:"" resolve:"file" comment:"Credentials file path (application default credentials if empty)"`
+	ImpersonateServiceAccount string `toml:"impersonateServiceAccount" default:"" comment:"Impersonated service account email"`
+	ImpersonateDelegates      string `toml:"impersonateDelegates" default:"" comment:"Comma separated impersonation delegation chain"`
+}
+
+// EncryptionOptions represents value encryption settings.
+type EncryptionOptions struct {
+	Key    string `toml:"key" default:"" resolve:"value" comment:"Value encryption key (supports secret references)"`
+	Revert bool   `toml:"revert" default:"false" comment:"Decrypt values instead of encrypting them"`
+}
+
+// PathOptions represents path mapping settings.
+type PathOptions struct {
+	Strip    string            `toml:"strip" default:"" comment:"Prefix removed from requested paths"`
+	Prefix   string            `toml:"prefix" default:"" comment:"Prefix added to requested paths"`
+	Rewrite  []string          `toml:"rewrite" default:"" comment:"Rewrite rules (<regexp>=><replacement>)"`
+	Template string            `toml:"template" default:"" comment:"Path template"`
+	Vars     map[string]string `toml:"vars" default:"" comment:"Path template variables"`
+	Allow    []string          `toml:"allow" default:"" comment:"Allowed path globs"`
+	Deny     []string          `toml:"deny" default:"" comment:"Denied path globs"`
+}
+
+// -----------------------------------------------------------------------------
+
+// EngineURL returns the storage engine URL built from the backend URL and
+// option blocks. Secret references are resolved, and the URL is validated.
+// Returned errors never contain secret values.
+func (b *Backend) EngineURL() (string, error) {
+	uri, err := b.engineURL()
+	if err != nil {
+		return "", fmt.Errorf("backend '%s': %w", b.NS, err)
+	}
+
+	// No error
+	return uri, nil
+}
+
+// -----------------------------------------------------------------------------
+
+func (b *Backend) engineURL() (string, error) {
+	// Check arguments
+	if b.URL == "" {
+		return "", errors.New("url is mandatory")
+	}
+
+	// Parse URL
+	u, err := url.Parse(b.URL)
+	if err != nil {
+		return "", fmt.Errorf("invalid url '%s'", storage.RedactURL(b.URL))
+	}
+	if u.Scheme == "" {
+		return "", errors.New("url scheme is mandatory")
+	}
+	if !storage.IsRegistered(u.Scheme) {
+		return "", fmt.Errorf("unsupported storage engine '%s'", u.Scheme)
+	}
+
+	// Collect option parameters
+	params, err := b.params(u.Scheme)
+	if err != nil {
+		return "", err
+	}
+
+	// Merge with URL parameters
+	q := u.Query()
+	for _, k := range sortedKeys(params) {
+		if _, ok := q[k]; ok {
+			return "", fmt.Errorf("'%s' is defined in both url and options", k)
+		}
+		q[k] = params[k]
+	}
+	u.RawQuery = q.Encode()
+
+	// No error
+	return u.String(), nil
+}
+
+//nolint:gocyclo // Flat option mapping
+func (b *Backend) params(scheme string) (url.Values, error) {
+	q := url.Values{}
+
+	set := func(key, value string) {
+		if value != "" {
+			q.Set(key, value)
+		}
+	}
+
+	// Engine specific options
+	if !reflect.ValueOf(b.Container).IsZero() {
+		if !strings.HasPrefix(scheme, "bundle") {
+			return nil, fmt.Errorf("container options are not supported by '%s' engine", scheme)
+		}
+		set("cid", b.Container.ID)
+		set("unlock", b.Container.Unlock)
+		set("prefix", b.Container.Prefix)
+		set("label-selector", b.Container.LabelSelector)
+		for _, a := range b.Container.Annotations {
+			q.Add("annotation", a)
+		}
+		set("package-prefix", b.Container.PackagePrefix)
+		set("query", b.Container.Query)
+		set("patch", b.Container.Patch)
+		if b.Container.Conflict != "" && scheme != "bundle+multi" {
+			return nil, fmt.Errorf("container conflict option is not supported by '%s' engine", scheme)
+		}
+		set("conflict", b.Container.Conflict)
+	}
+	if len(b.Sources) > 0 {
+		if scheme != "bundle+multi" {
+			return nil, fmt.Errorf("container sources are not supported by '%s' engine", scheme)
+		}
+		for i, s := range b.Sources {
+			// Delegate source validation
+			source := Backend{
+				URL:       s.URL,
+				Container: s.Container,
+				Azure:     s.Azure,
+				GCS:       s.GCS,
+			}
+			uri, err := source.engineURL()
+			if err != nil {
+				return nil, fmt.Errorf("container source #%d: %w", i, err)
+			}
+			if !strings.HasPrefix(uri, "bundle") || strings.HasPrefix(uri, "bundle+multi:") {
+				return nil, fmt.Errorf("container source #%d must use a bundle engine", i)
+			}
+			q.Add("b", uri)
+		}
+	}
+	if b.Storage != (StorageOptions{}) {
+		if !oneOf(scheme, "s3", "gcs", "azblob") {
+			return nil, fmt.Errorf("storage options are not supported by '%s' engine", scheme)
+		}
+		set("prefix", b.Storage.Prefix)
+
+		// S3 specific options
+		s3Options := b.Storage
+		s3Options.Prefix = ""
+		if s3Options != (StorageOptions{}) && scheme != "s3" {
+			return nil, fmt.Errorf("encryption, version and requester-pays storage options are not supported by '%s' engine", scheme)
+		}
+		set("sse-customer-key", b.Storage.SSECustomerKey)
+		set("sse-kms-key-id", b.Storage.SSEKMSKeyID)
+		set("version-id", b.Storage.VersionID)
+		if b.Storage.RequesterPays {
+			q.Set("requester-pays", "true")
+		}
+	}
+	if b.Kubernetes != (KubernetesOptions{}) {
+		if scheme != "k8s" {
+			return nil, fmt.Errorf("kubernetes options are not supported by '%s' engine", scheme)
+		}
+		set("kubeconfig", b.Kubernetes.Kubeconfig)
+		set("context", b.Kubernetes.Context)
+		set("kind", b.Kubernetes.Kind)
+		set("label-selector", b.Kubernetes.LabelSelector)
+		set("resync", b.Kubernetes.Resync)
+		set("sync-timeout", b.Kubernetes.SyncTimeout)
+	}
+	if b.Database != (DatabaseOptions{}) {
+		if !oneOf(scheme, "sqlite", "postgres") {
+			return nil, fmt.Errorf("database options are not supported by '%s' engine", scheme)
+		}
+		set("table", b.Database.Table)
+		set("key-column", b.Database.KeyColumn)
+		set("value-column", b.Database.ValueColumn)
+		set("timeout", b.Database.Timeout)
+	}
+	if b.Env != (EnvOptions{}) {
+		if !oneOf(scheme, "env", "dotenv") {
+			return nil, fmt.Errorf("env options are not supported by '%s' engine", scheme)
+		}
+		set("prefix", b.Env.Prefix)
+		set("separator", b.Env.Separator)
+	}
+
+	if b.Vault != (VaultOptions{}) {
+		if scheme != "vault" {
+			return nil, fmt.Errorf("vault options are not supported by '%s' engine", scheme)
+		}
+		set("address", b.Vault.Address)
+		set("namespace", b.Vault.Namespace)
+		set("ca-cert", b.Vault.CACert)
+		set("client-cert", b.Vault.ClientCert)
+		set("client-key", b.Vault.ClientKey)
+		set("server-name", b.Vault.ServerName)
+		if b.Vault.Insecure {
+			q.Set("insecure", "true")
+		}
+		set("auth", b.Vault.Auth)
+		set("auth-mount", b.Vault.AuthMount)
+		set("token-file", b.Vault.TokenFile)
+		set("role-id", b.Vault.RoleID)
+		set("secret-id", b.Vault.SecretID)
+		set("role", b.Vault.Role)
+		set("jwt-file", b.Vault.JWTFile)
+		set("aws-region", b.Vault.AWSRegion)
+		set("aws-header", b.Vault.AWSHeader)
+		if b.Vault.Version > 0 {
+			q.Set("version", strconv.Itoa(b.Vault.Version))
+		}
+		set("field", b.Vault.Field)
+		if b.Vault.CustomMetadata {
+			q.Set("custom-metadata", "true")
+		}
+	}
+
+	if b.Azure != (AzureOptions{}) {
+		if !oneOf(scheme, "azblob", "bundle+azblob") {
+			return nil, fmt.Errorf("azure options are not supported by '%s' engine", scheme)
+		}
+		set("auth", b.Azure.Auth)
+		set("connection-string", b.Azure.ConnectionString)
+		set("account", b.Azure.Account)
+		set("endpoint-suffix", b.Azure.EndpointSuffix)
+		set("sas-token", b.Azure.SASToken)
+		set("client-id", b.Azure.ClientID)
+		set("tenant-id", b.Azure.TenantID)
+		set("federated-token-file", b.Azure.FederatedTokenFile)
+		set("authority-host", b.Azure.AuthorityHost)
+	}
+	if b.GCS != (GCSOptions{}) {
+		if !oneOf(scheme, "gcs", "bundle+gcs") {
+			return nil, fmt.Errorf("gcs options are not supported by '%s' engine", scheme)
+		}
+		set("credentials-file", b.GCS.CredentialsFile)
+		set("impersonate-service-account", b.GCS.ImpersonateServiceAccount)
+		set("impersonate-delegates", b.GCS.ImpersonateDelegates)
+	}
+
+	// Decorator options
+	set("key", b.Encryption.Key)
+	if b.Encryption.Revert {
+		q.Set("enc_revert", "true")
+	}
+	set("path_strip", b.Path.Strip)
+	set("path_prefix", b.Path.Prefix)
+	set("path_template", b.Path.Template)
+	for _, r := range b.Path.Rewrite {
+		q.Add("path_rewrite", r)
+	}
+	for _, a := range b.Path.Allow {
+		q.Add("path_allow", a)
+	}
+	for _, d := range b.Path.Deny {
+		q.Add("path_deny", d)
+	}
+	for k, v := range b.Path.Vars {
+		q.Set("path_var_"+k, v)
+	}
+
+	// No error
+	return q, nil
+}
+
+func oneOf(value string, candidates ...string) bool {
+	for _, c := range candidates {
+		if value == c {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sortedKeys(q url.Values) []string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
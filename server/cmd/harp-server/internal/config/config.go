@@ -17,7 +17,11 @@
 
 package config
 
-import "github.com/elastic/harp/pkg/sdk/platform"
+import (
+	"time"
+
+	"github.com/elastic/harp/pkg/sdk/platform"
+)
 
 // Configuration contains qualysbeat settings
 type Configuration struct {
@@ -32,20 +36,38 @@ type Configuration struct {
 		Listen  string `toml:"listen" default:":8080" comment:"Listen address for HTTP server"`
 		UseTLS  bool   `toml:"useTLS" default:"false" comment:"Enable TLS listener"`
 		TLS     struct {
-			CertificatePath              string `toml:"certificatePath" default:"" comment:"Certificate path"`
-			PrivateKeyPath               string `toml:"privateKeyPath" default:"" comment:"Private Key path"`
-			CACertificatePath            string `toml:"caCertificatePath" default:"" comment:"CA Certificate Path"`
+			CertificatePath              string `toml:"certificatePath" default:"" resolve:"file" comment:"Certificate path"`
+			PrivateKeyPath               string `toml:"privateKeyPath" default:"" resolve:"file" comment:"Private Key path"`
+			CACertificatePath            string `toml:"caCertificatePath" default:"" resolve:"file" comment:"CA Certificate Path"`
 			ClientAuthenticationRequired bool   `toml:"clientAuthenticationRequired" default:"false" comment:"Force client authentication"`
 		} `toml:"TLS" comment:"TLS Socket settings"`
+		Templates struct {
+			Enabled          bool             `toml:"enabled" default:"false" comment:"Enable template rendering endpoint"`
+			Strict           bool             `toml:"strict" default:"true" comment:"Fail on missing keys and secrets by default"`
+			AllowInline      bool             `toml:"allowInline" default:"false" comment:"Allow templates posted in request body"`
+			InlineNamespaces []string         `toml:"inlineNamespaces" default:"" comment:"Namespaces readable by inline templates"`
+			Policies         []TemplatePolicy `toml:"Policies" default:"" comment:"Namespaces readable by stored templates"`
+		} `toml:"Templates" comment:"Template rendering settings"`
+		Cache struct {
+			MaxAge     time.Duration    `toml:"maxAge" default:"0s" comment:"Client cache max-age (no-store when 0)"`
+			Namespaces []NamespaceCache `toml:"Namespaces" default:"" comment:"Per namespace max-age overrides"`
+			ETagKey    string           `toml:"etagKey" default:"" resolve:"value" comment:"ETag hash key, mandatory when max-age is set (derived from backends when empty)"`
+		} `toml:"Cache" comment:"Conditional responses and client cache settings"`
+		Watch struct {
+			Enabled   bool          `toml:"enabled" default:"false" comment:"Enable change notifications (watch and index parameters)"`
+			Interval  time.Duration `toml:"interval" default:"5s" comment:"Backend polling interval"`
+			MaxWait   time.Duration `toml:"maxWait" default:"5m" comment:"Maximum blocking query and event stream duration"`
+			Heartbeat time.Duration `toml:"heartbeat" default:"15s" comment:"Event stream keepalive interval"`
+		} `toml:"Watch" comment:"Change notification settings"`
 	} `toml:"HTTP" comment:"###############################\n HTTP Settings \n##############################"`
 	Vault struct {
 		Network string `toml:"network" default:"tcp" comment:"Network class used for listen (tcp, tcp4, tcp6, unixsocket)"`
 		Listen  string `toml:"listen" default:":8200" comment:"Listen address for fake Vault server"`
 		UseTLS  bool   `toml:"useTLS" default:"false" comment:"Enable TLS listener"`
 		TLS     struct {
-			CertificatePath              string `toml:"certificatePath" default:"" comment:"Certificate path"`
-			PrivateKeyPath               string `toml:"privateKeyPath" default:"" comment:"Private Key path"`
-			CACertificatePath            string `toml:"caCertificatePath" default:"" comment:"CA Certificate Path"`
+			CertificatePath              string `toml:"certificatePath" default:"" resolve:"file" comment:"Certificate path"`
+			PrivateKeyPath               string `toml:"privateKeyPath" default:"" resolve:"file" comment:"Private Key path"`
+			CACertificatePath            string `toml:"caCertificatePath" default:"" resolve:"file" comment:"CA Certificate Path"`
 			ClientAuthenticationRequired bool   `toml:"clientAuthenticationRequired" default:"false" comment:"Force client authentication"`
 		} `toml:"TLS" comment:"TLS Socket settings"`
 	} `toml:"Vault" comment:"###############################\n Vault Settings \n##############################"`
@@ -54,28 +76,83 @@ type Configuration struct {
 		Listen  string `toml:"listen" default:":8085" comment:"Listen address for gRPC server"`
 		UseTLS  bool   `toml:"useTLS" default:"false" comment:"Enable TLS listener"`
 		TLS     struct {
-			CertificatePath              string `toml:"certificatePath" default:"" comment:"Certificate path"`
-			PrivateKeyPath               string `toml:"privateKeyPath" default:"" comment:"Private Key path"`
-			CACertificatePath            string `toml:"caCertificatePath" default:"" comment:"CA Certificate Path"`
+			CertificatePath              string `toml:"certificatePath" default:"" resolve:"file" comment:"Certificate path"`
+			PrivateKeyPath               string `toml:"privateKeyPath" default:"" resolve:"file" comment:"Private Key path"`
+			CACertificatePath            string `toml:"caCertificatePath" default:"" resolve:"file" comment:"CA Certificate Path"`
 			ClientAuthenticationRequired bool   `toml:"clientAuthenticationRequired" default:"false" comment:"Force client authentication"`
 		} `toml:"TLS" comment:"TLS Socket settings"`
 	} `toml:"gRPC" comment:"###############################\n gRPC Settings \n##############################"`
 
+	Metrics struct {
+		Enabled bool   `toml:"enabled" default:"false" comment:"Expose server metrics (expvar)"`
+		Network string `toml:"network" default:"tcp" comment:"Network class used for listen (tcp, tcp4, tcp6, unixsocket)"`
+		Listen  string `toml:"listen" default:"127.0.0.1:5557" comment:"Listen address for metrics server"`
+	} `toml:"Metrics" comment:"###############################\n Metrics \n##############################"`
+
+	RateLimit struct {
+		Enabled            bool                 `toml:"enabled" default:"false" comment:"Enable request rate limiting"`
+		Client             RateLimitPolicy      `toml:"Client" comment:"Per client identity (certificate common name or IP address) token bucket"`
+		Namespace          RateLimitPolicy      `toml:"Namespace" comment:"Per namespace token bucket"`
+		Namespaces         []NamespaceRateLimit `toml:"Namespaces" default:"" comment:"Per namespace token bucket overrides"`
+		MaxConcurrentCalls int                  `toml:"maxConcurrentCalls" default:"0" comment:"Maximum concurrent backend calls (0 for unlimited)"`
+		ConcurrencyWait    time.Duration        `toml:"concurrencyWait" default:"1s" comment:"Maximum wait duration for a backend call slot"`
+		IdleTimeout        time.Duration        `toml:"idleTimeout" default:"10m" comment:"Idle duration before a client bucket is released"`
+		TrustedProxies     []string             `toml:"trustedProxies" default:"" comment:"Proxy addresses or CIDR ranges allowed to give the client address using X-Forwarded-For or X-Real-IP headers"`
+	} `toml:"RateLimit" comment:"###############################\n Rate limiting \n##############################"`
+
 	Backends []Backend `toml:"Backends" default:"" comment:"###############################\n Backends \n##############################"`
 
 	Transformers []Transformer `toml:"Transformers" default:"" comment:"###############################\n Tranformers \n##############################"`
 
-	Keyring []string `toml:"Keyring" default:"" comment:"###############################\n Container Keyring \n##############################"`
+	Keyring []string `toml:"Keyring" default:"" resolve:"value" comment:"###############################\n Container Keyring \n##############################"`
 }
 
 // Backend represents backend mapping settings
 type Backend struct {
-	NS  string `toml:"ns" default:"" comment:"Backend mount namespace"`
-	URL string `toml:"url" default:"" comment:"Backend settings url"`
+	NS    string `toml:"ns" default:"" comment:"Backend mount namespace"`
+	URL   string `toml:"url" default:"" comment:"Backend settings url"`
+	Probe string `toml:"probe" default:"" comment:"Secret path read by the check command (defaults to the first listed secret)"`
+
+	Container  ContainerOptions  `toml:"Container" comment:"Container engine settings (bundle*)"`
+	Storage    StorageOptions    `toml:"Storage" comment:"Cloud storage engine settings (s3, gcs, azblob)"`
+	Kubernetes KubernetesOptions `toml:"Kubernetes" comment:"Kubernetes engine settings (k8s)"`
+	Database   DatabaseOptions   `toml:"Database" comment:"Database engine settings (sqlite, postgres)"`
+	Env        EnvOptions        `toml:"Env" comment:"Environment engine settings (env, dotenv)"`
+	Vault      VaultOptions      `toml:"Vault" comment:"Vault engine settings (vault)"`
+	Azure      AzureOptions      `toml:"Azure" comment:"Azure Blob Storage credentials (azblob, bundle+azblob)"`
+	GCS        GCSOptions        `toml:"GCS" comment:"Google Cloud Storage credentials (gcs, bundle+gcs)"`
+	Sources    []ContainerSource `toml:"Sources" comment:"Merged container sources (bundle+multi)"`
+	Encryption EncryptionOptions `toml:"Encryption" comment:"Value encryption settings"`
+	Path       PathOptions       `toml:"Path" comment:"Path mapping settings"`
 }
 
 // Transformer represents transformer mapping settings
 type Transformer struct {
 	Name string `toml:"name" default:"" comment:"Transformer key name"`
-	Key  string `toml:"key" default:"" comment:"Transformer key"`
+	Key  string `toml:"key" default:"" resolve:"value" comment:"Transformer key"`
+}
+
+// TemplatePolicy represents stored template secret access settings
+type TemplatePolicy struct {
+	Template   string   `toml:"template" default:"" comment:"Template selector glob (<namespace>/<path>)"`
+	Namespaces []string `toml:"namespaces" default:"" comment:"Namespaces readable by matching templates"`
+}
+
+// RateLimitPolicy represents token bucket settings
+type RateLimitPolicy struct {
+	Rate  float64 `toml:"rate" default:"0" comment:"Allowed requests per second (0 for unlimited)"`
+	Burst int     `toml:"burst" default:"1" comment:"Maximum burst size"`
+}
+
+// NamespaceCache represents namespace client cache override settings
+type NamespaceCache struct {
+	NS     string        `toml:"ns" default:"" comment:"Backend mount namespace"`
+	MaxAge time.Duration `toml:"maxAge" default:"0s" comment:"Client cache max-age (no-store when 0)"`
+}
+
+// NamespaceRateLimit represents namespace token bucket override settings
+type NamespaceRateLimit struct {
+	NS    string  `toml:"ns" default:"" comment:"Backend mount namespace"`
+	Rate  float64 `toml:"rate" default:"0" comment:"Allowed requests per second (0 for unlimited)"`
+	Burst int     `toml:"burst" default:"1" comment:"Maximum burst size"`
 }
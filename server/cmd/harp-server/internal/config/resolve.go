@@ -0,0 +1,216 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/elastic/harp-plugins/server/pkg/server/reference"
+)
+
+// ResolveReferences replaces secret references (env://, file://, vault://,
+// awskms://, harp-bundle://) found in configuration values by their resolved
+// value.
+//
+// Resolution is opt-in, only fields tagged with `resolve:"value"` or
+// `resolve:"file"` are resolved, `resolve:"-"` skips a field and its children. Fields tagged with `resolve:"file"` expect a
+// file path, so that resolved content is written to a private temporary file
+// and the value is replaced by its path, `file://` references are used as path
+// directly. These files are removed by Cleanup.
+//
+// This is the only secret reference resolution step, engine settings given by
+// option blocks are resolved before building the engine URL.
+func (c *Configuration) ResolveReferences(ctx context.Context) error {
+	r := &resolver{}
+	if err := r.resolveValue(ctx, "", reflect.ValueOf(c).Elem(), ""); err != nil {
+		// Don't keep partially resolved files
+		removeFiles(r.files)
+		return err
+	}
+
+	// Keep track of created files
+	resolvedFilesMu.Lock()
+	resolvedFiles = append(resolvedFiles, r.files...)
+	resolvedFilesMu.Unlock()
+
+	// No error
+	return nil
+}
+
+// Cleanup removes private files created to resolve references.
+func Cleanup() error {
+	resolvedFilesMu.Lock()
+	defer resolvedFilesMu.Unlock()
+
+	err := removeFiles(resolvedFiles)
+	resolvedFiles = nil
+	return err
+}
+
+// -----------------------------------------------------------------------------
+
+var (
+	resolvedFilesMu sync.Mutex
+	resolvedFiles   []string
+)
+
+// Field resolution modes
+const (
+	resolveNone  = "-"
+	resolveValue = "value"
+	resolveFile  = "file"
+)
+
+type resolver struct {
+	files []string
+}
+
+func (r *resolver) resolveValue(ctx context.Context, name string, v reflect.Value, mode string) error {
+	if mode == resolveNone {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return r.resolveValue(ctx, name, v.Elem(), mode)
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+
+			if err := r.resolveValue(ctx, joinName(name, f.Name), v.Field(i), f.Tag.Get("resolve")); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := r.resolveValue(ctx, fmt.Sprintf("%s[%d]", name, i), v.Index(i), mode); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if mode == "" || v.Type().Elem().Kind() != reflect.String {
+			return nil
+		}
+		for _, k := range v.MapKeys() {
+			out, err := r.resolveString(ctx, fmt.Sprintf("%s[%v]", name, k), v.MapIndex(k).String(), mode)
+			if err != nil {
+				return err
+			}
+			v.SetMapIndex(k, reflect.ValueOf(out).Convert(v.Type().Elem()))
+		}
+	case reflect.String:
+		if mode == "" {
+			return nil
+		}
+		out, err := r.resolveString(ctx, name, v.String(), mode)
+		if err != nil {
+			return err
+		}
+		v.SetString(out)
+	default:
+	}
+
+	// No error
+	return nil
+}
+
+func (r *resolver) resolveString(ctx context.Context, name, value, mode string) (string, error) {
+	// Check arguments
+	if mode != resolveValue && mode != resolveFile {
+		return "", fmt.Errorf("unsupported resolution mode '%s' for '%s' setting", mode, name)
+	}
+
+	// Ignore non reference values
+	if !reference.IsReference(value) {
+		return value, nil
+	}
+
+	// File references already designate a path
+	if mode == resolveFile && strings.HasPrefix(value, "file://") {
+		return strings.TrimPrefix(value, "file://"), nil
+	}
+
+	// Resolve reference
+	out, err := reference.Resolve(ctx, value)
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve '%s' setting: %w", name, err)
+	}
+
+	// Materialize as file if required
+	if mode == resolveFile {
+		path, err := writePrivateFile(name, out)
+		if err != nil {
+			return "", err
+		}
+		r.files = append(r.files, path)
+		return path, nil
+	}
+
+	// No error
+	return out, nil
+}
+
+func writePrivateFile(name, content string) (string, error) {
+	// Create temporary file (created with 0600 permissions)
+	f, err := os.CreateTemp("", "harp-server-*")
+	if err != nil {
+		return "", fmt.Errorf("unable to create file for '%s' setting: %w", name, err)
+	}
+	defer f.Close()
+
+	// Write content
+	if _, err := f.WriteString(content); err != nil {
+		removeFiles([]string{f.Name()})
+		return "", fmt.Errorf("unable to write file for '%s' setting: %w", name, err)
+	}
+
+	// No error
+	return f.Name(), nil
+}
+
+func removeFiles(paths []string) error {
+	var firstErr error
+	for _, p := range paths {
+		if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) && firstErr == nil {
+			firstErr = fmt.Errorf("unable to remove resolved file: %w", err)
+		}
+	}
+
+	return firstErr
+}
+
+func joinName(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+
+	return strings.Join([]string{prefix, name}, ".")
+}
@@ -0,0 +1,209 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestResolveReferences(t *testing.T) {
+	t.Setenv("CONFIG_TEST_KEY", "s3cr3t")
+	t.Setenv("CONFIG_TEST_CA", "-----BEGIN CERTIFICATE-----")
+
+	caPath := filepath.Join(t.TempDir(), "ca.pem")
+
+	c := &Configuration{
+		Keyring: []string{"env://CONFIG_TEST_KEY", "plain-key"},
+		Transformers: []Transformer{
+			{Name: "env://CONFIG_TEST_KEY", Key: "env://CONFIG_TEST_KEY"},
+		},
+		Backends: []Backend{
+			{
+				NS:  "production",
+				URL: "env://CONFIG_TEST_KEY",
+				Vault: VaultOptions{
+					Address:   "env://CONFIG_TEST_KEY",
+					SecretID:  "env://CONFIG_TEST_KEY",
+					CACert:    "file://" + caPath,
+					TokenFile: "/var/run/secrets/token",
+					JWTFile:   "env://CONFIG_TEST_CA",
+				},
+				GCS: GCSOptions{
+					CredentialsFile: "/etc/gcs/credentials.json",
+				},
+			},
+		},
+	}
+	defer func() {
+		if err := Cleanup(); err != nil {
+			t.Errorf("unexpected cleanup error: %v", err)
+		}
+	}()
+
+	if err := c.ResolveReferences(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Tagged values are resolved
+	if got := c.Keyring; got[0] != "s3cr3t" || got[1] != "plain-key" {
+		t.Errorf("unexpected keyring %v", got)
+	}
+	if got := c.Transformers[0].Key; got != "s3cr3t" {
+		t.Errorf("unexpected transformer key %q", got)
+	}
+	if got := c.Backends[0].Vault.SecretID; got != "s3cr3t" {
+		t.Errorf("unexpected secret id %q", got)
+	}
+
+	// Untagged values are kept
+	if got := c.Transformers[0].Name; got != "env://CONFIG_TEST_KEY" {
+		t.Errorf("untagged transformer name has been resolved to %q", got)
+	}
+	if got := c.Backends[0].URL; got != "env://CONFIG_TEST_KEY" {
+		t.Errorf("untagged url has been resolved to %q", got)
+	}
+	if got := c.Backends[0].Vault.Address; got != "env://CONFIG_TEST_KEY" {
+		t.Errorf("untagged address has been resolved to %q", got)
+	}
+
+	// File paths are kept, file references designate a path
+	if got := c.Backends[0].Vault.TokenFile; got != "/var/run/secrets/token" {
+		t.Errorf("unexpected token file %q", got)
+	}
+	if got := c.Backends[0].GCS.CredentialsFile; got != "/etc/gcs/credentials.json" {
+		t.Errorf("unexpected credentials file %q", got)
+	}
+	if got := c.Backends[0].Vault.CACert; got != caPath {
+		t.Errorf("unexpected ca certificate path %q", got)
+	}
+
+	// Other references are materialized as private files
+	jwtPath := c.Backends[0].Vault.JWTFile
+	content, err := os.ReadFile(jwtPath)
+	if err != nil {
+		t.Fatalf("unable to read materialized file: %v", err)
+	}
+	if string(content) != "-----BEGIN CERTIFICATE-----" {
+		t.Errorf("unexpected materialized content %q", content)
+	}
+	info, err := os.Stat(jwtPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("unexpected file permissions %o", perm)
+	}
+
+	// Cleanup removes materialized files
+	if err := Cleanup(); err != nil {
+		t.Fatalf("unexpected cleanup error: %v", err)
+	}
+	if _, err := os.Stat(jwtPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected materialized file to be removed, got %v", err)
+	}
+}
+
+func TestResolveReferences_Error(t *testing.T) {
+	t.Setenv("CONFIG_TEST_CA", "-----BEGIN CERTIFICATE-----")
+
+	c := &Configuration{
+		Backends: []Backend{
+			{
+				Vault: VaultOptions{
+					TokenFile: "env://CONFIG_TEST_CA",
+					SecretID:  "file://" + filepath.Join(t.TempDir(), "missing"),
+				},
+			},
+		},
+	}
+
+	if err := c.ResolveReferences(context.Background()); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	// Partially resolved files are removed
+	path := c.Backends[0].Vault.TokenFile
+	if path == "env://CONFIG_TEST_CA" {
+		t.Fatal("expected token file to be materialized before the failure")
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected materialized file to be removed, got %v", err)
+	}
+}
+
+func TestResolver_Modes(t *testing.T) {
+	t.Setenv("CONFIG_TEST_KEY", "s3cr3t")
+
+	type nested struct {
+		Value    string `resolve:"value"`
+		Untagged string
+	}
+	type settings struct {
+		Untagged string
+		Value    string            `resolve:"value"`
+		Pointer  *string           `resolve:"value"`
+		Labels   map[string]string `resolve:"value"`
+		Nested   nested
+		Ignored  nested `resolve:"-"`
+	}
+
+	const ref = "env://CONFIG_TEST_KEY"
+	pointer := ref
+	s := settings{
+		Untagged: ref,
+		Value:    ref,
+		Pointer:  &pointer,
+		Labels:   map[string]string{"key": ref},
+		Nested:   nested{Value: ref, Untagged: ref},
+		Ignored:  nested{Value: ref, Untagged: ref},
+	}
+
+	r := &resolver{}
+	if err := r.resolveValue(context.Background(), "", reflect.ValueOf(&s), ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.Untagged != ref || s.Nested.Untagged != ref {
+		t.Errorf("untagged fields have been resolved: %+v", s)
+	}
+	if s.Ignored.Value != ref || s.Ignored.Untagged != ref {
+		t.Errorf("ignored fields have been resolved: %+v", s.Ignored)
+	}
+	if s.Value != "s3cr3t" || *s.Pointer != "s3cr3t" || s.Labels["key"] != "s3cr3t" || s.Nested.Value != "s3cr3t" {
+		t.Errorf("tagged fields have not been resolved: %+v", s)
+	}
+	if len(r.files) != 0 {
+		t.Errorf("unexpected materialized files %v", r.files)
+	}
+}
+
+func TestResolver_UnsupportedMode(t *testing.T) {
+	type settings struct {
+		Value string `resolve:"inline"`
+	}
+
+	s := settings{Value: "env://CONFIG_TEST_KEY"}
+	r := &resolver{}
+	if err := r.resolveValue(context.Background(), "", reflect.ValueOf(&s), ""); err == nil {
+		t.Error("expected an error for an unsupported mode")
+	}
+}
@@ -0,0 +1,136 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package core contains components shared by all dispatchers.
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/config"
+	"github.com/elastic/harp-plugins/server/pkg/server/manager"
+	"github.com/elastic/harp-plugins/server/pkg/server/namespace"
+	"github.com/elastic/harp-plugins/server/pkg/server/ratelimit"
+	"github.com/elastic/harp-plugins/server/pkg/server/storage/backends/container"
+	"github.com/elastic/harp/pkg/sdk/log"
+)
+
+// RateLimiter returns the rate limiter built from settings, nil if disabled.
+func RateLimiter(cfg *config.Configuration) (*ratelimit.Limiter, error) {
+	// Rate limiting is optional
+	if !cfg.RateLimit.Enabled {
+		return nil, nil
+	}
+
+	// Forwarded client addresses are only honoured from trusted proxies
+	proxies, err := ratelimit.ParseNetworks(cfg.RateLimit.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rate limit settings: %w", err)
+	}
+
+	// Namespace overrides
+	namespaces := map[string]ratelimit.Policy{}
+	for _, ns := range cfg.RateLimit.Namespaces {
+		id, err := namespace.Parse(ns.NS)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate limit namespace: %w", err)
+		}
+		if _, ok := namespaces[id]; ok {
+			return nil, fmt.Errorf("rate limit namespace '%s' is declared more than once", id)
+		}
+		namespaces[id] = ratelimit.Policy{Rate: ns.Rate, Burst: ns.Burst}
+	}
+
+	return ratelimit.New(&ratelimit.Options{
+		Client:             ratelimit.Policy{Rate: cfg.RateLimit.Client.Rate, Burst: cfg.RateLimit.Client.Burst},
+		Namespace:          ratelimit.Policy{Rate: cfg.RateLimit.Namespace.Rate, Burst: cfg.RateLimit.Namespace.Burst},
+		Namespaces:         namespaces,
+		MaxConcurrentCalls: cfg.RateLimit.MaxConcurrentCalls,
+		ConcurrencyWait:    cfg.RateLimit.ConcurrencyWait,
+		IdleTimeout:        cfg.RateLimit.IdleTimeout,
+		TrustedProxies:     proxies,
+	}), nil
+}
+
+// BackendManager returns a backend manager with all configured namespaces
+// registered.
+func BackendManager(ctx context.Context, cfg *config.Configuration, l *ratelimit.Limiter) (manager.Backend, error) {
+	// Apply container keyring before loading containers
+	container.SetKeyring(cfg.Keyring)
+
+	// Validate namespaces before loading containers
+	if err := checkNamespaces(cfg.Backends); err != nil {
+		return nil, err
+	}
+
+	// Initialize default manager
+	bm := manager.Default()
+
+	// Backends
+	for i := range cfg.Backends {
+		b := &cfg.Backends[i]
+
+		// Build engine URL from settings
+		engineURL, err := b.EngineURL()
+		if err != nil {
+			log.CheckErrCtx(ctx, "Unable to close secret backends", bm.Close())
+			return nil, fmt.Errorf("invalid backend settings: %w", err)
+		}
+
+		// Register namespace engine
+		if err := bm.Register(ctx, b.NS, engineURL); err != nil {
+			log.CheckErrCtx(ctx, "Unable to close secret backends", bm.Close())
+			return nil, err
+		}
+	}
+
+	// Release engine resources (informers, connections) with the server
+	go func() {
+		<-ctx.Done()
+		log.CheckErrCtx(ctx, "Unable to close secret backends", bm.Close())
+	}()
+
+	// Apply rate limits
+	if l != nil {
+		return ratelimit.Backend(bm, l), nil
+	}
+
+	// No error
+	return bm, nil
+}
+
+// -----------------------------------------------------------------------------
+
+// checkNamespaces validates backend namespace identifiers, and reports
+// declarations resolving to the same namespace.
+func checkNamespaces(backends []config.Backend) error {
+	declared := map[string]string{}
+	for _, b := range backends {
+		id, err := namespace.Parse(b.NS)
+		if err != nil {
+			return fmt.Errorf("invalid backend namespace: %w", err)
+		}
+		if previous, ok := declared[id]; ok {
+			return fmt.Errorf("backend namespaces '%s' and '%s' collide as '%s'", previous, b.NS, id)
+		}
+		declared[id] = b.NS
+	}
+
+	// No error
+	return nil
+}
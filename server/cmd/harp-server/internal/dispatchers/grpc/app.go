@@ -24,6 +24,8 @@ import (
 	"google.golang.org/grpc"
 
 	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/config"
+	"github.com/elastic/harp-plugins/server/pkg/server/manager"
+	"github.com/elastic/harp-plugins/server/pkg/server/ratelimit"
 )
 
 type application struct {
@@ -55,3 +57,22 @@ func New(ctx context.Context, cfg *config.Configuration) (*grpc.Server, error) {
 	// Return server
 	return app.server, err
 }
+
+// NewWithBackend initialize the application using the given backend manager
+// and rate limiter.
+func NewWithBackend(ctx context.Context, cfg *config.Configuration, bm manager.Backend, l *ratelimit.Limiter) (*grpc.Server, error) {
+	var err error
+
+	once.Do(func() {
+		// Initialize application
+		app = &application{
+			cfg: cfg,
+		}
+
+		// Initialize core context
+		app.server, err = setupWithBackend(ctx, cfg, bm, l)
+	})
+
+	// Return server
+	return app.server, err
+}
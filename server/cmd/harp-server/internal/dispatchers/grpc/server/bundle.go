@@ -24,6 +24,7 @@ import (
 	"google.golang.org/grpc/status"
 
 	"github.com/elastic/harp-plugins/server/pkg/server/manager"
+	"github.com/elastic/harp-plugins/server/pkg/server/ratelimit"
 	bundlev1 "github.com/elastic/harp/api/gen/go/harp/bundle/v1"
 )
 
@@ -53,6 +54,9 @@ func (s *grpcBundleServer) GetSecret(ctx context.Context, req *bundlev1.GetSecre
 
 	// Delegate to engine to retrieve secret
 	content, err := s.bm.GetSecret(ctx, req.Namespace, req.Path)
+	if st := ratelimit.Status(ctx, err); st != nil {
+		return nil, st
+	}
 	if err != nil {
 		return nil, status.Errorf(codes.NotFound, "Secret '%s' could not be retrieved from '%s' namespace", req.Path, req.Namespace)
 	}
@@ -31,38 +31,25 @@ import (
 	"google.golang.org/grpc/reflection"
 
 	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/config"
+	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/dispatchers/core"
 	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/dispatchers/grpc/server"
 	"github.com/elastic/harp-plugins/server/pkg/server/manager"
-	"github.com/elastic/harp-plugins/server/pkg/server/storage/backends/container"
+	"github.com/elastic/harp-plugins/server/pkg/server/ratelimit"
 	bundlev1 "github.com/elastic/harp/api/gen/go/harp/bundle/v1"
 	"github.com/elastic/harp/pkg/sdk/log"
 	"github.com/elastic/harp/pkg/sdk/tlsconfig"
-	vpath "github.com/elastic/harp/pkg/vault/path"
 )
 
-func backendManager(ctx context.Context, cfg *config.Configuration) (manager.Backend, error) {
-	// Initialize default manager
-	bm := manager.Default()
-
-	// Backends
-	for _, b := range cfg.Backends {
-		// Register namespace engine
-		if err := bm.Register(ctx, vpath.SanitizePath(b.NS), b.URL); err != nil {
-			return nil, err
-		}
-	}
-
-	// No error
-	return bm, nil
-}
-
-func grpcServer(ctx context.Context, cfg *config.Configuration, bm manager.Backend) (*grpc.Server, error) {
-	// Apply container keyring
-	container.SetKeyring(cfg.Keyring)
+func grpcServer(ctx context.Context, cfg *config.Configuration, bm manager.Backend, l *ratelimit.Limiter) (*grpc.Server, error) {
 
 	// gRPC middlewares
 	sopts := []grpc.ServerOption{}
 
+	// Apply client rate limits
+	if l != nil {
+		sopts = append(sopts, grpc.UnaryInterceptor(ratelimit.UnaryServerInterceptor(l)))
+	}
+
 	// Enable TLS if requested
 	if cfg.GRPC.UseTLS {
 		// Client authentication enabled but not required
@@ -106,7 +93,15 @@ func grpcServer(ctx context.Context, cfg *config.Configuration, bm manager.Backe
 
 func setup(ctx context.Context, cfg *config.Configuration) (*grpc.Server, error) {
 	wire.Build(
-		backendManager,
+		core.RateLimiter,
+		core.BackendManager,
+		grpcServer,
+	)
+	return &grpc.Server{}, nil
+}
+
+func setupWithBackend(ctx context.Context, cfg *config.Configuration, bm manager.Backend, l *ratelimit.Limiter) (*grpc.Server, error) {
+	wire.Build(
 		grpcServer,
 	)
 	return &grpc.Server{}, nil
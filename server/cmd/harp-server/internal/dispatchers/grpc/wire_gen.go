@@ -10,13 +10,13 @@ import (
 	"context"
 	"crypto/tls"
 	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/config"
+	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/dispatchers/core"
 	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/dispatchers/grpc/server"
 	"github.com/elastic/harp-plugins/server/pkg/server/manager"
-	"github.com/elastic/harp-plugins/server/pkg/server/storage/backends/container"
+	"github.com/elastic/harp-plugins/server/pkg/server/ratelimit"
 	"github.com/elastic/harp/api/gen/go/harp/bundle/v1"
 	"github.com/elastic/harp/pkg/sdk/log"
 	"github.com/elastic/harp/pkg/sdk/tlsconfig"
-	"github.com/elastic/harp/pkg/vault/path"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
@@ -26,38 +26,39 @@ import (
 // Injectors from wire.go:
 
 func setup(ctx context.Context, cfg *config.Configuration) (*grpc.Server, error) {
-	backend, err := backendManager(ctx, cfg)
+	limiter, err := core.RateLimiter(cfg)
 	if err != nil {
 		return nil, err
 	}
-	server, err := grpcServer(ctx, cfg, backend)
+	backend, err := core.BackendManager(ctx, cfg, limiter)
+	if err != nil {
+		return nil, err
+	}
+	server, err := grpcServer(ctx, cfg, backend, limiter)
 	if err != nil {
 		return nil, err
 	}
 	return server, nil
 }
 
-// wire.go:
-
-func backendManager(ctx context.Context, cfg *config.Configuration) (manager.Backend, error) {
-
-	bm := manager.Default()
-
-	for _, b := range cfg.Backends {
-
-		if err := bm.Register(ctx, path.SanitizePath(b.NS), b.URL); err != nil {
-			return nil, err
-		}
+func setupWithBackend(ctx context.Context, cfg *config.Configuration, bm manager.Backend, l *ratelimit.Limiter) (*grpc.Server, error) {
+	server, err := grpcServer(ctx, cfg, bm, l)
+	if err != nil {
+		return nil, err
 	}
-
-	return bm, nil
+	return server, nil
 }
 
-func grpcServer(ctx context.Context, cfg *config.Configuration, bm manager.Backend) (*grpc.Server, error) {
-	container.SetKeyring(cfg.Keyring)
+// wire.go:
+
+func grpcServer(ctx context.Context, cfg *config.Configuration, bm manager.Backend, l *ratelimit.Limiter) (*grpc.Server, error) {
 
 	sopts := []grpc.ServerOption{}
 
+	if l != nil {
+		sopts = append(sopts, grpc.UnaryInterceptor(ratelimit.UnaryServerInterceptor(l)))
+	}
+
 	if cfg.GRPC.UseTLS {
 
 		clientAuth := tls.VerifyClientCertIfGiven
@@ -18,6 +18,8 @@
 package routes
 
 import (
+	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"net/http"
@@ -25,56 +27,369 @@ import (
 
 	"go.uber.org/zap"
 
+	"github.com/elastic/harp-plugins/server/pkg/server/envelope"
+	"github.com/elastic/harp-plugins/server/pkg/server/format"
+	"github.com/elastic/harp-plugins/server/pkg/server/ratelimit"
 	"github.com/elastic/harp-plugins/server/pkg/server/storage"
+	"github.com/elastic/harp-plugins/server/pkg/server/storage/backends/container"
 	"github.com/elastic/harp/pkg/sdk/log"
+	"github.com/elastic/harp/pkg/sdk/value"
 	"github.com/elastic/harp/pkg/sdk/value/encryption"
 )
 
+const (
+	// recipientHeader carries the client public key used to seal the response.
+	recipientHeader = "X-Harp-Recipient"
+	// recipientJWKHeader carries the client JSON Web Key used to seal the response.
+	recipientJWKHeader = "X-Harp-Recipient-JWK"
+	// envelopeHeader describes the response encryption envelope.
+	envelopeHeader = "X-Harp-Envelope"
+	// versionHeader carries the engine secret version.
+	versionHeader = "X-Harp-Version"
+	// originHeader carries the source serving the secret for merged engines.
+	originHeader = "X-Harp-Origin"
+	// indexHeader carries the representation index used by blocking queries.
+	indexHeader = "X-Harp-Index"
+)
+
+// varyHeader lists request headers selecting the response representation.
+var varyHeader = strings.Join([]string{"Accept", recipientHeader, recipientJWKHeader}, ", ")
+
+// envelopeSymmetric identifies responses encrypted with a request key.
+const envelopeSymmetric envelope.Envelope = "symmetric"
+
 // Backend returns a backend http request handler.
-func backend(namespace string, engine storage.Engine) http.HandlerFunc {
+func backend(namespace string, engine storage.Engine, cache *cachePolicy, watch *watchPolicy) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var (
-			ctx    = r.Context()
-			id     = r.URL.Path
-			keyRaw = r.URL.Query().Get("key")
+			ctx = r.Context()
+			id  = r.URL.Path
+			q   = r.URL.Query()
 		)
 
 		// Remove namespace prefix
 		identifier := strings.TrimPrefix(id, fmt.Sprintf("/%s", namespace))
 
-		// Retrieve secret from engine
-		secret, err := engine.Get(ctx, identifier)
-		if errors.Is(err, storage.ErrSecretNotFound) {
-			http.Error(w, "secret not found", http.StatusNotFound)
+		// Representation depends on format negotiation and response encryption
+		w.Header().Set("Vary", varyHeader)
+
+		// Prepare representation loader
+		loader := func(ctx context.Context) (*representation, error) {
+			return load(ctx, r, engine, identifier, cache)
+		}
+
+		// Check watch requests
+		index := q.Get("index")
+		if (q.Get("watch") == "true" || index != "") && watch == nil {
+			http.Error(w, "watch is not enabled", http.StatusBadRequest)
 			return
 		}
-		if err != nil {
-			log.For(ctx).Error("unable to retrieve secret from engine", zap.Error(err), zap.String("url", r.URL.String()))
-			http.Error(w, "unable to retrieve secret", http.StatusBadRequest)
+
+		// Stream change events
+		if q.Get("watch") == "true" {
+			watch.stream(w, r, namespace, identifier, engine, loader)
 			return
 		}
 
-		// key is defined
-		if keyRaw != "" {
-			// Retrieve transformer from key
-			transformer, err := encryption.FromKey(keyRaw)
-			if err != nil {
-				log.For(ctx).Error("unable to initialize secret transformer", zap.String("url", r.URL.String()))
-				http.Error(w, "unable to initialize secret transformer", http.StatusInternalServerError)
-				return
-			}
+		// Retrieve secret representation
+		rep, err := loader(ctx)
+		if writeError(w, r, err) {
+			return
+		}
 
-			// Apply transformation to secret value
-			secret, err = transformer.To(ctx, secret)
-			if err != nil {
-				log.For(ctx).Error("unable to protect secret", zap.String("url", r.URL.String()))
-				http.Error(w, "unable to protect secret", http.StatusBadRequest)
+		// Blocking query, wait for a representation change
+		if index != "" {
+			rep, err = watch.wait(r, namespace, identifier, index, engine, rep, loader)
+			if writeError(w, r, err) {
 				return
 			}
 		}
 
+		// Apply response encryption
+		secret, contentType, envelopeType, err := protect(r, rep)
+		if writeError(w, r, err) {
+			return
+		}
+
+		// Expose representation validators, the entity tag describes the sent
+		// bytes while the index identifies the secret representation.
+		etag := rep.etag
+		if envelopeType != "" {
+			etag = cache.etag(contentType, secret)
+		}
+		versionHeaders(w, &rep.metadata)
+		w.Header().Set("ETag", etag)
+		w.Header().Set(indexHeader, rep.etag)
+		w.Header().Set("Cache-Control", cache.control())
+
+		// Check client cache, expired blocking queries return the current
+		// representation
+		if notModified(r, etag, rep.metadata.Created) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if envelopeType != "" {
+			w.Header().Set(envelopeHeader, string(envelopeType))
+		}
+
 		// Send result
+		w.Header().Set("Content-Type", contentType)
 		w.WriteHeader(http.StatusOK)
-		fmt.Fprintf(w, "%s", secret)
+		_, err = w.Write(secret)
+		log.CheckErrCtx(ctx, "Unable to write response", err)
+	}
+}
+
+// representation describes a converted secret value.
+type representation struct {
+	body        []byte
+	contentType string
+	etag        string
+	metadata    storage.Metadata
+}
+
+// requestError describes an error returned to the client.
+type requestError struct {
+	status  int
+	message string
+	cause   error
+}
+
+func (e *requestError) Error() string { return e.message }
+func (e *requestError) Unwrap() error { return e.cause }
+
+// load retrieves the secret from the engine and converts it to the requested
+// representation.
+func load(ctx context.Context, r *http.Request, engine storage.Engine, identifier string, cache *cachePolicy) (*representation, error) {
+	// Retrieve secret from engine
+	entry, err := storage.GetWithMetadata(ctx, engine, identifier)
+	if errors.Is(err, storage.ErrSecretNotFound) {
+		return nil, &requestError{status: http.StatusNotFound, message: "secret not found", cause: err}
+	}
+	if err != nil {
+		return nil, &requestError{status: http.StatusBadRequest, message: "unable to retrieve secret", cause: fmt.Errorf("unable to retrieve secret from engine: %w", err)}
+	}
+
+	// Expose container packages as documents
+	entry, err = container.JSON(entry)
+	if err != nil {
+		return nil, &requestError{status: http.StatusBadRequest, message: "unable to decode secret", cause: err}
+	}
+
+	// Convert secret to requested format
+	secret, contentType, status, err := convert(r, identifier, entry.Value, entry.Metadata.ContentType)
+	if err != nil {
+		return nil, &requestError{status: status, message: err.Error(), cause: fmt.Errorf("unable to convert secret: %w", err)}
+	}
+
+	// No error
+	return &representation{
+		body:        secret,
+		contentType: contentType,
+		etag:        cache.etag(contentType, secret),
+		metadata:    entry.Metadata,
+	}, nil
+}
+
+// protect applies the requested response encryption to the representation.
+// It returns the response body, its content type and the envelope type if
+// encrypted.
+func protect(r *http.Request, rep *representation) ([]byte, string, envelope.Envelope, error) {
+	// Prepare response encryption
+	transformer, envelopeType, status, err := protection(r)
+	if err != nil {
+		return nil, "", "", &requestError{status: status, message: err.Error(), cause: fmt.Errorf("unable to initialize secret transformer: %w", err)}
+	}
+	if transformer == nil {
+		return rep.body, rep.contentType, "", nil
+	}
+
+	// Apply transformation to secret value
+	secret, err := transformer.To(r.Context(), rep.body)
+	if err != nil {
+		return nil, "", "", &requestError{status: http.StatusBadRequest, message: "unable to protect secret", cause: fmt.Errorf("unable to protect secret: %w", err)}
+	}
+
+	// Encrypted content is opaque
+	return secret, envelopeType.ContentType(), envelopeType, nil
+}
+
+// writeError sends the error to the client. It returns false if err is nil.
+func writeError(w http.ResponseWriter, r *http.Request, err error) bool {
+	if err == nil {
+		return false
+	}
+	if ratelimit.WriteError(w, err) {
+		return true
+	}
+
+	// Default to an opaque error
+	reqErr := &requestError{status: http.StatusInternalServerError, message: "internal error", cause: err}
+	errors.As(err, &reqErr)
+
+	// Not found is not an error
+	if reqErr.status != http.StatusNotFound {
+		log.For(r.Context()).Error("unable to process secret request", zap.Error(reqErr.cause), zap.String("path", r.URL.Path))
+	}
+
+	http.Error(w, reqErr.message, reqErr.status)
+	return true
+}
+
+// versionHeaders sets version, origin and Last-Modified headers from secret
+// metadata.
+func versionHeaders(w http.ResponseWriter, meta *storage.Metadata) {
+	if meta.Version != "" {
+		w.Header().Set(versionHeader, meta.Version)
+	}
+	if meta.Origin != "" {
+		w.Header().Set(originHeader, meta.Origin)
+	}
+	if !meta.Created.IsZero() {
+		w.Header().Set("Last-Modified", meta.Created.UTC().Format(http.TimeFormat))
 	}
 }
+
+// protection returns the transformer used to encrypt the response according
+// to the request. A nil transformer is returned when no encryption is requested.
+func protection(r *http.Request) (value.Transformer, envelope.Envelope, int, error) {
+	var (
+		keyRaw    = r.URL.Query().Get("key")
+		recipient = r.URL.Query().Get("recipient")
+		jwkRaw    = r.Header.Get(recipientJWKHeader)
+	)
+
+	// Recipient could be given using a header
+	if recipient == "" {
+		recipient = r.Header.Get(recipientHeader)
+	}
+
+	// Check exclusive settings
+	count := 0
+	for _, v := range []string{keyRaw, recipient, jwkRaw} {
+		if v != "" {
+			count++
+		}
+	}
+	switch {
+	case count == 0:
+		return nil, "", http.StatusOK, nil
+	case count > 1:
+		return nil, "", http.StatusBadRequest, errors.New("only one of key, recipient or JWK could be used")
+	}
+
+	switch {
+	case keyRaw != "":
+		// Retrieve transformer from symmetric key
+		transformer, err := encryption.FromKey(keyRaw)
+		if err != nil {
+			return nil, "", http.StatusInternalServerError, errors.New("unable to initialize secret transformer")
+		}
+		return transformer, envelopeSymmetric, http.StatusOK, nil
+	case recipient != "":
+		// Seal to the given public key
+		transformer, e, err := envelope.Transformer(recipient)
+		if err != nil {
+			return nil, "", http.StatusBadRequest, errors.New("invalid or unsupported recipient public key")
+		}
+		return transformer, e, http.StatusOK, nil
+	default:
+	}
+
+	// JWK could be sent as raw JSON or base64url encoded
+	jwk := []byte(jwkRaw)
+	if decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(jwkRaw, "=")); err == nil {
+		jwk = decoded
+	}
+	transformer, e, err := envelope.FromJWK(jwk)
+	if err != nil {
+		return nil, "", http.StatusBadRequest, errors.New("invalid or unsupported recipient JWK")
+	}
+
+	// No error
+	return transformer, e, http.StatusOK, nil
+}
+
+// convert applies the format negotiation and field extraction to the secret
+// content. The engine content type is used for unconverted content when the
+// identifier has no known extension. It returns the response body, its content
+// type and the HTTP status code to use on error.
+func convert(r *http.Request, identifier string, secret []byte, engineContentType string) ([]byte, string, int, error) {
+	var (
+		q          = r.URL.Query()
+		field      = q.Get("field")
+		formatName = q.Get("format")
+		target     format.Format
+		acceptable = true
+	)
+
+	// Resolve target format, query parameter has precedence
+	if formatName != "" {
+		f, ok := format.Parse(formatName)
+		if !ok {
+			return nil, "", http.StatusBadRequest, fmt.Errorf("unsupported format '%s'", formatName)
+		}
+		target = f
+	} else {
+		// Scalar fields are served as text whatever the Accept header, so
+		// that the negotiation result is only enforced for encoded content.
+		target, acceptable = format.Negotiate(r.Header.Get("Accept"))
+	}
+	if !acceptable && field == "" {
+		return nil, "", http.StatusNotAcceptable, errors.New("no acceptable format")
+	}
+
+	// Detect source format from identifier extension
+	source, _ := format.FromPath(identifier)
+
+	// No conversion required
+	if target == format.Raw && field == "" {
+		if source == "" && engineContentType != "" {
+			return secret, engineContentType, http.StatusOK, nil
+		}
+		if source == "" {
+			if _, err := format.Decode(secret, format.JSON); err == nil {
+				source = format.JSON
+			} else {
+				source = format.Raw
+			}
+		}
+		return secret, format.ContentType(source), http.StatusOK, nil
+	}
+
+	// Decode secret content
+	data, err := format.Decode(secret, source)
+	if err != nil {
+		return nil, "", http.StatusUnprocessableEntity, errors.New("unable to decode secret")
+	}
+
+	// Extract a single field
+	if field != "" {
+		value, ok := format.Field(data, field)
+		if !ok {
+			return nil, "", http.StatusNotFound, fmt.Errorf("field '%s' not found", field)
+		}
+
+		// Nested objects are encoded using the requested format
+		obj, isObject := value.(map[string]interface{})
+		if !isObject {
+			return []byte(format.Scalar(value)), "text/plain; charset=utf-8", http.StatusOK, nil
+		}
+		data = obj
+	}
+	if !acceptable {
+		return nil, "", http.StatusNotAcceptable, errors.New("no acceptable format")
+	}
+
+	// Encode using target format
+	if target == format.Raw {
+		target = format.JSON
+	}
+	out, err := format.Encode(data, target)
+	if err != nil {
+		return nil, "", http.StatusInternalServerError, errors.New("unable to encode secret")
+	}
+
+	// No error
+	return out, format.ContentType(target), http.StatusOK, nil
+}
@@ -0,0 +1,168 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package routes
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+)
+
+func TestBackend_BlockingQueryTimeout(t *testing.T) {
+	engine := &countingEngine{value: []byte("value")}
+	cache := &cachePolicy{key: []byte("key")}
+	watch := &watchPolicy{
+		interval:  10 * time.Millisecond,
+		maxWait:   50 * time.Millisecond,
+		heartbeat: time.Second,
+		poller:    newPoller(10 * time.Millisecond),
+	}
+	handler := backend("root", engine, cache, watch)
+
+	// Retrieve current entity tag
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/root/app/db", nil))
+	etag := rec.Header().Get("ETag")
+
+	// Expired blocking query returns the current representation
+	req := httptest.NewRequest(http.MethodGet, "/root/app/db?index="+url.QueryEscape(etag), nil)
+	rec = httptest.NewRecorder()
+	handler(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "value" || rec.Header().Get("ETag") != etag {
+		t.Fatalf("unexpected blocking query response: %d %q %s", rec.Code, rec.Body.String(), rec.Header().Get("ETag"))
+	}
+
+	// Preconditions still return not modified
+	req = httptest.NewRequest(http.MethodGet, "/root/app/db", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	handler(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Fatalf("expected not modified, got %d", rec.Code)
+	}
+}
+
+func TestConvert_Negotiation(t *testing.T) {
+	secret := []byte(`{"user":"admin","port":5432,"tls":{"enabled":true}}`)
+
+	testCases := []struct {
+		name        string
+		query       string
+		accept      string
+		wantStatus  int
+		wantType    string
+		wantContent string
+	}{
+		{name: "raw", wantStatus: http.StatusOK, wantType: "application/json; charset=utf-8", wantContent: string(secret)},
+		{name: "yaml", accept: "application/yaml", wantStatus: http.StatusOK, wantType: "application/yaml; charset=utf-8", wantContent: "port: 5432\ntls:\n  enabled: true\nuser: admin\n"},
+		{name: "format has precedence", query: "format=dotenv", accept: "application/yaml", wantStatus: http.StatusOK, wantType: "text/x-dotenv; charset=utf-8", wantContent: "PORT=5432\nTLS_ENABLED=true\nUSER=admin\n"},
+		{name: "unsupported format", query: "format=xml", wantStatus: http.StatusBadRequest},
+		{name: "not acceptable", accept: "text/plain", wantStatus: http.StatusNotAcceptable},
+		{name: "scalar field as text", query: "field=user", accept: "text/plain", wantStatus: http.StatusOK, wantType: "text/plain; charset=utf-8", wantContent: "admin"},
+		{name: "scalar field with any accept", query: "field=port", accept: "application/json", wantStatus: http.StatusOK, wantType: "text/plain; charset=utf-8", wantContent: "5432"},
+		{name: "object field as text", query: "field=tls", accept: "text/plain", wantStatus: http.StatusNotAcceptable},
+		{name: "object field", query: "field=tls", accept: "application/json", wantStatus: http.StatusOK, wantType: "application/json; charset=utf-8", wantContent: "{\"enabled\":true}\n"},
+		{name: "unknown field", query: "field=password", accept: "text/plain", wantStatus: http.StatusNotFound},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/root/app/db?"+tc.query, nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+
+			out, contentType, status, err := convert(req, "app/db", secret, "")
+			if tc.wantStatus != http.StatusOK {
+				if err == nil || status != tc.wantStatus {
+					t.Fatalf("expected status %d with an error, got %d (%v)", tc.wantStatus, status, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if contentType != tc.wantType {
+				t.Errorf("content type = %q, want %q", contentType, tc.wantType)
+			}
+			if string(out) != tc.wantContent {
+				t.Errorf("content = %q, want %q", out, tc.wantContent)
+			}
+		})
+	}
+}
+
+func TestBackend_ProtectedValidators(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engine := &countingEngine{value: []byte("value")}
+	cache := &cachePolicy{key: []byte("key")}
+	handler := backend("root", engine, cache, nil)
+
+	// Plain responses use the representation entity tag
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/root/app/db", nil))
+	index := rec.Header().Get("X-Harp-Index")
+	if rec.Code != http.StatusOK || index == "" || rec.Header().Get("ETag") != index {
+		t.Fatalf("unexpected plain response: %d %s %s", rec.Code, rec.Header().Get("ETag"), index)
+	}
+	if got := rec.Header().Get("Vary"); got != "Accept, X-Harp-Recipient, X-Harp-Recipient-JWK" {
+		t.Errorf("unexpected Vary header %q", got)
+	}
+
+	// Protected responses describe the sent bytes
+	req := httptest.NewRequest(http.MethodGet, "/root/app/db", nil)
+	req.Header.Set("X-Harp-Recipient", identity.Recipient().String())
+	req.Header.Set("If-None-Match", index)
+	rec = httptest.NewRecorder()
+	handler(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected sealed content for a plain entity tag, got %d", rec.Code)
+	}
+	etag := rec.Header().Get("ETag")
+	if etag == index || etag != cache.etag(rec.Header().Get("Content-Type"), rec.Body.Bytes()) {
+		t.Errorf("entity tag %s doesn't describe the sent bytes", etag)
+	}
+	if got := rec.Header().Get("X-Harp-Index"); got != index {
+		t.Errorf("unexpected index %s, want %s", got, index)
+	}
+	if got := rec.Header().Get("Vary"); got == "" {
+		t.Error("expected Vary header on protected responses")
+	}
+
+	// Sealed content opens to the representation
+	r, err := age.Decrypt(bytes.NewReader(rec.Body.Bytes()), identity)
+	if err != nil {
+		t.Fatalf("unable to decrypt response: %v", err)
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "value" {
+		t.Errorf("unexpected sealed content %q", out)
+	}
+}
@@ -0,0 +1,153 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package routes
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/config"
+	"github.com/elastic/harp-plugins/server/pkg/server/namespace"
+)
+
+// cachePolicy defines conditional response and client cache settings of a
+// namespace.
+type cachePolicy struct {
+	key    []byte
+	maxAge time.Duration
+}
+
+// CheckCache validates client cache settings, as done when the backend router
+// is built.
+func CheckCache(cfg *config.Configuration) error {
+	_, err := cachePolicies(cfg)
+	return err
+}
+
+// cachePolicies returns namespace cache policies indexed by namespace identifier.
+func cachePolicies(cfg *config.Configuration) (map[string]*cachePolicy, error) {
+	// Namespace overrides
+	cacheEnabled := cfg.HTTP.Cache.MaxAge > 0
+	overrides := map[string]time.Duration{}
+	for _, n := range cfg.HTTP.Cache.Namespaces {
+		ns, err := namespace.Parse(n.NS)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cache policy namespace: %w", err)
+		}
+		overrides[ns] = n.MaxAge
+		cacheEnabled = cacheEnabled || n.MaxAge > 0
+	}
+
+	// ETag are keyed hashes, so that secret digests are not exposed
+	key := []byte(cfg.HTTP.Cache.ETagKey)
+	switch {
+	case len(key) > 0:
+	case cacheEnabled:
+		// Cached entity tags must be stable across restarts and replicas
+		return nil, errors.New("etagKey is mandatory when client cache is enabled")
+	default:
+		key = defaultETagKey(cfg)
+	}
+
+	res := map[string]*cachePolicy{}
+	for _, b := range cfg.Backends {
+		ns, err := namespace.Parse(b.NS)
+		if err != nil {
+			return nil, fmt.Errorf("invalid backend namespace: %w", err)
+		}
+
+		maxAge, ok := overrides[ns]
+		if !ok {
+			maxAge = cfg.HTTP.Cache.MaxAge
+		}
+
+		res[ns] = &cachePolicy{
+			key:    key,
+			maxAge: maxAge,
+		}
+	}
+
+	// No error
+	return res, nil
+}
+
+// defaultETagKey derives the entity tag key from the backend configuration,
+// so that replicas sharing the configuration issue the same entity tags.
+func defaultETagKey(cfg *config.Configuration) []byte {
+	h := sha256.New()
+	h.Write([]byte("harp-server-etag-key"))
+	for _, b := range cfg.Backends {
+		h.Write([]byte{0})
+		h.Write([]byte(b.NS))
+		h.Write([]byte{0})
+		h.Write([]byte(b.URL))
+	}
+
+	return h.Sum(nil)
+}
+
+// etag returns a strong entity tag for the given representation.
+func (p *cachePolicy) etag(contentType string, body []byte) string {
+	h := hmac.New(sha256.New, p.key)
+	h.Write([]byte(contentType))
+	h.Write([]byte{0})
+	h.Write(body)
+
+	return fmt.Sprintf("%q", hex.EncodeToString(h.Sum(nil)[:16]))
+}
+
+// control returns the Cache-Control header value.
+func (p *cachePolicy) control() string {
+	if p.maxAge <= 0 {
+		return "no-store"
+	}
+
+	return fmt.Sprintf("private, max-age=%d", int64(p.maxAge.Seconds()))
+}
+
+// notModified evaluates request preconditions (RFC 7232), If-None-Match has
+// precedence over If-Modified-Since.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	// Check entity tags
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Check modification time
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		t, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+		return !lastModified.Truncate(time.Second).After(t)
+	}
+
+	return false
+}
@@ -0,0 +1,81 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package routes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/config"
+)
+
+func cacheConfig() *config.Configuration {
+	return &config.Configuration{
+		Backends: []config.Backend{
+			{NS: "root", URL: "bundle:///var/lib/harp/secrets.bundle"},
+			{NS: "public", URL: "env://APP_"},
+		},
+	}
+}
+
+func TestCachePolicies_DefaultKey(t *testing.T) {
+	first, err := cachePolicies(cacheConfig())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := cachePolicies(cacheConfig())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Entity tags are stable across instances
+	if a, b := first["root"].etag("text/plain", []byte("value")), second["root"].etag("text/plain", []byte("value")); a != b {
+		t.Fatalf("expected deterministic entity tags, got %s and %s", a, b)
+	}
+
+	// Key depends on backend configuration
+	cfg := cacheConfig()
+	cfg.Backends[0].URL = "bundle:///var/lib/harp/other.bundle"
+	third, err := cachePolicies(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if a, b := first["root"].etag("text/plain", []byte("value")), third["root"].etag("text/plain", []byte("value")); a == b {
+		t.Fatal("expected entity tags to depend on backend configuration")
+	}
+}
+
+func TestCachePolicies_KeyRequiredWithMaxAge(t *testing.T) {
+	cfg := cacheConfig()
+	cfg.HTTP.Cache.Namespaces = []config.NamespaceCache{{NS: "public", MaxAge: 30 * time.Second}}
+	if _, err := cachePolicies(cfg); err == nil {
+		t.Fatal("expected error without etag key")
+	}
+
+	cfg.HTTP.Cache.ETagKey = "shared-secret-key"
+	policies, err := cachePolicies(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := policies["public"].control(); got != "private, max-age=30" {
+		t.Fatalf("unexpected cache control: %s", got)
+	}
+	if got := policies["root"].control(); got != "no-store" {
+		t.Fatalf("unexpected cache control: %s", got)
+	}
+}
@@ -0,0 +1,182 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package routes
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/elastic/harp-plugins/server/pkg/server/storage"
+	"github.com/elastic/harp/pkg/sdk/log"
+)
+
+// poller checks watched secrets once per interval for all subscribers, so
+// that engine load doesn't grow with the number of watching clients. Engines
+// notifying their changes (storage.Notifier) are checked immediately.
+type poller struct {
+	sync.Mutex
+	interval time.Duration
+	ticker   tickerFunc
+	watches  map[string]*secretWatch
+}
+
+// tickerFunc returns a channel ticking once per interval and its stop
+// function.
+type tickerFunc func(interval time.Duration) (<-chan time.Time, func())
+
+func newTicker(interval time.Duration) (<-chan time.Time, func()) {
+	t := time.NewTicker(interval)
+	return t.C, t.Stop
+}
+
+// secretWatch describes a polled secret and its subscribers.
+type secretWatch struct {
+	subscribers map[chan struct{}]struct{}
+	cancel      context.CancelFunc
+}
+
+func newPoller(interval time.Duration) *poller {
+	return &poller{
+		interval: interval,
+		ticker:   newTicker,
+		watches:  map[string]*secretWatch{},
+	}
+}
+
+// subscribe returns a channel notified each time the secret changes in the
+// engine. The secret is polled while at least one subscriber exists, the
+// returned function must be called to unsubscribe.
+func (p *poller) subscribe(namespace, identifier string, engine storage.Engine) (<-chan struct{}, func()) {
+	p.Lock()
+	defer p.Unlock()
+
+	key := namespace + "/" + identifier
+
+	// Start polling on first subscription
+	sw, ok := p.watches[key]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		sw = &secretWatch{
+			subscribers: map[chan struct{}]struct{}{},
+			cancel:      cancel,
+		}
+		p.watches[key] = sw
+		go p.poll(ctx, sw, identifier, engine)
+	}
+
+	// Notifications are coalesced, subscribers reload the secret anyway
+	ch := make(chan struct{}, 1)
+	sw.subscribers[ch] = struct{}{}
+
+	return ch, func() {
+		p.Lock()
+		defer p.Unlock()
+
+		delete(sw.subscribers, ch)
+		if len(sw.subscribers) == 0 {
+			sw.cancel()
+			delete(p.watches, key)
+		}
+	}
+}
+
+// poll notifies subscribers when the secret fingerprint changes.
+func (p *poller) poll(ctx context.Context, sw *secretWatch, identifier string, engine storage.Engine) {
+	ticks, stop := p.ticker(p.interval)
+	defer stop()
+
+	// Check immediately on engine change notification
+	changed := make(chan struct{}, 1)
+	if unregister, ok := storage.Notify(engine, func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}); ok {
+		defer unregister()
+	}
+
+	last := ""
+	for {
+		// Compute secret fingerprint
+		current, err := p.fingerprint(ctx, identifier, engine)
+		switch {
+		case err == nil:
+			if current != last {
+				last = current
+				p.notify(sw)
+			}
+		case errors.Is(err, context.Canceled):
+			return
+		default:
+			// Keep last state, subscribers are notified on next change
+			log.Bg().Debug("unable to poll watched secret", zap.Error(err), zap.String("path", identifier))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticks:
+		case <-changed:
+		}
+	}
+}
+
+// fingerprint returns a digest of the engine secret, or a deletion marker
+// when the secret is not found.
+func (p *poller) fingerprint(ctx context.Context, identifier string, engine storage.Engine) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.interval)
+	defer cancel()
+
+	// Retrieve secret from engine
+	secret, err := storage.GetWithMetadata(ctx, engine, identifier)
+	if errors.Is(err, storage.ErrSecretNotFound) {
+		return "deleted", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// Metadata changes are notified too
+	h := sha256.New()
+	for _, v := range [][]byte{secret.Value, []byte(secret.Metadata.Version), []byte(secret.Metadata.ContentType), []byte(secret.Metadata.Origin)} {
+		h.Write(v)
+		h.Write([]byte{0})
+	}
+
+	// No error
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (p *poller) notify(sw *secretWatch) {
+	p.Lock()
+	defer p.Unlock()
+
+	for ch := range sw.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
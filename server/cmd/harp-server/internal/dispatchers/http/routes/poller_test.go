@@ -0,0 +1,156 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package routes
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/elastic/harp-plugins/server/pkg/server/storage"
+)
+
+type countingEngine struct {
+	sync.Mutex
+	value []byte
+	calls int32
+}
+
+func (e *countingEngine) Get(_ context.Context, _ string) ([]byte, error) {
+	atomic.AddInt32(&e.calls, 1)
+
+	e.Lock()
+	defer e.Unlock()
+
+	if e.value == nil {
+		return nil, storage.ErrSecretNotFound
+	}
+	return e.value, nil
+}
+
+func (e *countingEngine) set(value []byte) {
+	e.Lock()
+	defer e.Unlock()
+	e.value = value
+}
+
+func expectNotification(t *testing.T, ch <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(time.Second):
+		t.Fatal("expected change notification")
+	}
+}
+
+func TestPoller_SharedSubscription(t *testing.T) {
+	engine := &countingEngine{value: []byte("v1")}
+	p := newPoller(10 * time.Millisecond)
+
+	first, unsubscribeFirst := p.subscribe("ns", "/app/db", engine)
+	second, unsubscribeSecond := p.subscribe("ns", "/app/db", engine)
+
+	// Initial state is notified
+	expectNotification(t, first)
+	expectNotification(t, second)
+
+	// Both subscribers are notified from a single poll loop
+	engine.set([]byte("v2"))
+	expectNotification(t, first)
+	expectNotification(t, second)
+
+	// Deletion is notified
+	engine.set(nil)
+	expectNotification(t, first)
+	expectNotification(t, second)
+
+	unsubscribeFirst()
+	unsubscribeSecond()
+
+	// Polling stops with the last subscriber
+	p.Lock()
+	count := len(p.watches)
+	p.Unlock()
+	if count != 0 {
+		t.Fatalf("expected no active watch, got %d", count)
+	}
+	time.Sleep(30 * time.Millisecond)
+	calls := atomic.LoadInt32(&engine.calls)
+	time.Sleep(50 * time.Millisecond)
+	if got := atomic.LoadInt32(&engine.calls); got != calls {
+		t.Fatalf("expected polling to stop, got %d calls after unsubscription", got-calls)
+	}
+}
+
+func TestPoller_SinglePollLoop(t *testing.T) {
+	engine := &countingEngine{value: []byte("v0")}
+	ticks := make(chan time.Time)
+	p := newPoller(time.Hour)
+	p.ticker = func(time.Duration) (<-chan time.Time, func()) {
+		return ticks, func() {}
+	}
+
+	first, unsubscribe := p.subscribe("ns", "/app/db", engine)
+	defer unsubscribe()
+	for i := 0; i < 9; i++ {
+		_, unsubscribe := p.subscribe("ns", "/app/db", engine)
+		defer unsubscribe()
+	}
+	expectNotification(t, first)
+
+	// Each tick triggers a single poll, whatever the subscriber count
+	for i := 1; i <= 3; i++ {
+		engine.set([]byte(fmt.Sprintf("v%d", i)))
+		ticks <- time.Now()
+		expectNotification(t, first)
+	}
+	if calls := atomic.LoadInt32(&engine.calls); calls != 4 {
+		t.Fatalf("expected a shared poll loop, got %d engine calls", calls)
+	}
+}
+
+type notifyingEngine struct {
+	countingEngine
+	listener chan func()
+}
+
+func (e *notifyingEngine) Notify(fn func()) func() {
+	e.listener <- fn
+	return func() {}
+}
+
+func TestPoller_EngineNotification(t *testing.T) {
+	engine := &notifyingEngine{
+		countingEngine: countingEngine{value: []byte("v1")},
+		listener:       make(chan func(), 1),
+	}
+	p := newPoller(time.Hour)
+
+	changes, unsubscribe := p.subscribe("ns", "/app/db", engine)
+	defer unsubscribe()
+	expectNotification(t, changes)
+
+	// Change is checked on engine notification, without waiting for next poll
+	notify := <-engine.listener
+	engine.set([]byte("v2"))
+	notify()
+	expectNotification(t, changes)
+}
@@ -21,14 +21,13 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"strings"
 
 	"github.com/go-chi/chi"
-	"github.com/gosimple/slug"
 	"go.uber.org/zap"
 
 	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/config"
 	"github.com/elastic/harp-plugins/server/pkg/server/manager"
+	"github.com/elastic/harp-plugins/server/pkg/server/namespace"
 	"github.com/elastic/harp/pkg/sdk/log"
 )
 
@@ -36,6 +35,15 @@ import (
 func Backends(ctx context.Context, cfg *config.Configuration, bm manager.Backend) (http.Handler, error) {
 	r := chi.NewRouter()
 
+	// Prepare cache policies
+	policies, err := cachePolicies(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prepare change notification policy
+	watch := newWatchPolicy(cfg)
+
 	// Backends
 	for _, b := range cfg.Backends {
 		// Retrieve backend engine
@@ -45,9 +53,12 @@ func Backends(ctx context.Context, cfg *config.Configuration, bm manager.Backend
 		}
 
 		// Wrap engine with handler
-		ns := clean(b.NS)
+		ns, err := namespace.Parse(b.NS)
+		if err != nil {
+			return nil, fmt.Errorf("unable to route '%s' namespace: %w", b.NS, err)
+		}
 		r.Route(fmt.Sprintf("/%s", ns), func(r chi.Router) {
-			r.Get("/*", backend(ns, engine))
+			r.Get("/*", backend(ns, engine, policies[ns], watch))
 		})
 
 		log.For(ctx).Info("Bakend registered", zap.String("path", b.NS))
@@ -56,10 +67,3 @@ func Backends(ctx context.Context, cfg *config.Configuration, bm manager.Backend
 	// Return no error
 	return r, nil
 }
-
-func clean(ns string) string {
-	// Remove any starting "/"
-	ns = strings.TrimPrefix(ns, "/")
-	// Slugify
-	return slug.Make(ns)
-}
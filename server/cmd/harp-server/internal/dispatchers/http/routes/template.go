@@ -0,0 +1,417 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package routes
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/go-chi/chi"
+	"github.com/gobwas/glob"
+	"go.uber.org/zap"
+
+	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/config"
+	"github.com/elastic/harp-plugins/server/pkg/server/format"
+	"github.com/elastic/harp-plugins/server/pkg/server/manager"
+	"github.com/elastic/harp-plugins/server/pkg/server/namespace"
+	"github.com/elastic/harp-plugins/server/pkg/server/ratelimit"
+	"github.com/elastic/harp-plugins/server/pkg/server/storage"
+	"github.com/elastic/harp-plugins/server/pkg/server/storage/backends/container"
+	"github.com/elastic/harp/pkg/sdk/log"
+	"github.com/elastic/harp/pkg/template/engine"
+)
+
+// Limit posted template size to 1Mb
+const maxTemplateSize = 1048576
+
+// Limit rendered template size to 10Mb
+const maxRenderSize = 10 * 1048576
+
+// Limit indentation width of indent and nindent functions
+const maxIndentWidth = 256
+
+// errRenderTooLarge is raised when the rendered template exceeds the size limit.
+var errRenderTooLarge = errors.New("rendered template is too large")
+
+// Template functions available to templates. Functions exposing the server
+// host (environment, DNS), generating keys, or whose cost or output size is set
+// by the caller (custom passwords, repeat, until, seq, random strings, key
+// derivation) are not listed.
+var allowedTemplateFuncs = []string{
+	// Strings
+	"abbrev", "abbrevboth", "camelcase", "cat", "contains", "hasPrefix",
+	"hasSuffix", "initials", "kebabcase", "lower", "nospace", "plural",
+	"quote", "replace", "snakecase", "split", "splitList", "splitn", "squote",
+	"substr", "swapcase", "title", "toString", "toStrings", "trim", "trimAll",
+	"trimPrefix", "trimSuffix", "trimall", "trunc", "untitle", "upper", "wrap",
+	"regexFind", "regexFindAll", "regexMatch", "regexQuoteMeta",
+	"regexReplaceAll", "regexReplaceAllLiteral", "regexSplit", "shellEscape",
+	"jsonEscape", "jsonUnescape", "unquote",
+	// Conversions and math
+	"add", "add1", "add1f", "addf", "atoi", "ceil", "div", "divf", "float64",
+	"floor", "int", "int64", "max", "maxf", "min", "minf", "mod", "mul", "mulf",
+	"round", "sub", "subf", "toDecimal",
+	// Lists and dictionaries
+	"append", "chunk", "compact", "concat", "dict", "dig", "first", "get",
+	"has", "hasKey", "initial", "join", "keys", "last", "list", "merge",
+	"mergeOverwrite", "omit", "pick", "pluck", "prepend", "push", "rest",
+	"reverse", "set", "slice", "sortAlpha", "tuple", "uniq", "unset", "values",
+	"without",
+	// Flow control and reflection
+	"all", "any", "coalesce", "default", "empty", "fail", "ternary", "deepEqual",
+	"kindIs", "kindOf", "typeIs", "typeIsLike", "typeOf",
+	// Dates
+	"ago", "date", "dateInZone", "dateModify", "duration", "durationRound",
+	"htmlDate", "htmlDateInZone", "now", "toDate", "unixEpoch",
+	// Encoding
+	"b32dec", "b32enc", "b64dec", "b64enc", "b64urldec", "b64urlenc",
+	"bech32dec", "bech32enc", "hexdec", "hexenc", "fromJson", "fromJsonArray",
+	"fromYaml", "fromYamlArray", "toJson", "toPrettyJson", "toRawJson",
+	"toToml", "toYaml", "urlJoin", "urlParse", "urlPathEscape",
+	"urlPathUnescape", "urlQueryEscape", "urlQueryUnescape",
+	// Paths
+	"base", "clean", "dir", "ext", "isAbs",
+	// Hashes and identifiers
+	"adler32sum", "sha1sum", "sha256sum", "uuidv4",
+	// Keys and certificates given by secrets
+	"fromJwk", "keyToBytes", "toJwk", "toPem", "toSSH", "toTLSA",
+	"parsePemCertificate", "parsePemCertificateBundle",
+	"parsePemCertificateRequest",
+	// Fixed size passwords
+	"noSymbolPassword", "strongPassword", "basicDiceware", "strongDiceware",
+	// Semantic versions
+	"semver", "semverCompare",
+}
+
+// Templates returns an HTTP router for template rendering.
+func Templates(ctx context.Context, cfg *config.Configuration, bm manager.Backend) (http.Handler, error) {
+	r := chi.NewRouter()
+
+	// Initialize controller
+	ctrl := &templateHandler{
+		bm:               bm,
+		strict:           cfg.HTTP.Templates.Strict,
+		namespaces:       map[string]struct{}{},
+		inlineNamespaces: map[string]struct{}{},
+	}
+
+	// Index backend namespaces to resolve template paths
+	for _, b := range cfg.Backends {
+		ns, err := namespace.Parse(b.NS)
+		if err != nil {
+			return nil, fmt.Errorf("invalid backend namespace: %w", err)
+		}
+		ctrl.namespaces[ns] = struct{}{}
+	}
+
+	// Compile template policies
+	policies, inlineNamespaces, err := templatePolicies(cfg)
+	if err != nil {
+		return nil, err
+	}
+	ctrl.policies = policies
+
+	// Map routes
+	r.Get("/*", ctrl.renderStored())
+	if cfg.HTTP.Templates.AllowInline {
+		ctrl.inlineNamespaces = inlineNamespaces
+		r.Post("/", ctrl.renderInline())
+	}
+
+	log.For(ctx).Info("Template rendering enabled", zap.Bool("inline", cfg.HTTP.Templates.AllowInline))
+
+	// Return no error
+	return r, nil
+}
+
+// CheckTemplates validates template rendering settings, as done when the
+// template router is built.
+func CheckTemplates(cfg *config.Configuration) error {
+	_, _, err := templatePolicies(cfg)
+	return err
+}
+
+// templatePolicies compiles stored template policies and resolves inline
+// template readable namespaces.
+func templatePolicies(cfg *config.Configuration) ([]*templatePolicy, map[string]struct{}, error) {
+	policies := []*templatePolicy{}
+	for _, p := range cfg.HTTP.Templates.Policies {
+		g, err := glob.Compile(strings.Trim(p.Template, "/"), '/')
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to compile template policy selector '%s': %w", p.Template, err)
+		}
+		namespaces, err := namespaceSet(p.Namespaces)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid template policy '%s' namespace: %w", p.Template, err)
+		}
+		policies = append(policies, &templatePolicy{
+			selector:   g,
+			namespaces: namespaces,
+		})
+	}
+
+	// Inline templates
+	inlineNamespaces := map[string]struct{}{}
+	if cfg.HTTP.Templates.AllowInline {
+		namespaces, err := namespaceSet(cfg.HTTP.Templates.InlineNamespaces)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid inline template namespace: %w", err)
+		}
+		inlineNamespaces = namespaces
+	}
+
+	// No error
+	return policies, inlineNamespaces, nil
+}
+
+type templatePolicy struct {
+	selector   glob.Glob
+	namespaces map[string]struct{}
+}
+
+type templateHandler struct {
+	bm               manager.Backend
+	strict           bool
+	policies         []*templatePolicy
+	namespaces       map[string]struct{}
+	inlineNamespaces map[string]struct{}
+}
+
+func (h *templateHandler) renderStored() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		// Resolve the most nested namespace prefixing the template path
+		ns, p, ok := namespace.Longest(h.namespaces, chi.URLParam(r, "*"))
+		if !ok {
+			http.Error(w, "template not found", http.StatusNotFound)
+			return
+		}
+
+		// Retrieve template content from namespace
+		tmpl, err := h.bm.GetSecret(ctx, ns, fmt.Sprintf("/%s", p))
+		if errors.Is(err, storage.ErrSecretNotFound) || errors.Is(err, manager.ErrNamespaceNotFound) {
+			http.Error(w, "template not found", http.StatusNotFound)
+			return
+		}
+		if ratelimit.WriteError(w, err) {
+			return
+		}
+		if err != nil {
+			log.For(ctx).Error("unable to retrieve template from engine", zap.Error(err), zap.String("ns", ns), zap.String("path", p))
+			http.Error(w, "unable to retrieve template", http.StatusBadRequest)
+			return
+		}
+
+		// Resolve readable namespaces
+		allowed := map[string]struct{}{}
+		for _, policy := range h.policies {
+			if policy.selector.Match(fmt.Sprintf("%s/%s", ns, strings.Trim(p, "/"))) {
+				for n := range policy.namespaces {
+					allowed[n] = struct{}{}
+				}
+			}
+		}
+
+		h.render(w, r, p, string(tmpl), allowed)
+	}
+}
+
+func (h *templateHandler) renderInline() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Drain template from body
+		tmpl, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxTemplateSize))
+		if err != nil {
+			http.Error(w, "unable to read template", http.StatusBadRequest)
+			return
+		}
+
+		h.render(w, r, "", string(tmpl), h.inlineNamespaces)
+	}
+}
+
+func (h *templateHandler) render(w http.ResponseWriter, r *http.Request, name, tmpl string, allowed map[string]struct{}) {
+	ctx := r.Context()
+
+	// Strict mode could be overridden by query
+	strict := h.strict
+	switch r.URL.Query().Get("strict") {
+	case "true":
+		strict = true
+	case "false":
+		strict = false
+	}
+
+	// Prepare the template
+	funcs := templateFuncs()
+	funcs["secret"] = h.secretReader(ctx, allowed, strict)
+	t, err := template.New("template").Funcs(funcs).Parse(tmpl)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("unable to compile template: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Check strict mode
+	if strict {
+		t.Option("missingkey=error")
+	} else {
+		t.Option("missingkey=zero")
+	}
+
+	// Render template
+	out := &limitedBuffer{limit: maxRenderSize}
+	if err := t.Execute(out, map[string]interface{}{
+		"Values": queryValues(r),
+	}); err != nil {
+		if ratelimit.WriteError(w, err) {
+			return
+		}
+		log.For(ctx).Debug("unable to render template", zap.Error(err), zap.String("name", name))
+		http.Error(w, "unable to render template", http.StatusUnprocessableEntity)
+		return
+	}
+
+	// Detect content type from template name
+	contentType := "text/plain; charset=utf-8"
+	if f, ok := format.FromPath(name); ok {
+		contentType = format.ContentType(f)
+	}
+
+	// Send result
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(out.Bytes())
+	log.CheckErrCtx(ctx, "Unable to write response", err)
+}
+
+// templateFuncs returns the allowed template functions.
+func templateFuncs() template.FuncMap {
+	all := engine.FuncMap(nil)
+
+	funcs := template.FuncMap{}
+	for _, name := range allowedTemplateFuncs {
+		if fn, ok := all[name]; ok {
+			funcs[name] = fn
+		}
+	}
+
+	// Bounded indentation
+	funcs["indent"] = indent
+	funcs["nindent"] = func(width int, v string) (string, error) {
+		indented, err := indent(width, v)
+		return "\n" + indented, err
+	}
+
+	return funcs
+}
+
+// indent pads each line of the given value, the width is bounded.
+func indent(width int, v string) (string, error) {
+	if width < 0 || width > maxIndentWidth {
+		return "", fmt.Errorf("indentation width must be between 0 and %d", maxIndentWidth)
+	}
+
+	pad := strings.Repeat(" ", width)
+	return pad + strings.ReplaceAll(v, "\n", "\n"+pad), nil
+}
+
+// limitedBuffer is a buffer rejecting writes beyond the given limit.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.limit {
+		return 0, errRenderTooLarge
+	}
+	return b.Buffer.Write(p)
+}
+
+// secretReader returns the `secret "ns" "path"` template function.
+func (h *templateHandler) secretReader(ctx context.Context, allowed map[string]struct{}, strict bool) func(string, string) (map[string]interface{}, error) {
+	return func(ns, p string) (map[string]interface{}, error) {
+		// Check namespace access
+		id, err := namespace.Parse(ns)
+		if _, ok := allowed[id]; err != nil || !ok {
+			return nil, fmt.Errorf("namespace '%s' is not readable by this template", ns)
+		}
+
+		// Retrieve secret
+		secret, err := h.bm.GetSecretWithMetadata(ctx, ns, fmt.Sprintf("/%s", strings.TrimPrefix(p, "/")))
+		if err != nil {
+			if !strict && errors.Is(err, storage.ErrSecretNotFound) {
+				return map[string]interface{}{}, nil
+			}
+			if errors.Is(err, ratelimit.ErrLimitExceeded) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("unable to retrieve secret '%s' from '%s' namespace", p, ns)
+		}
+
+		// Expose container packages as documents
+		secret, err = container.JSON(secret)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode secret '%s' from '%s' namespace", p, ns)
+		}
+
+		// Decode secret
+		source, _ := format.FromPath(p)
+		data, err := format.Decode(secret.Value, source)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode secret '%s' from '%s' namespace", p, ns)
+		}
+
+		// No error
+		return data, nil
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+func namespaceSet(namespaces []string) (map[string]struct{}, error) {
+	res := map[string]struct{}{}
+	for _, ns := range namespaces {
+		id, err := namespace.Parse(ns)
+		if err != nil {
+			return nil, err
+		}
+		res[id] = struct{}{}
+	}
+
+	// No error
+	return res, nil
+}
+
+func queryValues(r *http.Request) map[string]string {
+	res := map[string]string{}
+	for k, v := range r.URL.Query() {
+		if k == "strict" || len(v) == 0 {
+			continue
+		}
+		res[k] = v[0]
+	}
+
+	return res
+}
@@ -0,0 +1,120 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func renderInline(t *testing.T, tmpl, query string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	h := &templateHandler{
+		inlineNamespaces: map[string]struct{}{},
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/?"+query, strings.NewReader(tmpl))
+	rec := httptest.NewRecorder()
+	h.renderInline().ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestRender_DeniedFunctions(t *testing.T) {
+	for _, tmpl := range []string{
+		`{{ env "HOME" }}`,
+		`{{ expandenv "$HOME" }}`,
+		`{{ getHostByName "localhost" }}`,
+		`{{ cryptoPair "ec:p256" }}`,
+		`{{ paranoidPassword }}`,
+		`{{ customPassword 1000000 10 10 false false }}`,
+		`{{ customDiceware 1000000 }}`,
+		`{{ repeat 1000000000 "a" }}`,
+		`{{ range until 1000000000 }}{{ end }}`,
+		`{{ range untilStep 0 1000000000 1 }}{{ end }}`,
+		`{{ encryptPem "key" "password" }}`,
+		`{{ randAlpha 1000000000 }}`,
+	} {
+		rec := renderInline(t, tmpl, "")
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", tmpl, http.StatusBadRequest, rec.Code)
+		}
+		if !strings.Contains(rec.Body.String(), "not defined") {
+			t.Errorf("%s: expected undefined function error, got %q", tmpl, rec.Body.String())
+		}
+	}
+}
+
+func TestRender_MissingValues(t *testing.T) {
+	rec := renderInline(t, `a={{ .Values.a }} b={{ .Values.b }} c=<no value>`, "strict=false&a=1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if got, want := rec.Body.String(), "a=1 b= c=<no value>"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestRender_StrictMissingValues(t *testing.T) {
+	rec := renderInline(t, `{{ .Values.b }}`, "strict=true")
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, rec.Code)
+	}
+}
+
+func TestRender_AllowedFunctions(t *testing.T) {
+	rec := renderInline(t, `{{ "abc" | upper }}:{{ list "a" "b" | join "," }}:{{ "a\nb" | nindent 2 }}`, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if got, want := rec.Body.String(), "ABC:a,b:\n  a\n  b"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestRender_BoundedIndent(t *testing.T) {
+	rec := renderInline(t, `{{ indent 1000000000 "a" }}`, "")
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, rec.Code)
+	}
+}
+
+func TestRender_OutputLimit(t *testing.T) {
+	// 1Kb line rendered 16k times
+	line := strings.Repeat("a", 1024)
+	rec := renderInline(t, `{{ range $i := list 1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16 }}{{ range $j := list 1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16 }}{{ range $k := list 1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16 }}{{ range $l := list 1 2 3 4 }}`+line+`{{ end }}{{ end }}{{ end }}{{ end }}`, "")
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status %d, got %d", http.StatusUnprocessableEntity, rec.Code)
+	}
+	if got, want := strings.TrimSpace(rec.Body.String()), "unable to render template"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestRender_GenericError(t *testing.T) {
+	rec := renderInline(t, `{{ fail "internal detail" }}`, "")
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status %d, got %d", http.StatusUnprocessableEntity, rec.Code)
+	}
+	if strings.Contains(rec.Body.String(), "internal detail") {
+		t.Errorf("render error must not be echoed, got %q", rec.Body.String())
+	}
+}
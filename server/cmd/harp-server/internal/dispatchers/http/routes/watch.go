@@ -0,0 +1,260 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package routes
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/config"
+	"github.com/elastic/harp-plugins/server/pkg/server/ratelimit"
+	"github.com/elastic/harp-plugins/server/pkg/server/storage"
+	"github.com/elastic/harp/pkg/sdk/log"
+)
+
+// watchPolicy defines change notification settings.
+type watchPolicy struct {
+	interval  time.Duration
+	maxWait   time.Duration
+	heartbeat time.Duration
+	poller    *poller
+}
+
+// loaderFunc returns the current secret representation.
+type loaderFunc func(ctx context.Context) (*representation, error)
+
+// watchEvent describes a secret change.
+type watchEvent struct {
+	Path         string `json:"path"`
+	ETag         string `json:"etag,omitempty"`
+	Version      string `json:"version,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	Envelope     string `json:"envelope,omitempty"`
+	Value        string `json:"value,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+func newWatchPolicy(cfg *config.Configuration) *watchPolicy {
+	if !cfg.HTTP.Watch.Enabled {
+		return nil
+	}
+
+	interval := durationOrDefault(cfg.HTTP.Watch.Interval, 5*time.Second)
+
+	return &watchPolicy{
+		interval:  interval,
+		maxWait:   durationOrDefault(cfg.HTTP.Watch.MaxWait, 5*time.Minute),
+		heartbeat: durationOrDefault(cfg.HTTP.Watch.Heartbeat, 15*time.Second),
+		poller:    newPoller(interval),
+	}
+}
+
+// wait blocks until the representation differs from the given index or the
+// wait duration expires. The last known representation is returned.
+func (p *watchPolicy) wait(r *http.Request, namespace, identifier, index string, engine storage.Engine, current *representation, loader loaderFunc) (*representation, error) {
+	// Resolve wait duration
+	wait := p.maxWait
+	if raw := r.URL.Query().Get("wait"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return nil, &requestError{status: http.StatusBadRequest, message: "invalid wait duration", cause: err}
+		}
+		if d < wait {
+			wait = d
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), wait)
+	defer cancel()
+
+	// Subscribe to shared secret changes
+	changes, unsubscribe := p.poller.subscribe(namespace, identifier, engine)
+	defer unsubscribe()
+
+	for current.etag == index {
+		select {
+		case <-ctx.Done():
+			return current, nil
+		case <-changes:
+		}
+
+		// Refresh representation
+		next, err := loader(ctx)
+		switch {
+		case err == nil:
+			current = next
+		case errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ratelimit.ErrLimitExceeded):
+			// Retry on next change
+		default:
+			return nil, err
+		}
+	}
+
+	// No error
+	return current, nil
+}
+
+// stream sends change events using Server-Sent Events until the client
+// disconnects or the maximum stream duration expires.
+func (p *watchPolicy) stream(w http.ResponseWriter, r *http.Request, namespace, identifier string, engine storage.Engine, loader loaderFunc) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	var (
+		includeValue = r.URL.Query().Get("value") == "true"
+		last         = r.Header.Get("Last-Event-ID")
+	)
+
+	// Streams are bounded, clients reconnect using Last-Event-ID
+	ctx, cancel := context.WithTimeout(r.Context(), p.maxWait)
+	defer cancel()
+
+	// Subscribe to shared secret changes before the first check
+	changes, unsubscribe := p.poller.subscribe(namespace, identifier, engine)
+	defer unsubscribe()
+
+	// Start event stream
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(p.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		// Check current representation
+		rep, err := loader(ctx)
+		switch {
+		case err == nil:
+			if rep.etag != last {
+				err := sendChange(w, r, identifier, rep, includeValue)
+				var reqErr *requestError
+				switch {
+				case err == nil:
+					last = rep.etag
+				case errors.As(err, &reqErr):
+					// Representation can't be sent, notify the client
+					log.For(ctx).Error("unable to prepare change event", zap.Error(reqErr.cause), zap.String("path", r.URL.Path))
+					if err := sendEvent(w, "error", "", &watchEvent{Path: identifier, Error: reqErr.message}); err != nil {
+						log.For(ctx).Debug("unable to send error event", zap.Error(err), zap.String("path", r.URL.Path))
+						return
+					}
+				default:
+					log.For(ctx).Warn("unable to send change event", zap.Error(err), zap.String("path", r.URL.Path))
+					return
+				}
+			}
+		case errors.Is(err, storage.ErrSecretNotFound):
+			if last != "" {
+				if err := sendEvent(w, "deleted", "", &watchEvent{Path: identifier}); err != nil {
+					log.For(ctx).Debug("unable to send deletion event", zap.Error(err), zap.String("path", r.URL.Path))
+					return
+				}
+				last = ""
+			}
+		case errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled):
+			return
+		case errors.Is(err, ratelimit.ErrLimitExceeded):
+			// Retry on next change
+		default:
+			log.For(ctx).Error("unable to watch secret", zap.Error(err), zap.String("path", r.URL.Path))
+			if err := sendEvent(w, "error", "", &watchEvent{Path: identifier, Error: "unable to retrieve secret"}); err != nil {
+				log.For(ctx).Debug("unable to send error event", zap.Error(err), zap.String("path", r.URL.Path))
+				return
+			}
+		}
+		flusher.Flush()
+
+		// Wait for next change
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+					log.For(ctx).Debug("unable to send keepalive", zap.Error(err), zap.String("path", r.URL.Path))
+					return
+				}
+				flusher.Flush()
+			case <-changes:
+				break wait
+			}
+		}
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+func sendChange(w http.ResponseWriter, r *http.Request, identifier string, rep *representation, includeValue bool) error {
+	evt := &watchEvent{
+		Path:    identifier,
+		ETag:    rep.etag,
+		Version: rep.metadata.Version,
+	}
+	if !rep.metadata.Created.IsZero() {
+		evt.LastModified = rep.metadata.Created.UTC().Format(time.RFC3339)
+	}
+
+	// Value is only sent when explicitly requested
+	if includeValue {
+		secret, _, envelopeType, err := protect(r, rep)
+		if err != nil {
+			return err
+		}
+		evt.Envelope = string(envelopeType)
+		evt.Value = base64.StdEncoding.EncodeToString(secret)
+	}
+
+	return sendEvent(w, "change", rep.etag, evt)
+}
+
+func sendEvent(w http.ResponseWriter, name, id string, evt *watchEvent) error {
+	payload, err := json.Marshal(evt)
+	if err != nil {
+		return fmt.Errorf("unable to encode event: %w", err)
+	}
+
+	if id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, payload)
+	return err
+}
+
+func durationOrDefault(d, defaultValue time.Duration) time.Duration {
+	if d <= 0 {
+		return defaultValue
+	}
+
+	return d
+}
@@ -32,42 +32,36 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/config"
+	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/dispatchers/core"
 	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/dispatchers/http/routes"
 	"github.com/elastic/harp-plugins/server/pkg/server/manager"
-	"github.com/elastic/harp-plugins/server/pkg/server/storage/backends/container"
+	"github.com/elastic/harp-plugins/server/pkg/server/ratelimit"
 	"github.com/elastic/harp/pkg/sdk/log"
 	"github.com/elastic/harp/pkg/sdk/tlsconfig"
 )
 
-func backendManager(ctx context.Context, cfg *config.Configuration) (manager.Backend, error) {
-	// Initialize default manager
-	bm := manager.Default()
-
-	// Backends
-	for _, b := range cfg.Backends {
-		// Register namespace engine
-		if err := bm.Register(ctx, b.NS, b.URL); err != nil {
-			return nil, err
-		}
-	}
-
-	// No error
-	return bm, nil
-}
-
-func httpServer(ctx context.Context, cfg *config.Configuration, bm manager.Backend) (*http.Server, error) {
+func httpServer(ctx context.Context, cfg *config.Configuration, bm manager.Backend, l *ratelimit.Limiter) (*http.Server, error) {
 	r := chi.NewRouter()
 
 	// middleware stack
 	r.Use(middleware.RequestID)
+
+	// Apply client rate limits on the connection address, before RealIP
+	// rewrites it from client-controlled headers
+	if l != nil {
+		r.Use(ratelimit.Middleware(l))
+	}
+
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Recoverer)
 
-	// timeout before request cancelation
-	r.Use(middleware.Timeout(60 * time.Second))
-
-	// Apply container keyring
-	container.SetKeyring(cfg.Keyring)
+	// timeout before request cancelation, watch requests last up to maxWait
+	requestTimeout, writeTimeout := 60*time.Second, 5*time.Second
+	if cfg.HTTP.Watch.Enabled {
+		requestTimeout += cfg.HTTP.Watch.MaxWait
+		writeTimeout += cfg.HTTP.Watch.MaxWait
+	}
+	r.Use(middleware.Timeout(requestTimeout))
 
 	// API endpoint
 	backendRouter, err := routes.Backends(ctx, cfg, bm)
@@ -75,14 +69,26 @@ func httpServer(ctx context.Context, cfg *config.Configuration, bm manager.Backe
 		return nil, err
 	}
 
+	// Template rendering endpoint
+	var templateRouter http.Handler
+	if cfg.HTTP.Templates.Enabled {
+		templateRouter, err = routes.Templates(ctx, cfg, bm)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	r.Route("/api/v1", func(r chi.Router) {
+		if templateRouter != nil {
+			r.Mount("/_template", templateRouter)
+		}
 		r.Mount("/", http.StripPrefix("/api/v1", backendRouter))
 	})
 
 	// Assign router to server
 	server := &http.Server{
 		ReadTimeout:       5 * time.Second,
-		WriteTimeout:      5 * time.Second,
+		WriteTimeout:      writeTimeout,
 		IdleTimeout:       30 * time.Second,
 		ReadHeaderTimeout: 2 * time.Second,
 		Handler:           r,
@@ -122,7 +128,15 @@ func httpServer(ctx context.Context, cfg *config.Configuration, bm manager.Backe
 
 func setup(ctx context.Context, cfg *config.Configuration) (*http.Server, error) {
 	wire.Build(
-		backendManager,
+		core.RateLimiter,
+		core.BackendManager,
+		httpServer,
+	)
+	return &http.Server{}, nil
+}
+
+func setupWithBackend(ctx context.Context, cfg *config.Configuration, bm manager.Backend, l *ratelimit.Limiter) (*http.Server, error) {
+	wire.Build(
 		httpServer,
 	)
 	return &http.Server{}, nil
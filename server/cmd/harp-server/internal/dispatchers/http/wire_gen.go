@@ -10,9 +10,10 @@ import (
 	"context"
 	"crypto/tls"
 	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/config"
+	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/dispatchers/core"
 	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/dispatchers/http/routes"
 	"github.com/elastic/harp-plugins/server/pkg/server/manager"
-	"github.com/elastic/harp-plugins/server/pkg/server/storage/backends/container"
+	"github.com/elastic/harp-plugins/server/pkg/server/ratelimit"
 	"github.com/elastic/harp/pkg/sdk/log"
 	"github.com/elastic/harp/pkg/sdk/tlsconfig"
 	"github.com/go-chi/chi"
@@ -25,55 +26,72 @@ import (
 // Injectors from wire.go:
 
 func setup(ctx context.Context, cfg *config.Configuration) (*http.Server, error) {
-	backend, err := backendManager(ctx, cfg)
+	limiter, err := core.RateLimiter(cfg)
 	if err != nil {
 		return nil, err
 	}
-	server, err := httpServer(ctx, cfg, backend)
+	backend, err := core.BackendManager(ctx, cfg, limiter)
+	if err != nil {
+		return nil, err
+	}
+	server, err := httpServer(ctx, cfg, backend, limiter)
 	if err != nil {
 		return nil, err
 	}
 	return server, nil
 }
 
-// wire.go:
-
-func backendManager(ctx context.Context, cfg *config.Configuration) (manager.Backend, error) {
-
-	bm := manager.Default()
-
-	for _, b := range cfg.Backends {
-
-		if err := bm.Register(ctx, b.NS, b.URL); err != nil {
-			return nil, err
-		}
+func setupWithBackend(ctx context.Context, cfg *config.Configuration, bm manager.Backend, l *ratelimit.Limiter) (*http.Server, error) {
+	server, err := httpServer(ctx, cfg, bm, l)
+	if err != nil {
+		return nil, err
 	}
-
-	return bm, nil
+	return server, nil
 }
 
-func httpServer(ctx context.Context, cfg *config.Configuration, bm manager.Backend) (*http.Server, error) {
+// wire.go:
+
+func httpServer(ctx context.Context, cfg *config.Configuration, bm manager.Backend, l *ratelimit.Limiter) (*http.Server, error) {
 	r := chi.NewRouter()
 
 	r.Use(middleware.RequestID)
+
+	if l != nil {
+		r.Use(ratelimit.Middleware(l))
+	}
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Recoverer)
 
-	r.Use(middleware.Timeout(60 * time.Second))
-	container.SetKeyring(cfg.Keyring)
+	requestTimeout, writeTimeout := 60*time.Second, 5*time.Second
+	if cfg.HTTP.Watch.Enabled {
+		requestTimeout += cfg.HTTP.Watch.MaxWait
+		writeTimeout += cfg.HTTP.Watch.MaxWait
+	}
+	r.Use(middleware.Timeout(requestTimeout))
 
 	backendRouter, err := routes.Backends(ctx, cfg, bm)
 	if err != nil {
 		return nil, err
 	}
 
+	var templateRouter http.Handler
+	if cfg.HTTP.Templates.Enabled {
+		templateRouter, err = routes.Templates(ctx, cfg, bm)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	r.Route("/api/v1", func(r chi.Router) {
+		if templateRouter != nil {
+			r.Mount("/_template", templateRouter)
+		}
 		r.Mount("/", http.StripPrefix("/api/v1", backendRouter))
 	})
 
 	server := &http.Server{
 		ReadTimeout:       5 * time.Second,
-		WriteTimeout:      5 * time.Second,
+		WriteTimeout:      writeTimeout,
 		IdleTimeout:       30 * time.Second,
 		ReadHeaderTimeout: 2 * time.Second,
 		Handler:           r,
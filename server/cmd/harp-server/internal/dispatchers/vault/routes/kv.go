@@ -21,16 +21,29 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi"
-	"github.com/gosimple/slug"
 	"go.uber.org/zap"
 
+	"github.com/elastic/harp-plugins/server/pkg/server/format"
 	"github.com/elastic/harp-plugins/server/pkg/server/manager"
+	"github.com/elastic/harp-plugins/server/pkg/server/namespace"
+	"github.com/elastic/harp-plugins/server/pkg/server/ratelimit"
 	"github.com/elastic/harp-plugins/server/pkg/server/storage"
+	"github.com/elastic/harp-plugins/server/pkg/server/storage/backends/container"
 	"github.com/elastic/harp/pkg/sdk/log"
-	vpath "github.com/elastic/harp/pkg/vault/path"
+)
+
+const (
+	// namespaceHeader carries the Vault Enterprise namespace.
+	namespaceHeader = "X-Vault-Namespace"
+	// dataPath is the KV v2 data mount path.
+	dataPath = "/secret/data"
+	// mountsPath is the mount discovery path used by Vault clients.
+	mountsPath = "/sys/internal/ui/mounts"
 )
 
 // KVHandler initializes Vault KV API handler for given bundle
@@ -44,6 +57,9 @@ func KVHandler(r chi.Router, bm manager.Backend) {
 	r.Get("/v1/secret/config", ctrl.getConfig())
 	r.Get("/v1/sys/internal/ui/mounts/*", ctrl.getMount())
 	r.Get("/v1/secret/data/*", ctrl.getSecret())
+
+	// Namespace could also be given as request path prefix
+	r.Get("/v1/*", ctrl.getNested())
 }
 
 type vaultKVHandler struct {
@@ -78,30 +94,53 @@ func (h *vaultKVHandler) getSecret() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 
-		// Get namespace from headers
-		ns := slug.Make(r.Header.Get("X-Vault-Namespace"))
-		if ns == "" {
-			ns = "root"
+		// Split namespace path prefix and secret path
+		prefix, mount, p, ok := splitMountPath(r.URL.Path)
+		if !ok || mount != dataPath || p == "" {
+			http.Error(w, "unsupported path", http.StatusNotFound)
+			return
 		}
 
-		// Extract path
-		p := strings.TrimPrefix(r.URL.Path, "/v1/secret/data")
+		// Resolve namespace from header and path prefix
+		ns, err := namespace.Join(r.Header.Get(namespaceHeader), prefix)
+		if err != nil {
+			http.Error(w, "invalid namespace", http.StatusBadRequest)
+			return
+		}
 
 		// Retrieve secret from engine
-		secret, err := h.bm.GetSecret(ctx, vpath.SanitizePath(ns), p)
-		if errors.Is(err, storage.ErrSecretNotFound) {
+		secret, err := h.bm.GetSecretWithMetadata(ctx, ns, p)
+		if errors.Is(err, storage.ErrSecretNotFound) || errors.Is(err, manager.ErrNamespaceNotFound) {
 			http.Error(w, "secret not found", http.StatusNotFound)
 			return
 		}
+		if ratelimit.WriteError(w, err) {
+			return
+		}
 		if err != nil {
 			log.For(ctx).Error("unable to retrieve secret from engine", zap.Error(err), zap.String("url", r.URL.String()))
 			http.Error(w, "unable to retrieve secret", http.StatusBadRequest)
 			return
 		}
 
-		// Decode secret as JSON
-		var data interface{}
-		if err := json.Unmarshal(secret, &data); err != nil {
+		// Expose container packages as documents
+		secret, err = container.JSON(secret)
+		if err != nil {
+			log.For(ctx).Error("unable to decode package from engine", zap.Error(err), zap.String("url", r.URL.String()))
+			http.Error(w, "unable to decode secret", http.StatusBadRequest)
+			return
+		}
+
+		// Decode secret using path extension or format detection
+		data, err := decodeSecret(p, secret.Value)
+
+		// Single secret key values are exposed as a one field object
+		if idx := strings.Index(p, "#"); idx >= 0 {
+			if field, ok := fieldValue(&secret.Metadata, secret.Value); ok {
+				data, err = map[string]interface{}{p[idx+1:]: field}, nil
+			}
+		}
+		if err != nil {
 			log.For(ctx).Error("unable to decode secret from engine", zap.Error(err), zap.String("url", r.URL.String()))
 			http.Error(w, "unable to decode secret", http.StatusBadRequest)
 			return
@@ -112,9 +151,122 @@ func (h *vaultKVHandler) getSecret() http.HandlerFunc {
 			"data": &KV{
 				"data": data,
 			},
-			"metadata": &KV{
-				"version": "1",
-			},
+			"metadata": kvMetadata(&secret.Metadata),
 		})
 	}
 }
+
+func (h *vaultKVHandler) getNested() http.HandlerFunc {
+	getMount := h.getMount()
+	getSecret := h.getSecret()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, mount, _, ok := splitMountPath(r.URL.Path)
+		switch {
+		case ok && mount == mountsPath:
+			getMount(w, r)
+		case ok && mount == dataPath:
+			getSecret(w, r)
+		default:
+			http.Error(w, "unsupported path", http.StatusNotFound)
+		}
+	}
+}
+
+// splitMountPath returns the namespace path prefix, the mount and the path
+// relative to the mount of the given request path. Namespace names are parsed
+// first, the mount must follow them on a segment boundary.
+func splitMountPath(p string) (prefix, mount, rest string, ok bool) {
+	p = strings.TrimPrefix(p, "/v1")
+
+	for pos := 0; pos < len(p) && p[pos] == '/'; {
+		// Match mounts on segment boundary
+		for _, m := range []string{dataPath, mountsPath} {
+			if p[pos:] == m || strings.HasPrefix(p[pos:], m+"/") {
+				return p[:pos], m, p[pos+len(m):], true
+			}
+		}
+
+		// Otherwise the segment must be a namespace name
+		end := strings.Index(p[pos+1:], "/")
+		if end < 0 {
+			break
+		}
+		if _, err := namespace.Parse(p[pos+1 : pos+1+end]); err != nil {
+			break
+		}
+		pos += end + 1
+	}
+
+	return "", "", "", false
+}
+
+// decodeSecret decodes the secret as a key/value object, other JSON values
+// (arrays, scalars) are returned as-is.
+func decodeSecret(p string, value []byte) (interface{}, error) {
+	source, _ := format.FromPath(p)
+	data, err := format.Decode(value, source)
+	if err == nil {
+		return data, nil
+	}
+
+	// Fallback to generic JSON value
+	if source == "" || source == format.JSON {
+		var v interface{}
+		if errJSON := json.Unmarshal(value, &v); errJSON == nil {
+			return v, nil
+		}
+	}
+
+	return nil, err
+}
+
+// fieldValue returns the typed value of a single secret key. It returns false
+// if the value is an object, or if its content type is unknown.
+func fieldValue(meta *storage.Metadata, value []byte) (interface{}, bool) {
+	switch {
+	case strings.HasPrefix(meta.ContentType, "text/plain"):
+		return string(value), true
+	case strings.HasPrefix(meta.ContentType, "application/json"):
+		var v interface{}
+		if err := json.Unmarshal(value, &v); err != nil {
+			return nil, false
+		}
+		if _, isObject := v.(map[string]interface{}); isObject {
+			return nil, false
+		}
+		return v, true
+	default:
+	}
+
+	return nil, false
+}
+
+// kvMetadata returns a KV v2 metadata block from secret metadata.
+func kvMetadata(meta *storage.Metadata) *KV {
+	// Default to first version
+	version := 1
+	if v, err := strconv.Atoi(meta.Version); err == nil && v > 0 {
+		version = v
+	}
+
+	// Creation time is unknown for most engines
+	created := ""
+	if !meta.Created.IsZero() {
+		created = meta.Created.UTC().Format(time.RFC3339Nano)
+	}
+
+	// Vault returns null without custom metadata
+	var custom interface{}
+	if len(meta.CustomMetadata) > 0 {
+		custom = meta.CustomMetadata
+	}
+
+	return &KV{
+		"created_time":    created,
+		"custom_metadata": custom,
+		"deletion_time":   "",
+		"destroyed":       false,
+		"version":         version,
+	}
+}
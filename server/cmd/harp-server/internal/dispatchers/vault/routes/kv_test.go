@@ -0,0 +1,74 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package routes
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeSecret(t *testing.T) {
+	for _, tc := range []struct {
+		path    string
+		content string
+		want    interface{}
+	}{
+		{path: "/app/db", content: `{"user":"foo"}`, want: map[string]interface{}{"user": "foo"}},
+		{path: "/app/hosts", content: `["a","b"]`, want: []interface{}{"a", "b"}},
+		{path: "/app/port", content: `5432`, want: float64(5432)},
+		{path: "/app/hosts.json", content: `["a"]`, want: []interface{}{"a"}},
+		{path: "/app/db.env", content: "USER=foo\n", want: map[string]interface{}{"USER": "foo"}},
+	} {
+		got, err := decodeSecret(tc.path, []byte(tc.content))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.path, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %#v, want %#v", tc.path, got, tc.want)
+		}
+	}
+
+	if _, err := decodeSecret("/app/hosts.yaml", []byte(`["a"]`)); err == nil {
+		t.Error("expected error for non object YAML content")
+	}
+}
+
+func TestSplitMountPath(t *testing.T) {
+	for _, tc := range []struct {
+		path   string
+		prefix string
+		mount  string
+		rest   string
+		ok     bool
+	}{
+		{path: "/v1/secret/data/app/db", mount: dataPath, rest: "/app/db", ok: true},
+		{path: "/v1/team/app/secret/data/db", prefix: "/team/app", mount: dataPath, rest: "/db", ok: true},
+		{path: "/v1/team/sys/internal/ui/mounts/secret/data/db", prefix: "/team", mount: mountsPath, rest: "/secret/data/db", ok: true},
+		{path: "/v1/team/secret/data/sys/internal/ui/mounts/db", prefix: "/team", mount: dataPath, rest: "/sys/internal/ui/mounts/db", ok: true},
+		{path: "/v1/team/mysecret/data/db"},
+		{path: "/v1/team/secret/database/db"},
+		{path: "/v1/Team App/secret/data/db"},
+		{path: "/v1/team"},
+	} {
+		prefix, mount, rest, ok := splitMountPath(tc.path)
+		if prefix != tc.prefix || mount != tc.mount || rest != tc.rest || ok != tc.ok {
+			t.Errorf("%s: got (%q, %q, %q, %v), want (%q, %q, %q, %v)", tc.path, prefix, mount, rest, ok, tc.prefix, tc.mount, tc.rest, tc.ok)
+		}
+	}
+}
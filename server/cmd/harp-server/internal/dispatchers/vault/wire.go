@@ -34,32 +34,16 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/config"
+	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/dispatchers/core"
 	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/dispatchers/vault/routes"
 	"github.com/elastic/harp-plugins/server/pkg/server/manager"
-	"github.com/elastic/harp-plugins/server/pkg/server/storage/backends/container"
+	"github.com/elastic/harp-plugins/server/pkg/server/ratelimit"
 	"github.com/elastic/harp/pkg/sdk/log"
 	"github.com/elastic/harp/pkg/sdk/tlsconfig"
 	"github.com/elastic/harp/pkg/sdk/value"
 	"github.com/elastic/harp/pkg/sdk/value/encryption"
-	vpath "github.com/elastic/harp/pkg/vault/path"
 )
 
-func backendManager(ctx context.Context, cfg *config.Configuration) (manager.Backend, error) {
-	// Initialize default manager
-	bm := manager.Default()
-
-	// Backends
-	for _, b := range cfg.Backends {
-		// Register namespace engine
-		if err := bm.Register(ctx, vpath.SanitizePath(b.NS), b.URL); err != nil {
-			return nil, err
-		}
-	}
-
-	// No error
-	return bm, nil
-}
-
 type transformerMap map[string]value.Transformer
 
 func transformers(cfg *config.Configuration) (transformerMap, error) {
@@ -84,11 +68,18 @@ func transformers(cfg *config.Configuration) (transformerMap, error) {
 	return res, nil
 }
 
-func httpServer(ctx context.Context, cfg *config.Configuration, bm manager.Backend, tm transformerMap) (*http.Server, error) {
+func httpServer(ctx context.Context, cfg *config.Configuration, bm manager.Backend, tm transformerMap, l *ratelimit.Limiter) (*http.Server, error) {
 	r := chi.NewRouter()
 
 	// middleware stack
 	r.Use(middleware.RequestID)
+
+	// Apply client rate limits on the connection address, before RealIP
+	// rewrites it from client-controlled headers
+	if l != nil {
+		r.Use(ratelimit.Middleware(l))
+	}
+
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Recoverer)
 
@@ -103,9 +94,6 @@ func httpServer(ctx context.Context, cfg *config.Configuration, bm manager.Backe
 		routes.TransitHandler(r, name, t)
 	}
 
-	// Apply container keyring
-	container.SetKeyring(cfg.Keyring)
-
 	// Assign router to server
 	server := &http.Server{
 		Handler: r,
@@ -145,7 +133,16 @@ func httpServer(ctx context.Context, cfg *config.Configuration, bm manager.Backe
 
 func setup(ctx context.Context, cfg *config.Configuration) (*http.Server, error) {
 	wire.Build(
-		backendManager,
+		core.RateLimiter,
+		core.BackendManager,
+		transformers,
+		httpServer,
+	)
+	return &http.Server{}, nil
+}
+
+func setupWithBackend(ctx context.Context, cfg *config.Configuration, bm manager.Backend, l *ratelimit.Limiter) (*http.Server, error) {
+	wire.Build(
 		transformers,
 		httpServer,
 	)
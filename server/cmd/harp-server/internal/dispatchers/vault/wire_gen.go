@@ -11,14 +11,14 @@ import (
 	"crypto/tls"
 	"fmt"
 	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/config"
+	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/dispatchers/core"
 	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/dispatchers/vault/routes"
 	"github.com/elastic/harp-plugins/server/pkg/server/manager"
-	"github.com/elastic/harp-plugins/server/pkg/server/storage/backends/container"
+	"github.com/elastic/harp-plugins/server/pkg/server/ratelimit"
 	"github.com/elastic/harp/pkg/sdk/log"
 	"github.com/elastic/harp/pkg/sdk/tlsconfig"
 	"github.com/elastic/harp/pkg/sdk/value"
 	"github.com/elastic/harp/pkg/sdk/value/encryption"
-	"github.com/elastic/harp/pkg/vault/path"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 	"github.com/gosimple/slug"
@@ -30,7 +30,11 @@ import (
 // Injectors from wire.go:
 
 func setup(ctx context.Context, cfg *config.Configuration) (*http.Server, error) {
-	backend, err := backendManager(ctx, cfg)
+	limiter, err := core.RateLimiter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	backend, err := core.BackendManager(ctx, cfg, limiter)
 	if err != nil {
 		return nil, err
 	}
@@ -38,29 +42,27 @@ func setup(ctx context.Context, cfg *config.Configuration) (*http.Server, error)
 	if err != nil {
 		return nil, err
 	}
-	server, err := httpServer(ctx, cfg, backend, vaultTransformerMap)
+	server, err := httpServer(ctx, cfg, backend, vaultTransformerMap, limiter)
 	if err != nil {
 		return nil, err
 	}
 	return server, nil
 }
 
-// wire.go:
-
-func backendManager(ctx context.Context, cfg *config.Configuration) (manager.Backend, error) {
-
-	bm := manager.Default()
-
-	for _, b := range cfg.Backends {
-
-		if err := bm.Register(ctx, path.SanitizePath(b.NS), b.URL); err != nil {
-			return nil, err
-		}
+func setupWithBackend(ctx context.Context, cfg *config.Configuration, bm manager.Backend, l *ratelimit.Limiter) (*http.Server, error) {
+	vaultTransformerMap, err := transformers(cfg)
+	if err != nil {
+		return nil, err
 	}
-
-	return bm, nil
+	server, err := httpServer(ctx, cfg, bm, vaultTransformerMap, l)
+	if err != nil {
+		return nil, err
+	}
+	return server, nil
 }
 
+// wire.go:
+
 type transformerMap map[string]value.Transformer
 
 func transformers(cfg *config.Configuration) (transformerMap, error) {
@@ -83,10 +85,14 @@ func transformers(cfg *config.Configuration) (transformerMap, error) {
 	return res, nil
 }
 
-func httpServer(ctx context.Context, cfg *config.Configuration, bm manager.Backend, tm transformerMap) (*http.Server, error) {
+func httpServer(ctx context.Context, cfg *config.Configuration, bm manager.Backend, tm transformerMap, l *ratelimit.Limiter) (*http.Server, error) {
 	r := chi.NewRouter()
 
 	r.Use(middleware.RequestID)
+
+	if l != nil {
+		r.Use(ratelimit.Middleware(l))
+	}
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Recoverer)
 
@@ -97,7 +103,6 @@ func httpServer(ctx context.Context, cfg *config.Configuration, bm manager.Backe
 	for name, t := range tm {
 		routes.TransitHandler(r, name, t)
 	}
-	container.SetKeyring(cfg.Keyring)
 
 	server := &http.Server{
 		Handler: r,
@@ -19,6 +19,7 @@ package main
 
 import (
 	"math/rand"
+	"os"
 	"time"
 
 	"github.com/elastic/harp-plugins/server/cmd/harp-server/internal/cmd"
@@ -26,9 +27,13 @@ import (
 
 	// Register cloud storage
 	_ "github.com/elastic/harp-plugins/server/pkg/server/storage/backends/azblob"
+	_ "github.com/elastic/harp-plugins/server/pkg/server/storage/backends/composite"
 	_ "github.com/elastic/harp-plugins/server/pkg/server/storage/backends/container"
+	_ "github.com/elastic/harp-plugins/server/pkg/server/storage/backends/database"
+	_ "github.com/elastic/harp-plugins/server/pkg/server/storage/backends/env"
 	_ "github.com/elastic/harp-plugins/server/pkg/server/storage/backends/file"
 	_ "github.com/elastic/harp-plugins/server/pkg/server/storage/backends/gcs"
+	_ "github.com/elastic/harp-plugins/server/pkg/server/storage/backends/k8s"
 	_ "github.com/elastic/harp-plugins/server/pkg/server/storage/backends/s3"
 	_ "github.com/elastic/harp-plugins/server/pkg/server/storage/backends/vault"
 
@@ -51,5 +56,6 @@ func init() {
 func main() {
 	if err := cmd.Execute(); err != nil {
 		log.CheckErr("Unable to complete command execution", err)
+		os.Exit(1)
 	}
 }
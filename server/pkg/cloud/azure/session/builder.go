@@ -0,0 +1,302 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/Azure/go-autorest/autorest/adal"
+	"github.com/elastic/harp/pkg/sdk/log"
+	"go.uber.org/zap"
+)
+
+const (
+	// storageResource is the OAuth resource of Azure Storage.
+	storageResource = "https://storage.azure.com/"
+	// defaultAuthorityHost is the Azure public cloud identity provider.
+	defaultAuthorityHost = "https://login.microsoftonline.com/"
+	// defaultEndpointSuffix is the Azure public cloud storage endpoint suffix.
+	defaultEndpointSuffix = "core.windows.net"
+	// tokenRefreshMargin is the delay before token expiration to renew it.
+	tokenRefreshMargin = 5 * time.Minute
+	// tokenRetryDelay is the delay before retrying a failed token refresh.
+	tokenRetryDelay = 30 * time.Second
+)
+
+// Storage emulator well-known settings
+const (
+	emulatorAccountName  = "devstoreaccount1"
+	emulatorAccountKey   = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+	emulatorBlobEndpoint = "http://127.0.0.1:10000/devstoreaccount1"
+)
+
+// NewClient returns an Azure Blob Storage service client according to the
+// given authentication method. Tokens are refreshed automatically.
+func NewClient(opts *Options) (*azblob.ServiceURL, error) {
+	// Check arguments
+	if opts == nil {
+		return nil, errors.New("unable to build without options")
+	}
+
+	switch opts.Auth {
+	case "", AuthConnectionString:
+		if opts.ConnectionString == "" {
+			return nil, errors.New("AZURE_CONNECTION_STRING env. variable or connection-string parameter must be set for azblob backend")
+		}
+		client, err := connectionStringClient(opts.ConnectionString)
+		if err != nil {
+			return nil, fmt.Errorf("unable to initialize storage client: %w", err)
+		}
+		return client, nil
+	case AuthSAS:
+		if opts.AccountName == "" || opts.SASToken == "" {
+			return nil, errors.New("account and sas-token parameters are mandatory for sas authentication")
+		}
+		client, err := sasClient(opts.blobEndpoint(), opts.SASToken)
+		if err != nil {
+			return nil, fmt.Errorf("unable to initialize storage client: %w", err)
+		}
+		return client, nil
+	case AuthManagedIdentity:
+		token, err := adal.NewServicePrincipalTokenFromManagedIdentity(storageResource, &adal.ManagedIdentityOptions{
+			ClientID: opts.ClientID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to initialize managed identity: %w", err)
+		}
+		return opts.tokenClient(token)
+	case AuthWorkloadIdentity:
+		token, err := opts.federatedToken()
+		if err != nil {
+			return nil, fmt.Errorf("unable to initialize workload identity: %w", err)
+		}
+		return opts.tokenClient(token)
+	default:
+	}
+
+	return nil, fmt.Errorf("unsupported authentication method '%s'", opts.Auth)
+}
+
+// FromURL returns client options from URL parameters, environment variables
+// are used as default values.
+func FromURL(u string) (*Options, error) {
+	// Parse input as URL
+	input, err := url.Parse(u)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build session from url")
+	}
+
+	q := input.Query()
+
+	// Assemble options
+	opts := &Options{
+		Auth:               q.Get("auth"),
+		ConnectionString:   withEnv(q.Get("connection-string"), "AZURE_CONNECTION_STRING"),
+		AccountName:        withEnv(q.Get("account"), "AZURE_STORAGE_ACCOUNT"),
+		EndpointSuffix:     q.Get("endpoint-suffix"),
+		SASToken:           q.Get("sas-token"),
+		ClientID:           withEnv(q.Get("client-id"), "AZURE_CLIENT_ID"),
+		TenantID:           withEnv(q.Get("tenant-id"), "AZURE_TENANT_ID"),
+		FederatedTokenFile: withEnv(q.Get("federated-token-file"), "AZURE_FEDERATED_TOKEN_FILE"),
+		AuthorityHost:      withEnv(q.Get("authority-host"), "AZURE_AUTHORITY_HOST"),
+	}
+
+	// No error
+	return opts, nil
+}
+
+func withEnv(value, name string) string {
+	if value != "" {
+		return value
+	}
+	return os.Getenv(name)
+}
+
+// -----------------------------------------------------------------------------
+
+func (opts *Options) endpointSuffix() string {
+	if opts.EndpointSuffix != "" {
+		return opts.EndpointSuffix
+	}
+	return defaultEndpointSuffix
+}
+
+func (opts *Options) blobEndpoint() string {
+	return fmt.Sprintf("https://%s.blob.%s", opts.AccountName, opts.endpointSuffix())
+}
+
+// tokenClient returns a client authenticated using OAuth bearer tokens.
+func (opts *Options) tokenClient(token *adal.ServicePrincipalToken) (*azblob.ServiceURL, error) {
+	// Check arguments
+	if opts.AccountName == "" {
+		return nil, errors.New("account parameter is mandatory for token authentication")
+	}
+
+	endpoint, err := url.Parse(opts.blobEndpoint())
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse blob endpoint: %w", err)
+	}
+
+	// Token is acquired immediately and renewed before its expiration
+	credential := azblob.NewTokenCredential("", tokenRefresher(token))
+
+	return newServiceURL(endpoint, credential), nil
+}
+
+// federatedToken exchanges the federated token file content (Kubernetes
+// projected service account token) for an Azure AD access token.
+func (opts *Options) federatedToken() (*adal.ServicePrincipalToken, error) {
+	// Check arguments
+	if opts.ClientID == "" || opts.TenantID == "" || opts.FederatedTokenFile == "" {
+		return nil, errors.New("client-id, tenant-id and federated-token-file are mandatory")
+	}
+
+	authorityHost := opts.AuthorityHost
+	if authorityHost == "" {
+		authorityHost = defaultAuthorityHost
+	}
+	oauthConfig, err := adal.NewOAuthConfig(authorityHost, opts.TenantID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to prepare oauth configuration: %w", err)
+	}
+
+	// Expired initial token, acquired by the credential refresher
+	token, err := adal.NewServicePrincipalTokenFromManualToken(*oauthConfig, opts.ClientID, storageResource, adal.Token{
+		ExpiresOn: json.Number("0"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to prepare token: %w", err)
+	}
+	token.SetCustomRefreshFunc(func(ctx context.Context, resource string) (*adal.Token, error) {
+		// Token file is rotated by kubelet, read it on each refresh
+		assertion, err := os.ReadFile(opts.FederatedTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read federated token file: %w", err)
+		}
+
+		return exchange(ctx, oauthConfig, opts.ClientID, strings.TrimSpace(string(assertion)), resource)
+	})
+
+	return token, nil
+}
+
+// -----------------------------------------------------------------------------
+
+// tokenRefresher returns a token credential refresher renewing the given
+// service principal token.
+func tokenRefresher(token *adal.ServicePrincipalToken) azblob.TokenRefresher {
+	return func(credential azblob.TokenCredential) time.Duration {
+		if err := token.Refresh(); err != nil {
+			log.Bg().Error("azure: unable to refresh access token, retrying", zap.Error(err))
+			return tokenRetryDelay
+		}
+		credential.SetToken(token.OAuthToken())
+
+		// Schedule next refresh
+		current := token.Token()
+		next := time.Until(current.Expires()) - tokenRefreshMargin
+		if next < tokenRetryDelay {
+			next = tokenRetryDelay
+		}
+
+		return next
+	}
+}
+
+// connectionStringClient returns a client from an Azure Storage connection
+// string using shared key or SAS credentials.
+func connectionStringClient(connectionString string) (*azblob.ServiceURL, error) {
+	settings := map[string]string{}
+	for _, part := range strings.Split(connectionString, ";") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid connection string setting '%s'", kv[0])
+		}
+		settings[strings.ToLower(kv[0])] = kv[1]
+	}
+
+	// Storage emulator
+	if strings.EqualFold(settings["usedevelopmentstorage"], "true") {
+		settings["accountname"] = emulatorAccountName
+		settings["accountkey"] = emulatorAccountKey
+		settings["blobendpoint"] = emulatorBlobEndpoint
+	}
+
+	// Resolve blob endpoint
+	endpoint := settings["blobendpoint"]
+	if endpoint == "" {
+		if settings["accountname"] == "" {
+			return nil, errors.New("AccountName or BlobEndpoint setting is mandatory")
+		}
+		protocol := settings["defaultendpointsprotocol"]
+		if protocol == "" {
+			protocol = "https"
+		}
+		suffix := settings["endpointsuffix"]
+		if suffix == "" {
+			suffix = defaultEndpointSuffix
+		}
+		endpoint = fmt.Sprintf("%s://%s.blob.%s", protocol, settings["accountname"], suffix)
+	}
+
+	switch {
+	case settings["sharedaccesssignature"] != "":
+		return sasClient(endpoint, settings["sharedaccesssignature"])
+	case settings["accountname"] != "" && settings["accountkey"] != "":
+		credential, err := azblob.NewSharedKeyCredential(settings["accountname"], settings["accountkey"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid account key: %w", err)
+		}
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse blob endpoint: %w", err)
+		}
+		return newServiceURL(u, credential), nil
+	default:
+	}
+
+	return nil, errors.New("AccountKey or SharedAccessSignature setting is mandatory")
+}
+
+// sasClient returns a client authenticated by the SAS token appended to the
+// blob endpoint query.
+func sasClient(endpoint, sasToken string) (*azblob.ServiceURL, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse blob endpoint: %w", err)
+	}
+	u.RawQuery = strings.TrimPrefix(sasToken, "?")
+
+	return newServiceURL(u, azblob.NewAnonymousCredential()), nil
+}
+
+func newServiceURL(endpoint *url.URL, credential azblob.Credential) *azblob.ServiceURL {
+	serviceURL := azblob.NewServiceURL(*endpoint, azblob.NewPipeline(credential, azblob.PipelineOptions{}))
+	return &serviceURL
+}
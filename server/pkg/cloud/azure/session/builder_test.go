@@ -0,0 +1,102 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package session
+
+import (
+	"testing"
+)
+
+func TestNewClient_ConnectionString(t *testing.T) {
+	testCases := []struct {
+		name             string
+		connectionString string
+		wantURL          string
+		wantErr          bool
+	}{
+		{
+			name:             "account key",
+			connectionString: "DefaultEndpointsProtocol=https;AccountName=harp;AccountKey=c2VjcmV0;EndpointSuffix=core.chinacloudapi.cn",
+			wantURL:          "https://harp.blob.core.chinacloudapi.cn",
+		},
+		{
+			name:             "blob endpoint",
+			connectionString: "BlobEndpoint=http://127.0.0.1:10000/harp;AccountName=harp;AccountKey=c2VjcmV0",
+			wantURL:          "http://127.0.0.1:10000/harp",
+		},
+		{
+			name:             "shared access signature",
+			connectionString: "BlobEndpoint=https://harp.blob.core.windows.net;SharedAccessSignature=sv=2020-08-04&sig=abc",
+			wantURL:          "https://harp.blob.core.windows.net?sv=2020-08-04&sig=abc",
+		},
+		{
+			name:             "emulator",
+			connectionString: "UseDevelopmentStorage=true",
+			wantURL:          emulatorBlobEndpoint,
+		},
+		{
+			name:             "invalid account key",
+			connectionString: "AccountName=harp;AccountKey=not-base64!",
+			wantErr:          true,
+		},
+		{
+			name:             "missing credential",
+			connectionString: "AccountName=harp",
+			wantErr:          true,
+		},
+		{
+			name:             "invalid setting",
+			connectionString: "AccountName",
+			wantErr:          true,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewClient(&Options{
+				Auth:             AuthConnectionString,
+				ConnectionString: tc.connectionString,
+			})
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := client.String(); got != tc.wantURL {
+				t.Errorf("got url %q, want %q", got, tc.wantURL)
+			}
+		})
+	}
+}
+
+func TestNewClient_SAS(t *testing.T) {
+	client, err := NewClient(&Options{
+		Auth:        AuthSAS,
+		AccountName: "harp",
+		SASToken:    "?sv=2020-08-04&sig=abc",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := client.String(), "https://harp.blob.core.windows.net?sv=2020-08-04&sig=abc"; got != want {
+		t.Errorf("got url %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,90 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest/adal"
+)
+
+const clientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// exchange requests an access token using client credentials grant with the
+// federated token as client assertion.
+func exchange(ctx context.Context, oauthConfig *adal.OAuthConfig, clientID, assertion, resource string) (*adal.Token, error) {
+	// Prepare request
+	form := url.Values{
+		"grant_type":            []string{"client_credentials"},
+		"client_id":             []string{clientID},
+		"client_assertion_type": []string{clientAssertionType},
+		"client_assertion":      []string{assertion},
+		"resource":              []string{resource},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, oauthConfig.TokenEndpoint.String(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("unable to prepare token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	// Send request
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to request token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token request failed with status %d", resp.StatusCode)
+	}
+
+	// Decode response
+	var token struct {
+		AccessToken string      `json:"access_token"`
+		ExpiresIn   json.Number `json:"expires_in"`
+		TokenType   string      `json:"token_type"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("unable to decode token response: %w", err)
+	}
+	expiresIn, err := token.ExpiresIn.Int64()
+	if err != nil {
+		return nil, fmt.Errorf("invalid token expiration: %w", err)
+	}
+
+	// No error
+	return &adal.Token{
+		AccessToken: token.AccessToken,
+		ExpiresIn:   token.ExpiresIn,
+		ExpiresOn:   json.Number(strconv.FormatInt(time.Now().Add(time.Duration(expiresIn)*time.Second).Unix(), 10)),
+		Resource:    resource,
+		Type:        token.TokenType,
+	}, nil
+}
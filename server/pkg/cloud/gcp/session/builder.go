@@ -0,0 +1,103 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/impersonate"
+	"google.golang.org/api/option"
+)
+
+// NewClient returns a Google Cloud Storage client. Application default
+// credentials are used when no credentials file is given, tokens are
+// refreshed automatically. The token scope is read-write unless the client is
+// read-only.
+func NewClient(opts *Options) (*storage.Client, error) {
+	// Check arguments
+	if opts == nil {
+		return nil, errors.New("unable to build without options")
+	}
+
+	// Client lifetime context, used by token sources to refresh tokens
+	ctx := context.Background()
+
+	// Scope by operation
+	scope := storage.ScopeReadWrite
+	if opts.ReadOnly {
+		scope = storage.ScopeReadOnly
+	}
+
+	// Source credentials
+	clientOpts := []option.ClientOption{}
+	if opts.CredentialsFile != "" {
+		clientOpts = append(clientOpts, option.WithCredentialsFile(opts.CredentialsFile))
+	}
+
+	// Service account impersonation
+	if opts.ImpersonateServiceAccount != "" {
+		ts, err := impersonate.CredentialsTokenSource(ctx, impersonate.CredentialsConfig{
+			TargetPrincipal: opts.ImpersonateServiceAccount,
+			Delegates:       opts.ImpersonateDelegates,
+			Scopes:          []string{scope},
+		}, clientOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("unable to impersonate service account '%s': %w", opts.ImpersonateServiceAccount, err)
+		}
+		clientOpts = []option.ClientOption{option.WithTokenSource(ts)}
+	} else {
+		clientOpts = append(clientOpts, option.WithScopes(scope))
+	}
+
+	// Create a Google Storage client
+	client, err := storage.NewClient(ctx, clientOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize storage client: %w", err)
+	}
+
+	// No error
+	return client, nil
+}
+
+// FromURL returns client options from URL parameters.
+func FromURL(u string) (*Options, error) {
+	// Parse input as URL
+	input, err := url.Parse(u)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build session from url")
+	}
+
+	q := input.Query()
+
+	// Assemble options
+	opts := &Options{
+		CredentialsFile:           q.Get("credentials-file"),
+		ImpersonateServiceAccount: q.Get("impersonate-service-account"),
+	}
+	if delegates := q.Get("impersonate-delegates"); delegates != "" {
+		opts.ImpersonateDelegates = strings.Split(delegates, ",")
+	}
+
+	// No error
+	return opts, nil
+}
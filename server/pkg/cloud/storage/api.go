@@ -19,29 +19,164 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
+var (
+	// ErrNotFound is raised when the object (or object version) doesn't exist.
+	ErrNotFound = errors.New("storage: object not found")
+	// ErrNotModified is raised by conditional get when the object matches the
+	// given If-None-Match entity tag.
+	ErrNotModified = errors.New("storage: object not modified")
+	// ErrPreconditionFailed is raised by conditional get when the object
+	// doesn't match the given If-Match entity tag.
+	ErrPreconditionFailed = errors.New("storage: object precondition failed")
+)
+
 // -----------------------------------------------------------------------------
 
 // Backend is a generic interface for storage backends
 type Backend interface {
+	// GetObject retrieves the latest object version.
 	GetObject(ctx context.Context, path string) (*Object, error)
+	// GetObjectWithOptions retrieves an object using version or entity tag
+	// conditions.
+	GetObjectWithOptions(ctx context.Context, path string, opts *GetOptions) (*Object, error)
+	// StatObject retrieves object attributes without content.
+	StatObject(ctx context.Context, path string) (*ObjectInfo, error)
+	// ListObjects enumerates objects attributes matching the given options.
+	ListObjects(ctx context.Context, opts *ListOptions) (*ListResult, error)
+	// PutObject creates or replaces an object.
+	PutObject(ctx context.Context, path string, content io.Reader, opts *PutOptions) (*ObjectInfo, error)
+	// DeleteObject removes an object.
+	DeleteObject(ctx context.Context, path string) error
 }
 
 // -----------------------------------------------------------------------------
 
+// ObjectInfo describes storage object attributes
+type ObjectInfo struct {
+	Path         string
+	Size         int64
+	ETag         string
+	VersionID    string
+	ContentType  string
+	LastModified time.Time
+	Metadata     map[string]string
+}
+
 // Object is a generic representation of a storage object
 type Object struct {
 	Path         string
 	Content      io.ReadCloser
 	LastModified time.Time
+	Size         int64
+	ETag         string
+	VersionID    string
+	ContentType  string
+	Metadata     map[string]string
+}
+
+// Info returns the object attributes.
+func (object *Object) Info() *ObjectInfo {
+	return &ObjectInfo{
+		Path:         object.Path,
+		Size:         object.Size,
+		ETag:         object.ETag,
+		VersionID:    object.VersionID,
+		ContentType:  object.ContentType,
+		LastModified: object.LastModified,
+		Metadata:     object.Metadata,
+	}
 }
 
 // HasExtension determines whether or not an object contains a file extension
 func (object *Object) HasExtension(extension string) bool {
 	return filepath.Ext(object.Path) == fmt.Sprintf(".%s", extension)
 }
+
+// GetOptions defines object retrieval conditions
+type GetOptions struct {
+	// VersionID pins the object version.
+	VersionID string
+	// IfMatch requires the object entity tag to match.
+	IfMatch string
+	// IfNoneMatch requires the object entity tag to differ.
+	IfNoneMatch string
+}
+
+// PutOptions defines object creation settings
+type PutOptions struct {
+	ContentType string
+	Metadata    map[string]string
+}
+
+// ListOptions defines object enumeration settings
+type ListOptions struct {
+	// Prefix filters object paths.
+	Prefix string
+	// Delimiter groups paths sharing the same prefix up to the delimiter as
+	// common prefixes.
+	Delimiter string
+	// MaxKeys limits the page size (backend default if 0).
+	MaxKeys int
+	// ContinuationToken is the NextContinuationToken of the previous page.
+	ContinuationToken string
+}
+
+// ListResult is an object enumeration page
+type ListResult struct {
+	Objects               []*ObjectInfo
+	CommonPrefixes        []string
+	NextContinuationToken string
+}
+
+// -----------------------------------------------------------------------------
+
+// checkConditions evaluates get conditions against object attributes, for
+// backends without native entity tag conditions.
+func checkConditions(info *ObjectInfo, opts *GetOptions) error {
+	if opts == nil {
+		return nil
+	}
+	if opts.IfMatch != "" && !sameETag(opts.IfMatch, info.ETag) {
+		return ErrPreconditionFailed
+	}
+	if opts.IfNoneMatch != "" && sameETag(opts.IfNoneMatch, info.ETag) {
+		return ErrNotModified
+	}
+
+	return nil
+}
+
+// newObject assembles an object from its attributes and content.
+func newObject(info *ObjectInfo, content io.ReadCloser) *Object {
+	return &Object{
+		Path:         info.Path,
+		Content:      content,
+		LastModified: info.LastModified,
+		Size:         info.Size,
+		ETag:         info.ETag,
+		VersionID:    info.VersionID,
+		ContentType:  info.ContentType,
+		Metadata:     info.Metadata,
+	}
+}
+
+func sameETag(a, b string) bool {
+	return strings.Trim(a, `"`) == strings.Trim(b, `"`)
+}
+
+// relativePath returns the object path relative to backend prefix.
+func relativePath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+
+	return strings.TrimPrefix(strings.TrimPrefix(key, strings.TrimSuffix(prefix, "/")), "/")
+}
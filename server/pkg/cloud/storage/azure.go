@@ -21,18 +21,20 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	pathutil "path"
 	"time"
 
-	msstorage "github.com/Azure/azure-sdk-for-go/storage"
+	"github.com/Azure/azure-storage-blob-go/azblob"
 )
 
 // AzureBlob Backend object storage manager
-func AzureBlob(client *msstorage.Client, bucket, prefix string) Backend {
+func AzureBlob(service *azblob.ServiceURL, bucket, prefix string) Backend {
 	return &msAzureBlobBackend{
-		client: client,
-		bucket: bucket,
-		prefix: prefix,
+		service: service,
+		bucket:  bucket,
+		prefix:  prefix,
 	}
 }
 
@@ -40,56 +42,263 @@ func AzureBlob(client *msstorage.Client, bucket, prefix string) Backend {
 
 // msAzureBlobBackend is a storage backend for Microsoft Azure Blob Storage
 type msAzureBlobBackend struct {
-	client *msstorage.Client
-	bucket string
-	prefix string
+	service *azblob.ServiceURL
+	bucket  string
+	prefix  string
 }
 
 // GetObject retrieves an object from Microsoft Azure Blob Storage, at path
 func (b *msAzureBlobBackend) GetObject(ctx context.Context, path string) (*Object, error) {
-	// Check arguments
-	if b.client == nil {
-		return nil, errors.New("azure: unable to obtain a client reference")
+	return b.GetObjectWithOptions(ctx, path, nil)
+}
+
+// GetObjectWithOptions retrieves an object from Microsoft Azure Blob Storage
+// using conditions. Object version is the blob snapshot timestamp.
+func (b *msAzureBlobBackend) GetObjectWithOptions(ctx context.Context, path string, opts *GetOptions) (*Object, error) {
+	// Retrieve blob reference
+	blobURL, objectPath, err := b.blob(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prepare conditions
+	var conditions azblob.BlobAccessConditions
+	if opts != nil {
+		if opts.VersionID != "" {
+			if _, errParse := time.Parse(time.RFC3339Nano, opts.VersionID); errParse != nil {
+				return nil, fmt.Errorf("azure: invalid snapshot timestamp '%s'", opts.VersionID)
+			}
+			blobURL = blobURL.WithSnapshot(opts.VersionID)
+		}
+		conditions.ModifiedAccessConditions.IfMatch = azblob.ETag(opts.IfMatch)
+		conditions.ModifiedAccessConditions.IfNoneMatch = azblob.ETag(opts.IfNoneMatch)
+	}
+
+	res, err := blobURL.Download(ctx, 0, azblob.CountToEnd, conditions, false, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return nil, azureError(err, objectPath)
+	}
+
+	// Assemble response
+	var object Object
+	object.Path = path
+	object.Content = res.Body(azblob.RetryReaderOptions{})
+	object.Size = res.ContentLength()
+	object.ETag = string(res.ETag())
+	object.ContentType = res.ContentType()
+	object.LastModified = res.LastModified()
+	object.Metadata = azureMetadata(res.NewMetadata())
+	if opts != nil {
+		object.VersionID = opts.VersionID
 	}
 
-	// Retrieve blob service
-	blobSrv := b.client.GetBlobService()
+	// No error
+	return &object, nil
+}
 
+// StatObject retrieves object attributes from Microsoft Azure Blob Storage
+func (b *msAzureBlobBackend) StatObject(ctx context.Context, path string) (*ObjectInfo, error) {
+	// Retrieve blob reference
+	blobURL, objectPath, err := b.blob(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Retrieve properties and user metadata
+	res, err := blobURL.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return nil, azureError(err, objectPath)
+	}
+
+	// No error
+	return &ObjectInfo{
+		Path:         path,
+		Size:         res.ContentLength(),
+		ETag:         string(res.ETag()),
+		ContentType:  res.ContentType(),
+		LastModified: res.LastModified(),
+		Metadata:     azureMetadata(res.NewMetadata()),
+	}, nil
+}
+
+// ListObjects enumerates objects from Microsoft Azure Blob Storage
+func (b *msAzureBlobBackend) ListObjects(ctx context.Context, opts *ListOptions) (*ListResult, error) {
 	// Retrieve container
-	container := blobSrv.GetContainerReference(b.bucket)
-	if container == nil {
-		return nil, errors.New("azure: unable to obtain a container reference")
+	containerURL, err := b.container()
+	if err != nil {
+		return nil, err
+	}
+	if opts == nil {
+		opts = &ListOptions{}
 	}
 
-	// Compute object path
-	objectPath := pathutil.Join(b.prefix, path)
+	// Prepare parameters
+	prefix := joinPrefix(b.prefix, opts.Prefix)
+	marker := azblob.Marker{}
+	if opts.ContinuationToken != "" {
+		marker.Val = &opts.ContinuationToken
+	}
+	params := azblob.ListBlobsSegmentOptions{
+		Prefix: prefix,
+		Details: azblob.BlobListingDetails{
+			Metadata: true,
+		},
+	}
+	if opts.MaxKeys > 0 {
+		params.MaxResults = int32(opts.MaxKeys)
+	}
 
-	// Check if object exists
-	blobReference := container.GetBlobReference(objectPath)
-	if blobReference == nil {
-		return nil, fmt.Errorf("azure: unable to retrieve blob reference for '%s'", objectPath)
+	// List blobs
+	var (
+		blobs    []azblob.BlobItemInternal
+		prefixes []azblob.BlobPrefix
+		next     azblob.Marker
+	)
+	if opts.Delimiter != "" {
+		result, errList := containerURL.ListBlobsHierarchySegment(ctx, marker, opts.Delimiter, params)
+		if errList != nil {
+			return nil, azureError(errList, prefix)
+		}
+		blobs, prefixes, next = result.Segment.BlobItems, result.Segment.BlobPrefixes, result.NextMarker
+	} else {
+		result, errList := containerURL.ListBlobsFlatSegment(ctx, marker, params)
+		if errList != nil {
+			return nil, azureError(errList, prefix)
+		}
+		blobs, next = result.Segment.BlobItems, result.NextMarker
 	}
 
-	// Check existence
-	exists, err := blobReference.Exists()
+	// Assemble response
+	res := &ListResult{}
+	if next.Val != nil {
+		res.NextContinuationToken = *next.Val
+	}
+	for i := range blobs {
+		res.Objects = append(res.Objects, azureObjectInfo(b.prefix, &blobs[i]))
+	}
+	for _, p := range prefixes {
+		res.CommonPrefixes = append(res.CommonPrefixes, relativePath(b.prefix, p.Name))
+	}
+
+	// No error
+	return res, nil
+}
+
+// PutObject uploads an object to Microsoft Azure Blob Storage
+func (b *msAzureBlobBackend) PutObject(ctx context.Context, path string, content io.Reader, opts *PutOptions) (*ObjectInfo, error) {
+	// Retrieve blob reference
+	blobURL, objectPath, err := b.blob(path)
 	if err != nil {
-		return nil, fmt.Errorf("azure: unable to check blob existence for '%s': %w", objectPath, err)
+		return nil, err
+	}
+
+	// Prepare upload
+	var uploadOpts azblob.UploadStreamToBlockBlobOptions
+	info := &ObjectInfo{
+		Path: path,
 	}
-	if !exists {
-		return nil, fmt.Errorf("azure: object '%s' does not exist", objectPath)
+	if opts != nil {
+		uploadOpts.BlobHTTPHeaders.ContentType = opts.ContentType
+		uploadOpts.Metadata = opts.Metadata
+		info.ContentType = opts.ContentType
+		info.Metadata = opts.Metadata
 	}
 
-	readCloser, err := blobReference.Get(nil)
+	// Upload content
+	res, err := azblob.UploadStreamToBlockBlob(ctx, content, blobURL.ToBlockBlobURL(), uploadOpts)
 	if err != nil {
-		return nil, fmt.Errorf("azure: unbale to open content reader for '%s': %w", objectPath, err)
+		return nil, azureError(err, objectPath)
 	}
+	info.ETag = string(res.ETag())
+	info.LastModified = res.LastModified()
 
-	// Assemble response
-	var object Object
-	object.Path = path
-	object.Content = readCloser
-	object.LastModified = time.Time(blobReference.Properties.LastModified)
+	// No error
+	return info, nil
+}
+
+// DeleteObject removes an object from Microsoft Azure Blob Storage
+func (b *msAzureBlobBackend) DeleteObject(ctx context.Context, path string) error {
+	// Retrieve blob reference
+	blobURL, objectPath, err := b.blob(path)
+	if err != nil {
+		return err
+	}
+
+	if _, err := blobURL.Delete(ctx, azblob.DeleteSnapshotsOptionNone, azblob.BlobAccessConditions{}); err != nil {
+		return azureError(err, objectPath)
+	}
 
 	// No error
-	return &object, nil
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+
+func (b *msAzureBlobBackend) container() (azblob.ContainerURL, error) {
+	// Check arguments
+	if b.service == nil {
+		return azblob.ContainerURL{}, errors.New("azure: unable to obtain a client reference")
+	}
+
+	return b.service.NewContainerURL(b.bucket), nil
+}
+
+func (b *msAzureBlobBackend) blob(path string) (azblob.BlobURL, string, error) {
+	// Retrieve container
+	containerURL, err := b.container()
+	if err != nil {
+		return azblob.BlobURL{}, "", err
+	}
+
+	// Compute object path
+	objectPath := pathutil.Join(b.prefix, path)
+
+	return containerURL.NewBlobURL(objectPath), objectPath, nil
+}
+
+func azureObjectInfo(prefix string, blob *azblob.BlobItemInternal) *ObjectInfo {
+	info := &ObjectInfo{
+		Path:         relativePath(prefix, blob.Name),
+		ETag:         string(blob.Properties.Etag),
+		LastModified: blob.Properties.LastModified,
+		VersionID:    blob.Snapshot,
+		Metadata:     azureMetadata(blob.Metadata),
+	}
+	if blob.Properties.ContentLength != nil {
+		info.Size = *blob.Properties.ContentLength
+	}
+	if blob.Properties.ContentType != nil {
+		info.ContentType = *blob.Properties.ContentType
+	}
+
+	return info
+}
+
+func azureMetadata(metadata azblob.Metadata) map[string]string {
+	if len(metadata) == 0 {
+		return nil
+	}
+	return map[string]string(metadata)
+}
+
+// azureError converts Azure errors to storage errors.
+func azureError(err error, objectPath string) error {
+	status := 0
+
+	var serr azblob.StorageError
+	if errors.As(err, &serr) && serr.Response() != nil {
+		status = serr.Response().StatusCode
+	}
+
+	switch status {
+	case http.StatusNotFound:
+		return fmt.Errorf("azure: '%s': %w", objectPath, ErrNotFound)
+	case http.StatusNotModified:
+		return fmt.Errorf("azure: '%s': %w", objectPath, ErrNotModified)
+	case http.StatusPreconditionFailed:
+		return fmt.Errorf("azure: '%s': %w", objectPath, ErrPreconditionFailed)
+	default:
+	}
+
+	return fmt.Errorf("azure: unable to process request for '%s': %w", objectPath, err)
 }
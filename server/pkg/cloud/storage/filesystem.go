@@ -0,0 +1,266 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5" //nolint:gosec // used for entity tag computation only
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	pathutil "path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Filesystem returns an object storage backend rooted at the given local
+// directory. Objects are stored as files at pathutil.Join(prefix, path).
+//
+// Object versions and user metadata are not supported, content type is
+// resolved from the file extension.
+func Filesystem(root, prefix string) Backend {
+	return &filesystemBackend{
+		root:   root,
+		prefix: strings.Trim(prefix, "/"),
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+type filesystemBackend struct {
+	root   string
+	prefix string
+}
+
+// GetObject retrieves an object from local filesystem, at path
+func (b *filesystemBackend) GetObject(ctx context.Context, path string) (*Object, error) {
+	return b.GetObjectWithOptions(ctx, path, nil)
+}
+
+// GetObjectWithOptions retrieves an object from local filesystem using
+// conditions.
+func (b *filesystemBackend) GetObjectWithOptions(ctx context.Context, path string, opts *GetOptions) (*Object, error) {
+	// Versions are not supported
+	if opts != nil && opts.VersionID != "" {
+		return nil, fmt.Errorf("filesystem: '%s' version '%s': %w", path, opts.VersionID, ErrNotFound)
+	}
+
+	// Read file content
+	content, fi, err := b.read(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Assemble response
+	info := b.info(path, fi, content)
+	if err := checkConditions(info, opts); err != nil {
+		return nil, fmt.Errorf("filesystem: '%s': %w", path, err)
+	}
+
+	// No error
+	return newObject(info, io.NopCloser(bytes.NewReader(content))), nil
+}
+
+// StatObject retrieves object attributes from local filesystem
+func (b *filesystemBackend) StatObject(ctx context.Context, path string) (*ObjectInfo, error) {
+	content, fi, err := b.read(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// No error
+	return b.info(path, fi, content), nil
+}
+
+// ListObjects enumerates objects from local filesystem
+func (b *filesystemBackend) ListObjects(ctx context.Context, opts *ListOptions) (*ListResult, error) {
+	// Collect object keys
+	keys := []string{}
+	if err := filepath.WalkDir(b.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(b.root, p)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, filepath.ToSlash(rel))
+		return nil
+	}); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return &ListResult{}, nil
+		}
+		return nil, fmt.Errorf("filesystem: unable to list objects: %w", err)
+	}
+
+	return listKeys(keys, b.prefix, opts, func(key string) (*ObjectInfo, error) {
+		return b.StatObject(ctx, relativePath(b.prefix, key))
+	})
+}
+
+// PutObject writes an object to local filesystem
+func (b *filesystemBackend) PutObject(ctx context.Context, path string, content io.Reader, opts *PutOptions) (*ObjectInfo, error) {
+	// Check arguments
+	if content == nil {
+		return nil, fmt.Errorf("filesystem: unable to store nil content for '%s'", path)
+	}
+
+	// Drain content
+	body, err := io.ReadAll(content)
+	if err != nil {
+		return nil, fmt.Errorf("filesystem: unable to read content for '%s': %w", path, err)
+	}
+
+	// Write to a temporary file and rename it to the object path
+	if err := os.MkdirAll(b.root, 0o750); err != nil {
+		return nil, fmt.Errorf("filesystem: unable to create root directory: %w", err)
+	}
+	filename, err := b.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0o750); err != nil {
+		return nil, fmt.Errorf("filesystem: unable to create object directory for '%s': %w", path, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(filename), ".harp-object-*")
+	if err != nil {
+		return nil, fmt.Errorf("filesystem: unable to create object '%s': %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(body); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("filesystem: unable to write object '%s': %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("filesystem: unable to write object '%s': %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return nil, fmt.Errorf("filesystem: unable to write object '%s': %w", path, err)
+	}
+
+	// No error
+	return b.StatObject(ctx, path)
+}
+
+// DeleteObject removes an object from local filesystem
+func (b *filesystemBackend) DeleteObject(ctx context.Context, path string) error {
+	filename, err := b.resolve(path)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filename); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("filesystem: '%s': %w", path, ErrNotFound)
+		}
+		return fmt.Errorf("filesystem: unable to delete object '%s': %w", path, err)
+	}
+
+	// No error
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+
+// filename returns the local file path of the object. The object key is
+// cleaned as an absolute path to prevent root directory escape.
+func (b *filesystemBackend) filename(path string) string {
+	return filepath.Join(b.root, filepath.FromSlash(pathutil.Join("/", b.prefix, path)))
+}
+
+// resolve returns the local file path of the object with symbolic links
+// resolved. Paths resolved outside of the root directory are rejected.
+func (b *filesystemBackend) resolve(path string) (string, error) {
+	// Resolve root directory
+	root, err := filepath.EvalSymlinks(b.root)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("filesystem: '%s': %w", path, ErrNotFound)
+		}
+		return "", fmt.Errorf("filesystem: unable to resolve root directory: %w", err)
+	}
+
+	// Resolve the deepest existing ancestor, missing elements can't be links
+	existing, missing := b.filename(path), ""
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			existing = filepath.Join(resolved, missing)
+			break
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("filesystem: unable to resolve object '%s' path: %w", path, err)
+		}
+		missing = filepath.Join(filepath.Base(existing), missing)
+		existing = filepath.Dir(existing)
+	}
+
+	// Check root directory containment
+	rel, err := filepath.Rel(root, existing)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("filesystem: '%s' is resolved outside of the root directory: %w", path, ErrNotFound)
+	}
+
+	// No error
+	return existing, nil
+}
+
+func (b *filesystemBackend) read(path string) ([]byte, fs.FileInfo, error) {
+	filename, err := b.resolve(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fi, err := os.Stat(filename)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, fmt.Errorf("filesystem: '%s': %w", path, ErrNotFound)
+		}
+		return nil, nil, fmt.Errorf("filesystem: unable to stat object '%s': %w", path, err)
+	}
+	if !fi.Mode().IsRegular() {
+		return nil, nil, fmt.Errorf("filesystem: '%s': %w", path, ErrNotFound)
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, nil, fmt.Errorf("filesystem: unable to read object '%s': %w", path, err)
+	}
+
+	return content, fi, nil
+}
+
+func (b *filesystemBackend) info(path string, fi fs.FileInfo, content []byte) *ObjectInfo {
+	sum := md5.Sum(content) //nolint:gosec // entity tag only
+	return &ObjectInfo{
+		Path:         strings.TrimPrefix(path, "/"),
+		Size:         fi.Size(),
+		ETag:         strconv.Quote(hex.EncodeToString(sum[:])),
+		ContentType:  mime.TypeByExtension(filepath.Ext(path)),
+		LastModified: fi.ModTime().UTC(),
+	}
+}
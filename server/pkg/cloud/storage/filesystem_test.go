@@ -0,0 +1,75 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFilesystem_SymlinkEscape(t *testing.T) {
+	ctx := context.Background()
+	root, outside := t.TempDir(), t.TempDir()
+
+	// Prepare files
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("outside"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "inside"), []byte("inside"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret"), filepath.Join(root, "file-link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "dir-link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "inside"), filepath.Join(root, "inner-link")); err != nil {
+		t.Fatal(err)
+	}
+
+	b := Filesystem(root, "")
+
+	// Links resolved outside of root are rejected
+	for _, p := range []string{"file-link", "dir-link/secret", "/../dir-link/secret"} {
+		if _, err := b.GetObject(ctx, p); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetObject(%q): expected not found error, got %v", p, err)
+		}
+	}
+	if _, err := b.PutObject(ctx, "dir-link/new", strings.NewReader("value"), nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("PutObject: expected not found error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "new")); !errors.Is(err, os.ErrNotExist) {
+		t.Error("PutObject: object written outside of root directory")
+	}
+	if err := b.DeleteObject(ctx, "file-link"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeleteObject: expected not found error, got %v", err)
+	}
+
+	// Links resolved inside root are allowed
+	if _, err := b.GetObject(ctx, "inner-link"); err != nil {
+		t.Errorf("GetObject: unexpected error: %v", err)
+	}
+	if _, err := b.PutObject(ctx, "dir/new", strings.NewReader("value"), nil); err != nil {
+		t.Errorf("PutObject: unexpected error: %v", err)
+	}
+}
@@ -21,11 +21,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	pathutil "path"
+	"strconv"
 
 	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
 )
 
+// Default listing page size
+const gcsDefaultPageSize = 1000
+
 // GCS Backend object storage manager
 func GCS(client *storage.Client, bucket, prefix string) Backend {
 	return &gcsBackend{
@@ -46,6 +52,12 @@ type gcsBackend struct {
 
 // GetObject retrieves an object from Google Cloud Storage bucket, at prefix
 func (b *gcsBackend) GetObject(ctx context.Context, path string) (*Object, error) {
+	return b.GetObjectWithOptions(ctx, path, nil)
+}
+
+// GetObjectWithOptions retrieves an object from Google Cloud Storage bucket
+// using conditions. Object version is the object generation.
+func (b *gcsBackend) GetObjectWithOptions(ctx context.Context, path string, opts *GetOptions) (*Object, error) {
 	// Check parameters
 	if b.client == nil {
 		return nil, errors.New("gcs: client is nil")
@@ -57,24 +69,165 @@ func (b *gcsBackend) GetObject(ctx context.Context, path string) (*Object, error
 		return nil, errors.New("gcs: unable to retrieve object reference")
 	}
 
+	// Pin object generation
+	if opts != nil && opts.VersionID != "" {
+		generation, err := strconv.ParseInt(opts.VersionID, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("gcs: invalid object generation '%s'", opts.VersionID)
+		}
+		objectHandle = objectHandle.Generation(generation)
+	}
+
 	// Retrieve object attributes
 	attrs, err := objectHandle.Attrs(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("gcs: unable to retrieve object attribute: %w", err)
+		return nil, gcsError(err, path)
 	}
 
-	// Prepare content reader
-	rc, err := objectHandle.NewReader(ctx)
+	// Check conditions
+	info := gcsObjectInfo(b.prefix, attrs)
+	if err := checkConditions(info, opts); err != nil {
+		return nil, fmt.Errorf("gcs: '%s': %w", path, err)
+	}
+
+	// Prepare content reader, pinned to the checked generation
+	rc, err := objectHandle.Generation(attrs.Generation).NewReader(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("gcs: unable to initialie object reader: %w", err)
+		return nil, gcsError(err, path)
 	}
 
 	// Assemble response
-	var object Object
+	object := newObject(info, rc)
 	object.Path = path
-	object.Content = rc
-	object.LastModified = attrs.Updated
 
 	// No error
-	return &object, nil
+	return object, nil
+}
+
+// StatObject retrieves object attributes from Google Cloud Storage bucket
+func (b *gcsBackend) StatObject(ctx context.Context, path string) (*ObjectInfo, error) {
+	// Check parameters
+	if b.client == nil {
+		return nil, errors.New("gcs: client is nil")
+	}
+
+	attrs, err := b.client.Bucket(b.bucket).Object(pathutil.Join(b.prefix, path)).Attrs(ctx)
+	if err != nil {
+		return nil, gcsError(err, path)
+	}
+
+	// No error
+	info := gcsObjectInfo(b.prefix, attrs)
+	info.Path = path
+	return info, nil
+}
+
+// ListObjects enumerates objects from Google Cloud Storage bucket
+func (b *gcsBackend) ListObjects(ctx context.Context, opts *ListOptions) (*ListResult, error) {
+	// Check parameters
+	if b.client == nil {
+		return nil, errors.New("gcs: client is nil")
+	}
+	if opts == nil {
+		opts = &ListOptions{}
+	}
+
+	pageSize := opts.MaxKeys
+	if pageSize <= 0 {
+		pageSize = gcsDefaultPageSize
+	}
+
+	// Prepare iterator
+	it := b.client.Bucket(b.bucket).Objects(ctx, &storage.Query{
+		Prefix:    joinPrefix(b.prefix, opts.Prefix),
+		Delimiter: opts.Delimiter,
+	})
+
+	var attrs []*storage.ObjectAttrs
+	next, err := iterator.NewPager(it, pageSize, opts.ContinuationToken).NextPage(&attrs)
+	if err != nil {
+		return nil, fmt.Errorf("gcs: unable to list objects: %w", err)
+	}
+
+	// Assemble response
+	res := &ListResult{
+		NextContinuationToken: next,
+	}
+	for _, a := range attrs {
+		if a.Prefix != "" {
+			res.CommonPrefixes = append(res.CommonPrefixes, relativePath(b.prefix, a.Prefix))
+			continue
+		}
+		res.Objects = append(res.Objects, gcsObjectInfo(b.prefix, a))
+	}
+
+	// No error
+	return res, nil
+}
+
+// PutObject uploads an object to Google Cloud Storage bucket
+func (b *gcsBackend) PutObject(ctx context.Context, path string, content io.Reader, opts *PutOptions) (*ObjectInfo, error) {
+	// Check parameters
+	if b.client == nil {
+		return nil, errors.New("gcs: client is nil")
+	}
+
+	// Prepare writer
+	w := b.client.Bucket(b.bucket).Object(pathutil.Join(b.prefix, path)).NewWriter(ctx)
+	if opts != nil {
+		w.ContentType = opts.ContentType
+		w.Metadata = opts.Metadata
+	}
+
+	// Upload content
+	if _, err := io.Copy(w, content); err != nil {
+		_ = w.Close()
+		return nil, fmt.Errorf("gcs: unable to upload object '%s': %w", path, err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("gcs: unable to upload object '%s': %w", path, err)
+	}
+
+	// No error
+	info := gcsObjectInfo(b.prefix, w.Attrs())
+	info.Path = path
+	return info, nil
+}
+
+// DeleteObject removes an object from Google Cloud Storage bucket
+func (b *gcsBackend) DeleteObject(ctx context.Context, path string) error {
+	// Check parameters
+	if b.client == nil {
+		return errors.New("gcs: client is nil")
+	}
+
+	if err := b.client.Bucket(b.bucket).Object(pathutil.Join(b.prefix, path)).Delete(ctx); err != nil {
+		return gcsError(err, path)
+	}
+
+	// No error
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+
+func gcsObjectInfo(prefix string, attrs *storage.ObjectAttrs) *ObjectInfo {
+	return &ObjectInfo{
+		Path:         relativePath(prefix, attrs.Name),
+		Size:         attrs.Size,
+		ETag:         attrs.Etag,
+		VersionID:    strconv.FormatInt(attrs.Generation, 10),
+		ContentType:  attrs.ContentType,
+		LastModified: attrs.Updated,
+		Metadata:     attrs.Metadata,
+	}
+}
+
+// gcsError converts GCS errors to storage errors.
+func gcsError(err error, path string) error {
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return fmt.Errorf("gcs: '%s': %w", path, ErrNotFound)
+	}
+
+	return fmt.Errorf("gcs: unable to process request: %w", err)
 }
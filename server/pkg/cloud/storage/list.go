@@ -0,0 +1,90 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package storage
+
+import (
+	"sort"
+	"strings"
+)
+
+// listKeys builds an object enumeration page from full object keys, for
+// backends without native listing. Continuation token is the last emitted
+// key.
+func listKeys(keys []string, prefix string, opts *ListOptions, stat func(key string) (*ObjectInfo, error)) (*ListResult, error) {
+	if opts == nil {
+		opts = &ListOptions{}
+	}
+
+	// Sort keys to provide a stable pagination
+	sort.Strings(keys)
+
+	var (
+		listPrefix   = strings.TrimPrefix(joinPrefix(prefix, opts.Prefix), "/")
+		res          = &ListResult{}
+		seenPrefixes = map[string]struct{}{}
+		last         = ""
+		count        = 0
+	)
+	for _, k := range keys {
+		// Resume after continuation token
+		if opts.ContinuationToken != "" && k <= opts.ContinuationToken {
+			continue
+		}
+		if !strings.HasPrefix(k, listPrefix) {
+			continue
+		}
+
+		// Group by delimiter
+		if opts.Delimiter != "" {
+			rest := strings.TrimPrefix(k, listPrefix)
+			if idx := strings.Index(rest, opts.Delimiter); idx >= 0 {
+				commonPrefix := listPrefix + rest[:idx+len(opts.Delimiter)]
+				if _, ok := seenPrefixes[commonPrefix]; ok {
+					continue
+				}
+				if opts.MaxKeys > 0 && count >= opts.MaxKeys {
+					res.NextContinuationToken = last
+					break
+				}
+				seenPrefixes[commonPrefix] = struct{}{}
+				res.CommonPrefixes = append(res.CommonPrefixes, relativePath(prefix, commonPrefix))
+				// Skip all keys sharing this common prefix on next page
+				last = commonPrefix + "\xff"
+				count++
+				continue
+			}
+		}
+
+		// Check page size
+		if opts.MaxKeys > 0 && count >= opts.MaxKeys {
+			res.NextContinuationToken = last
+			break
+		}
+
+		info, err := stat(k)
+		if err != nil {
+			return nil, err
+		}
+		res.Objects = append(res.Objects, info)
+		last = k
+		count++
+	}
+
+	// No error
+	return res, nil
+}
@@ -0,0 +1,334 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5" //nolint:gosec // used for entity tag computation only
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	pathutil "path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInjectedFailure is raised by failure injection.
+var ErrInjectedFailure = errors.New("storage: injected failure")
+
+// Operation names used by failure injection.
+const (
+	OperationGet    = "get"
+	OperationStat   = "stat"
+	OperationList   = "list"
+	OperationPut    = "put"
+	OperationDelete = "delete"
+)
+
+// FailureFunc decides whether the given operation on path must fail, by
+// returning a non-nil error.
+type FailureFunc func(op, path string) error
+
+// MemoryOption defines in-memory backend optional settings.
+type MemoryOption func(*memoryBackend)
+
+// WithLatency delays all operations by the given duration.
+func WithLatency(latency time.Duration) MemoryOption {
+	return func(b *memoryBackend) {
+		b.latency = latency
+	}
+}
+
+// WithFailures injects operation failures.
+func WithFailures(fn FailureFunc) MemoryOption {
+	return func(b *memoryBackend) {
+		b.failures = fn
+	}
+}
+
+// FailureRate returns a FailureFunc that randomly fails the given operations
+// (all if empty) with ErrInjectedFailure, according to rate (0 to 1).
+func FailureRate(rate float64, ops ...string) FailureFunc {
+	return func(op, path string) error {
+		if len(ops) > 0 && !contains(ops, op) {
+			return nil
+		}
+		//nolint:gosec // no cryptographic usage
+		if rand.Float64() < rate {
+			return fmt.Errorf("memory: %s '%s': %w", op, path, ErrInjectedFailure)
+		}
+		return nil
+	}
+}
+
+// WithObject stores an initial object version, without injected latency or
+// failures.
+func WithObject(path string, content []byte, opts *PutOptions) MemoryOption {
+	return func(b *memoryBackend) {
+		b.store(path, content, opts)
+	}
+}
+
+// Memory returns an in-memory object storage backend, mainly used for tests.
+// Each PutObject creates a new object version.
+func Memory(prefix string, opts ...MemoryOption) Backend {
+	b := &memoryBackend{
+		prefix:  strings.Trim(prefix, "/"),
+		objects: map[string][]*memoryObject{},
+	}
+	for _, o := range opts {
+		o(b)
+	}
+
+	return b
+}
+
+// -----------------------------------------------------------------------------
+
+type memoryObject struct {
+	info    ObjectInfo
+	content []byte
+}
+
+type memoryBackend struct {
+	sync.RWMutex
+	prefix   string
+	objects  map[string][]*memoryObject
+	version  uint64
+	latency  time.Duration
+	failures FailureFunc
+}
+
+// GetObject retrieves the latest object version from memory.
+func (b *memoryBackend) GetObject(ctx context.Context, path string) (*Object, error) {
+	return b.GetObjectWithOptions(ctx, path, nil)
+}
+
+// GetObjectWithOptions retrieves an object from memory using conditions.
+func (b *memoryBackend) GetObjectWithOptions(ctx context.Context, path string, opts *GetOptions) (*Object, error) {
+	if err := b.inject(ctx, OperationGet, path); err != nil {
+		return nil, err
+	}
+
+	b.RLock()
+	defer b.RUnlock()
+
+	// Resolve object version
+	obj, err := b.lookup(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkConditions(&obj.info, opts); err != nil {
+		return nil, fmt.Errorf("memory: '%s': %w", path, err)
+	}
+
+	// No error
+	info := obj.copyInfo()
+	return newObject(&info, io.NopCloser(bytes.NewReader(obj.content))), nil
+}
+
+// StatObject retrieves the latest object version attributes from memory.
+func (b *memoryBackend) StatObject(ctx context.Context, path string) (*ObjectInfo, error) {
+	if err := b.inject(ctx, OperationStat, path); err != nil {
+		return nil, err
+	}
+
+	b.RLock()
+	defer b.RUnlock()
+
+	obj, err := b.lookup(path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// No error
+	info := obj.copyInfo()
+	return &info, nil
+}
+
+// ListObjects enumerates latest object versions from memory.
+func (b *memoryBackend) ListObjects(ctx context.Context, opts *ListOptions) (*ListResult, error) {
+	if err := b.inject(ctx, OperationList, listPath(opts)); err != nil {
+		return nil, err
+	}
+
+	b.RLock()
+	defer b.RUnlock()
+
+	keys := make([]string, 0, len(b.objects))
+	for k := range b.objects {
+		keys = append(keys, k)
+	}
+
+	return listKeys(keys, b.prefix, opts, func(key string) (*ObjectInfo, error) {
+		versions := b.objects[key]
+		info := versions[len(versions)-1].copyInfo()
+		return &info, nil
+	})
+}
+
+// PutObject stores a new object version in memory.
+func (b *memoryBackend) PutObject(ctx context.Context, path string, content io.Reader, opts *PutOptions) (*ObjectInfo, error) {
+	if err := b.inject(ctx, OperationPut, path); err != nil {
+		return nil, err
+	}
+
+	// Check arguments
+	if content == nil {
+		return nil, fmt.Errorf("memory: unable to store nil content for '%s'", path)
+	}
+
+	// Drain content
+	body, err := io.ReadAll(content)
+	if err != nil {
+		return nil, fmt.Errorf("memory: unable to read content for '%s': %w", path, err)
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	// No error
+	info := b.store(path, body, opts).copyInfo()
+	return &info, nil
+}
+
+// DeleteObject removes all object versions from memory.
+func (b *memoryBackend) DeleteObject(ctx context.Context, path string) error {
+	if err := b.inject(ctx, OperationDelete, path); err != nil {
+		return err
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	key := b.key(path)
+	if _, ok := b.objects[key]; !ok {
+		return fmt.Errorf("memory: '%s': %w", path, ErrNotFound)
+	}
+	delete(b.objects, key)
+
+	// No error
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+
+// inject applies latency and failures to the given operation.
+func (b *memoryBackend) inject(ctx context.Context, op, path string) error {
+	if b.latency > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(b.latency):
+		}
+	}
+	if b.failures != nil {
+		return b.failures(op, path)
+	}
+
+	return nil
+}
+
+func listPath(opts *ListOptions) string {
+	if opts == nil {
+		return ""
+	}
+	return opts.Prefix
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// store appends a new object version, caller must hold the lock.
+func (b *memoryBackend) store(path string, body []byte, opts *PutOptions) *memoryObject {
+	key := b.key(path)
+
+	// Prepare object
+	b.version++
+	sum := md5.Sum(body) //nolint:gosec // entity tag only
+	obj := &memoryObject{
+		info: ObjectInfo{
+			Path:         relativePath(b.prefix, key),
+			Size:         int64(len(body)),
+			ETag:         strconv.Quote(hex.EncodeToString(sum[:])),
+			VersionID:    strconv.FormatUint(b.version, 10),
+			LastModified: time.Now().UTC(),
+		},
+		content: body,
+	}
+	if opts != nil {
+		obj.info.ContentType = opts.ContentType
+		if len(opts.Metadata) > 0 {
+			obj.info.Metadata = map[string]string{}
+			for k, v := range opts.Metadata {
+				obj.info.Metadata[k] = v
+			}
+		}
+	}
+
+	// Append version
+	b.objects[key] = append(b.objects[key], obj)
+
+	return obj
+}
+
+func (b *memoryBackend) key(path string) string {
+	return strings.TrimPrefix(pathutil.Join(b.prefix, path), "/")
+}
+
+func (b *memoryBackend) lookup(path string, opts *GetOptions) (*memoryObject, error) {
+	versions, ok := b.objects[b.key(path)]
+	if !ok || len(versions) == 0 {
+		return nil, fmt.Errorf("memory: '%s': %w", path, ErrNotFound)
+	}
+
+	// Latest version by default
+	if opts == nil || opts.VersionID == "" {
+		return versions[len(versions)-1], nil
+	}
+
+	for _, v := range versions {
+		if v.info.VersionID == opts.VersionID {
+			return v, nil
+		}
+	}
+
+	return nil, fmt.Errorf("memory: '%s' version '%s': %w", path, opts.VersionID, ErrNotFound)
+}
+
+func (o *memoryObject) copyInfo() ObjectInfo {
+	info := o.info
+	if o.info.Metadata != nil {
+		info.Metadata = make(map[string]string, len(o.info.Metadata))
+		for k, v := range o.info.Metadata {
+			info.Metadata[k] = v
+		}
+	}
+	return info
+}
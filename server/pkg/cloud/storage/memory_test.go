@@ -0,0 +1,252 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func objectPaths(objects []*ObjectInfo) []string {
+	res := []string{}
+	for _, o := range objects {
+		res = append(res, o.Path)
+	}
+	return res
+}
+
+func TestMemory_Versions(t *testing.T) {
+	ctx := context.Background()
+	b := Memory("secrets", WithObject("app/db", []byte("v1"), &PutOptions{ContentType: "text/plain"}))
+
+	// Each put creates a new version
+	info, err := b.PutObject(ctx, "app/db", strings.NewReader("v2"), &PutOptions{Metadata: map[string]string{"owner": "team"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.Path != "app/db" || info.VersionID != "2" || info.Size != 2 {
+		t.Errorf("unexpected object attributes %+v", info)
+	}
+
+	// Latest version by default
+	obj, err := b.GetObject(ctx, "/app/db")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	content, _ := io.ReadAll(obj.Content)
+	if string(content) != "v2" || obj.Metadata["owner"] != "team" || obj.ETag != info.ETag {
+		t.Errorf("unexpected latest object %q %+v", content, obj.Info())
+	}
+
+	// Pinned version
+	obj, err = b.GetObjectWithOptions(ctx, "app/db", &GetOptions{VersionID: "1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	content, _ = io.ReadAll(obj.Content)
+	if string(content) != "v1" || obj.ContentType != "text/plain" {
+		t.Errorf("unexpected pinned object %q %+v", content, obj.Info())
+	}
+	if _, err := b.GetObjectWithOptions(ctx, "app/db", &GetOptions{VersionID: "3"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected not found error for unknown version, got %v", err)
+	}
+
+	// Conditions
+	if _, err := b.GetObjectWithOptions(ctx, "app/db", &GetOptions{IfNoneMatch: info.ETag}); !errors.Is(err, ErrNotModified) {
+		t.Errorf("expected not modified error, got %v", err)
+	}
+	if _, err := b.GetObjectWithOptions(ctx, "app/db", &GetOptions{IfMatch: `"other"`}); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("expected precondition error, got %v", err)
+	}
+
+	// Deletion removes all versions
+	if err := b.DeleteObject(ctx, "app/db"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := b.StatObject(ctx, "app/db"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+	if err := b.DeleteObject(ctx, "app/db"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}
+
+func TestMemory_Pagination(t *testing.T) {
+	ctx := context.Background()
+	b := Memory("secrets",
+		WithObject("app/a", []byte("a"), nil),
+		WithObject("app/b", []byte("b"), nil),
+		WithObject("app/c", []byte("c"), nil),
+		WithObject("app/d", []byte("d"), nil),
+		WithObject("app/e", []byte("e"), nil),
+		WithObject("other/f", []byte("f"), nil),
+	)
+
+	// Iterate over pages using continuation tokens
+	pages := [][]string{}
+	opts := &ListOptions{Prefix: "app/", MaxKeys: 2}
+	for i := 0; i < 10; i++ {
+		page, err := b.ListObjects(ctx, opts)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		pages = append(pages, objectPaths(page.Objects))
+		if page.NextContinuationToken == "" {
+			break
+		}
+		opts.ContinuationToken = page.NextContinuationToken
+	}
+
+	expected := [][]string{{"app/a", "app/b"}, {"app/c", "app/d"}, {"app/e"}}
+	if !reflect.DeepEqual(pages, expected) {
+		t.Errorf("unexpected pages %v, want %v", pages, expected)
+	}
+
+	// Without page size, all objects are returned at once
+	page, err := b.ListObjects(ctx, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page.Objects) != 6 || page.NextContinuationToken != "" {
+		t.Errorf("unexpected full listing %v (%q)", objectPaths(page.Objects), page.NextContinuationToken)
+	}
+}
+
+func TestMemory_PaginationWithDelimiter(t *testing.T) {
+	ctx := context.Background()
+	b := Memory("",
+		WithObject("a/1", []byte("1"), nil),
+		WithObject("a/2", []byte("2"), nil),
+		WithObject("b", []byte("b"), nil),
+		WithObject("c/1", []byte("1"), nil),
+		WithObject("d", []byte("d"), nil),
+	)
+
+	type page struct {
+		objects  []string
+		prefixes []string
+	}
+
+	// Common prefixes count as page entries and are never repeated
+	pages := []page{}
+	opts := &ListOptions{Delimiter: "/", MaxKeys: 2}
+	for i := 0; i < 10; i++ {
+		res, err := b.ListObjects(ctx, opts)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		pages = append(pages, page{objects: objectPaths(res.Objects), prefixes: res.CommonPrefixes})
+		if res.NextContinuationToken == "" {
+			break
+		}
+		opts.ContinuationToken = res.NextContinuationToken
+	}
+
+	expected := []page{
+		{objects: []string{"b"}, prefixes: []string{"a/"}},
+		{objects: []string{"d"}, prefixes: []string{"c/"}},
+	}
+	if !reflect.DeepEqual(pages, expected) {
+		t.Errorf("unexpected pages %+v, want %+v", pages, expected)
+	}
+}
+
+func TestMemory_Latency(t *testing.T) {
+	b := Memory("", WithLatency(50*time.Millisecond), WithObject("app/db", []byte("value"), nil))
+
+	// Operations are delayed
+	start := time.Now()
+	if _, err := b.StatObject(context.Background(), "app/db"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("expected delayed operation, took %s", elapsed)
+	}
+
+	// Delayed operations honour context cancellation
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if _, err := b.GetObject(ctx, "app/db"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline error, got %v", err)
+	}
+}
+
+func TestMemory_Failures(t *testing.T) {
+	ctx := context.Background()
+	injected := errors.New("injected")
+	calls := []string{}
+	b := Memory("", WithObject("app/db", []byte("value"), nil), WithFailures(func(op, path string) error {
+		calls = append(calls, op+":"+path)
+		if op == OperationGet && path == "app/db" {
+			return fmt.Errorf("get: %w", injected)
+		}
+		return nil
+	}))
+
+	if _, err := b.GetObject(ctx, "app/db"); !errors.Is(err, injected) {
+		t.Errorf("expected injected error, got %v", err)
+	}
+	if _, err := b.StatObject(ctx, "app/db"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := b.ListObjects(ctx, &ListOptions{Prefix: "app/"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := b.PutObject(ctx, "app/new", strings.NewReader("value"), nil); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := b.DeleteObject(ctx, "app/new"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	expected := []string{"get:app/db", "stat:app/db", "list:app/", "put:app/new", "delete:app/new"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("unexpected injection calls %v, want %v", calls, expected)
+	}
+}
+
+func TestFailureRate(t *testing.T) {
+	always := FailureRate(1, OperationGet, OperationList)
+	if err := always(OperationGet, "app/db"); !errors.Is(err, ErrInjectedFailure) {
+		t.Errorf("expected injected failure, got %v", err)
+	}
+	if err := always(OperationList, ""); !errors.Is(err, ErrInjectedFailure) {
+		t.Errorf("expected injected failure, got %v", err)
+	}
+	if err := always(OperationPut, "app/db"); err != nil {
+		t.Errorf("unexpected failure for an unselected operation: %v", err)
+	}
+
+	all := FailureRate(1)
+	if err := all(OperationDelete, "app/db"); !errors.Is(err, ErrInjectedFailure) {
+		t.Errorf("expected injected failure for all operations, got %v", err)
+	}
+
+	never := FailureRate(0)
+	for i := 0; i < 100; i++ {
+		if err := never(OperationGet, "app/db"); err != nil {
+			t.Fatalf("unexpected failure: %v", err)
+		}
+	}
+}
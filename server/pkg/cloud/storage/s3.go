@@ -21,7 +21,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	pathutil "path"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
@@ -29,26 +32,70 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 )
 
+// ErrUnexpectedEncryption is raised when the object is not encrypted with the
+// expected SSE-KMS key.
+var ErrUnexpectedEncryption = errors.New("storage: unexpected object encryption")
+
+// S3Option defines Amazon S3 backend optional settings.
+type S3Option func(*s3Backend)
+
+// WithSSECustomerKey sets the AES256 customer-provided key (SSE-C) used to
+// read and write objects.
+func WithSSECustomerKey(key []byte) S3Option {
+	return func(b *s3Backend) {
+		b.sseCustomerKey = key
+	}
+}
+
+// WithSSEKMSKeyID sets the SSE-KMS key used to encrypt written objects, read
+// objects must be encrypted with this key.
+func WithSSEKMSKeyID(keyID string) S3Option {
+	return func(b *s3Backend) {
+		b.sseKMSKeyID = keyID
+	}
+}
+
+// WithRequesterPays acknowledges requester charges for requester-pays
+// buckets.
+func WithRequesterPays() S3Option {
+	return func(b *s3Backend) {
+		b.requestPayer = aws.String(s3.RequestPayerRequester)
+	}
+}
+
 // S3 Backend object storage manager
-func S3(client s3iface.S3API, bucket, prefix string) Backend {
-	return &s3Backend{
+func S3(client s3iface.S3API, bucket, prefix string, opts ...S3Option) Backend {
+	b := &s3Backend{
 		client: client,
 		bucket: bucket,
 		prefix: prefix,
 	}
+	for _, o := range opts {
+		o(b)
+	}
+
+	return b
 }
 
 // -----------------------------------------------------------------------------
 
 // s3Backend is a storage backend for Amazon S3
 type s3Backend struct {
-	client s3iface.S3API
-	bucket string
-	prefix string
+	client         s3iface.S3API
+	bucket         string
+	prefix         string
+	sseCustomerKey []byte
+	sseKMSKeyID    string
+	requestPayer   *string
 }
 
 // GetObject retrieves an object from Amazon S3 bucket, at prefix
 func (b *s3Backend) GetObject(ctx context.Context, path string) (*Object, error) {
+	return b.GetObjectWithOptions(ctx, path, nil)
+}
+
+// GetObjectWithOptions retrieves an object from Amazon S3 bucket using conditions
+func (b *s3Backend) GetObjectWithOptions(ctx context.Context, path string, opts *GetOptions) (*Object, error) {
 	// Check parameters
 	if b.client == nil {
 		return nil, errors.New("s3: client is nil")
@@ -56,31 +103,279 @@ func (b *s3Backend) GetObject(ctx context.Context, path string) (*Object, error)
 
 	// Prepare request
 	input := &s3.GetObjectInput{
-		Bucket: aws.String(b.bucket),
-		Key:    aws.String(pathutil.Join(b.prefix, path)),
+		Bucket:       aws.String(b.bucket),
+		Key:          aws.String(pathutil.Join(b.prefix, path)),
+		RequestPayer: b.requestPayer,
+	}
+	if len(b.sseCustomerKey) > 0 {
+		input.SSECustomerAlgorithm = aws.String(s3.ServerSideEncryptionAes256)
+		input.SSECustomerKey = aws.String(string(b.sseCustomerKey))
+	}
+	if opts != nil {
+		if opts.VersionID != "" {
+			input.VersionId = aws.String(opts.VersionID)
+		}
+		if opts.IfMatch != "" {
+			input.IfMatch = aws.String(opts.IfMatch)
+		}
+		if opts.IfNoneMatch != "" {
+			input.IfNoneMatch = aws.String(opts.IfNoneMatch)
+		}
 	}
 
 	// Get object from bucket
 	result, err := b.client.GetObjectWithContext(ctx, input)
 	if err != nil {
-		var aerr awserr.Error
-		if errors.As(err, &aerr) {
-			switch aerr.Code() {
-			case s3.ErrCodeNoSuchKey:
-				return nil, fmt.Errorf("s3: object not found '%s'", *input.Key)
-			default:
-				return nil, fmt.Errorf("s3: unable to retrieve object: %w", aerr)
-			}
-		}
-		return nil, fmt.Errorf("s3: unable to process request: %w", err)
+		return nil, s3Error(err, *input.Key)
+	}
+
+	// Check encryption key
+	if err := b.checkEncryption(*input.Key, result.ServerSideEncryption, result.SSEKMSKeyId); err != nil {
+		result.Body.Close()
+		return nil, err
 	}
 
 	// Assemble response
 	var object Object
 	object.Path = path
 	object.Content = result.Body
-	object.LastModified = *result.LastModified
+	object.Size = aws.Int64Value(result.ContentLength)
+	object.ETag = aws.StringValue(result.ETag)
+	object.VersionID = aws.StringValue(result.VersionId)
+	object.ContentType = aws.StringValue(result.ContentType)
+	object.LastModified = aws.TimeValue(result.LastModified)
+	object.Metadata = aws.StringValueMap(result.Metadata)
 
 	// No error
 	return &object, nil
 }
+
+// StatObject retrieves object attributes from Amazon S3 bucket
+func (b *s3Backend) StatObject(ctx context.Context, path string) (*ObjectInfo, error) {
+	// Check parameters
+	if b.client == nil {
+		return nil, errors.New("s3: client is nil")
+	}
+
+	key := pathutil.Join(b.prefix, path)
+	input := &s3.HeadObjectInput{
+		Bucket:       aws.String(b.bucket),
+		Key:          aws.String(key),
+		RequestPayer: b.requestPayer,
+	}
+	if len(b.sseCustomerKey) > 0 {
+		input.SSECustomerAlgorithm = aws.String(s3.ServerSideEncryptionAes256)
+		input.SSECustomerKey = aws.String(string(b.sseCustomerKey))
+	}
+
+	result, err := b.client.HeadObjectWithContext(ctx, input)
+	if err != nil {
+		return nil, s3Error(err, key)
+	}
+
+	// Check encryption key
+	if err := b.checkEncryption(key, result.ServerSideEncryption, result.SSEKMSKeyId); err != nil {
+		return nil, err
+	}
+
+	// No error
+	return &ObjectInfo{
+		Path:         path,
+		Size:         aws.Int64Value(result.ContentLength),
+		ETag:         aws.StringValue(result.ETag),
+		VersionID:    aws.StringValue(result.VersionId),
+		ContentType:  aws.StringValue(result.ContentType),
+		LastModified: aws.TimeValue(result.LastModified),
+		Metadata:     aws.StringValueMap(result.Metadata),
+	}, nil
+}
+
+// ListObjects enumerates objects from Amazon S3 bucket
+func (b *s3Backend) ListObjects(ctx context.Context, opts *ListOptions) (*ListResult, error) {
+	// Check parameters
+	if b.client == nil {
+		return nil, errors.New("s3: client is nil")
+	}
+	if opts == nil {
+		opts = &ListOptions{}
+	}
+
+	// Prepare request
+	input := &s3.ListObjectsV2Input{
+		Bucket:       aws.String(b.bucket),
+		Prefix:       aws.String(joinPrefix(b.prefix, opts.Prefix)),
+		RequestPayer: b.requestPayer,
+	}
+	if opts.Delimiter != "" {
+		input.Delimiter = aws.String(opts.Delimiter)
+	}
+	if opts.MaxKeys > 0 {
+		input.MaxKeys = aws.Int64(int64(opts.MaxKeys))
+	}
+	if opts.ContinuationToken != "" {
+		input.ContinuationToken = aws.String(opts.ContinuationToken)
+	}
+
+	result, err := b.client.ListObjectsV2WithContext(ctx, input)
+	if err != nil {
+		return nil, s3Error(err, aws.StringValue(input.Prefix))
+	}
+
+	// Assemble response
+	res := &ListResult{
+		NextContinuationToken: aws.StringValue(result.NextContinuationToken),
+	}
+	for _, o := range result.Contents {
+		res.Objects = append(res.Objects, &ObjectInfo{
+			Path:         relativePath(b.prefix, aws.StringValue(o.Key)),
+			Size:         aws.Int64Value(o.Size),
+			ETag:         aws.StringValue(o.ETag),
+			LastModified: aws.TimeValue(o.LastModified),
+		})
+	}
+	for _, p := range result.CommonPrefixes {
+		res.CommonPrefixes = append(res.CommonPrefixes, relativePath(b.prefix, aws.StringValue(p.Prefix)))
+	}
+
+	// No error
+	return res, nil
+}
+
+// PutObject uploads an object to Amazon S3 bucket
+func (b *s3Backend) PutObject(ctx context.Context, path string, content io.Reader, opts *PutOptions) (*ObjectInfo, error) {
+	// Check parameters
+	if b.client == nil {
+		return nil, errors.New("s3: client is nil")
+	}
+
+	// S3 requires a seekable body
+	body, ok := content.(io.ReadSeeker)
+	if !ok {
+		return nil, errors.New("s3: content must be seekable")
+	}
+
+	// Prepare request
+	key := pathutil.Join(b.prefix, path)
+	input := &s3.PutObjectInput{
+		Bucket:       aws.String(b.bucket),
+		Key:          aws.String(key),
+		Body:         body,
+		RequestPayer: b.requestPayer,
+	}
+	switch {
+	case len(b.sseCustomerKey) > 0:
+		input.SSECustomerAlgorithm = aws.String(s3.ServerSideEncryptionAes256)
+		input.SSECustomerKey = aws.String(string(b.sseCustomerKey))
+	case b.sseKMSKeyID != "":
+		input.ServerSideEncryption = aws.String(s3.ServerSideEncryptionAwsKms)
+		input.SSEKMSKeyId = aws.String(b.sseKMSKeyID)
+	default:
+	}
+	if opts != nil {
+		if opts.ContentType != "" {
+			input.ContentType = aws.String(opts.ContentType)
+		}
+		if len(opts.Metadata) > 0 {
+			input.Metadata = aws.StringMap(opts.Metadata)
+		}
+	}
+
+	result, err := b.client.PutObjectWithContext(ctx, input)
+	if err != nil {
+		return nil, s3Error(err, key)
+	}
+
+	// No error
+	return &ObjectInfo{
+		Path:      path,
+		ETag:      aws.StringValue(result.ETag),
+		VersionID: aws.StringValue(result.VersionId),
+	}, nil
+}
+
+// DeleteObject removes an object from Amazon S3 bucket
+func (b *s3Backend) DeleteObject(ctx context.Context, path string) error {
+	// Check parameters
+	if b.client == nil {
+		return errors.New("s3: client is nil")
+	}
+
+	key := pathutil.Join(b.prefix, path)
+	if _, err := b.client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket:       aws.String(b.bucket),
+		Key:          aws.String(key),
+		RequestPayer: b.requestPayer,
+	}); err != nil {
+		return s3Error(err, key)
+	}
+
+	// No error
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+
+// checkEncryption ensures the object is encrypted with the expected SSE-KMS
+// key. Key identifiers are matched against key ARN suffix.
+func (b *s3Backend) checkEncryption(key string, sse, kmsKeyID *string) error {
+	if b.sseKMSKeyID == "" {
+		return nil
+	}
+
+	actual := aws.StringValue(kmsKeyID)
+	switch {
+	case aws.StringValue(sse) != s3.ServerSideEncryptionAwsKms:
+	case actual == b.sseKMSKeyID:
+		return nil
+	case strings.HasSuffix(actual, "/"+strings.TrimPrefix(b.sseKMSKeyID, "key/")):
+		return nil
+	default:
+	}
+
+	return fmt.Errorf("s3: '%s' is not encrypted with the expected KMS key: %w", key, ErrUnexpectedEncryption)
+}
+
+// s3Error converts S3 errors to storage errors.
+func s3Error(err error, key string) error {
+	var rerr awserr.RequestFailure
+	if errors.As(err, &rerr) {
+		switch rerr.StatusCode() {
+		case http.StatusNotFound:
+			return fmt.Errorf("s3: '%s': %w", key, ErrNotFound)
+		case http.StatusNotModified:
+			return fmt.Errorf("s3: '%s': %w", key, ErrNotModified)
+		case http.StatusPreconditionFailed:
+			return fmt.Errorf("s3: '%s': %w", key, ErrPreconditionFailed)
+		default:
+		}
+	}
+
+	var aerr awserr.Error
+	if errors.As(err, &aerr) {
+		switch aerr.Code() {
+		case s3.ErrCodeNoSuchKey, "NoSuchVersion", "NotFound":
+			return fmt.Errorf("s3: '%s': %w", key, ErrNotFound)
+		default:
+			return fmt.Errorf("s3: unable to process request: %w", aerr)
+		}
+	}
+
+	return fmt.Errorf("s3: unable to process request: %w", err)
+}
+
+// joinPrefix joins backend and listing prefixes, keeping trailing separator.
+func joinPrefix(prefix, p string) string {
+	if prefix == "" {
+		return p
+	}
+	if p == "" {
+		return pathutil.Clean(prefix) + "/"
+	}
+
+	joined := pathutil.Join(prefix, p)
+	if p[len(p)-1] == '/' {
+		joined += "/"
+	}
+
+	return joined
+}
@@ -0,0 +1,187 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// fakeS3 serves ListObjectsV2 pages, continuation tokens are the next key
+// index.
+type fakeS3 struct {
+	s3iface.S3API
+	keys   []string
+	inputs []*s3.ListObjectsV2Input
+}
+
+func (f *fakeS3) ListObjectsV2WithContext(_ aws.Context, in *s3.ListObjectsV2Input, _ ...request.Option) (*s3.ListObjectsV2Output, error) {
+	f.inputs = append(f.inputs, in)
+
+	start := 0
+	if token := aws.StringValue(in.ContinuationToken); token != "" {
+		idx, err := strconv.Atoi(strings.TrimPrefix(token, "next-"))
+		if err != nil || !strings.HasPrefix(token, "next-") {
+			return nil, awserr.New("InvalidArgument", "invalid continuation token", nil)
+		}
+		start = idx
+	}
+
+	out := &s3.ListObjectsV2Output{}
+	end := start + int(aws.Int64Value(in.MaxKeys))
+	if end > len(f.keys) || aws.Int64Value(in.MaxKeys) == 0 {
+		end = len(f.keys)
+	}
+	for _, k := range f.keys[start:end] {
+		out.Contents = append(out.Contents, &s3.Object{
+			Key:  aws.String(k),
+			Size: aws.Int64(5),
+			ETag: aws.String(`"etag"`),
+		})
+	}
+	if end < len(f.keys) {
+		out.NextContinuationToken = aws.String(fmt.Sprintf("next-%d", end))
+	}
+
+	return out, nil
+}
+
+func (f *fakeS3) GetObjectWithContext(_ aws.Context, in *s3.GetObjectInput, _ ...request.Option) (*s3.GetObjectOutput, error) {
+	if aws.StringValue(in.Key) != "secrets/app/db" {
+		return nil, awserr.NewRequestFailure(awserr.New(s3.ErrCodeNoSuchKey, "not found", nil), http.StatusNotFound, "req")
+	}
+	if aws.StringValue(in.IfNoneMatch) == `"etag"` {
+		return nil, awserr.NewRequestFailure(awserr.New("NotModified", "not modified", nil), http.StatusNotModified, "req")
+	}
+
+	return &s3.GetObjectOutput{
+		Body:          io.NopCloser(strings.NewReader("value")),
+		ContentLength: aws.Int64(5),
+		ContentType:   aws.String("text/plain"),
+		ETag:          aws.String(`"etag"`),
+		VersionId:     aws.String("v1"),
+		LastModified:  aws.Time(time.Unix(1600000000, 0)),
+		Metadata:      map[string]*string{"Owner": aws.String("team")},
+	}, nil
+}
+
+func TestS3_Pagination(t *testing.T) {
+	client := &fakeS3{
+		keys: []string{"secrets/app/a", "secrets/app/b", "secrets/app/c", "secrets/app/d", "secrets/app/e"},
+	}
+	b := S3(client, "bucket", "secrets")
+
+	pages := [][]string{}
+	opts := &ListOptions{Prefix: "app/", MaxKeys: 2}
+	for i := 0; i < 10; i++ {
+		page, err := b.ListObjects(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		pages = append(pages, objectPaths(page.Objects))
+		if page.NextContinuationToken == "" {
+			break
+		}
+		opts.ContinuationToken = page.NextContinuationToken
+	}
+
+	// Paths are relative to the backend prefix
+	expected := [][]string{{"app/a", "app/b"}, {"app/c", "app/d"}, {"app/e"}}
+	if !reflect.DeepEqual(pages, expected) {
+		t.Errorf("unexpected pages %v, want %v", pages, expected)
+	}
+
+	// Request settings are forwarded
+	tokens := []string{}
+	for _, in := range client.inputs {
+		if aws.StringValue(in.Bucket) != "bucket" || aws.StringValue(in.Prefix) != "secrets/app/" || aws.Int64Value(in.MaxKeys) != 2 {
+			t.Errorf("unexpected list request %s", in)
+		}
+		tokens = append(tokens, aws.StringValue(in.ContinuationToken))
+	}
+	if !reflect.DeepEqual(tokens, []string{"", "next-2", "next-4"}) {
+		t.Errorf("unexpected continuation tokens %v", tokens)
+	}
+
+	// Invalid tokens are reported
+	if _, err := b.ListObjects(context.Background(), &ListOptions{ContinuationToken: "invalid"}); err == nil {
+		t.Error("expected an error for an invalid continuation token")
+	}
+}
+
+func TestS3_GetObject(t *testing.T) {
+	b := S3(&fakeS3{}, "bucket", "secrets")
+	ctx := context.Background()
+
+	obj, err := b.GetObject(ctx, "app/db")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer obj.Content.Close()
+
+	content, _ := io.ReadAll(obj.Content)
+	expected := &ObjectInfo{
+		Path:         "app/db",
+		Size:         5,
+		ETag:         `"etag"`,
+		VersionID:    "v1",
+		ContentType:  "text/plain",
+		LastModified: time.Unix(1600000000, 0),
+		Metadata:     map[string]string{"Owner": "team"},
+	}
+	if string(content) != "value" || !reflect.DeepEqual(obj.Info(), expected) {
+		t.Errorf("unexpected object %q %+v", content, obj.Info())
+	}
+
+	if _, err := b.GetObject(ctx, "app/missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+	if _, err := b.GetObjectWithOptions(ctx, "app/db", &GetOptions{IfNoneMatch: `"etag"`}); !errors.Is(err, ErrNotModified) {
+		t.Errorf("expected not modified error, got %v", err)
+	}
+}
+
+func TestJoinPrefix(t *testing.T) {
+	testCases := []struct {
+		prefix, p, want string
+	}{
+		{prefix: "", p: "app/", want: "app/"},
+		{prefix: "secrets", p: "", want: "secrets/"},
+		{prefix: "secrets/", p: "app", want: "secrets/app"},
+		{prefix: "secrets", p: "app/", want: "secrets/app/"},
+	}
+	for _, tc := range testCases {
+		if got := joinPrefix(tc.prefix, tc.p); got != tc.want {
+			t.Errorf("joinPrefix(%q, %q) = %q, want %q", tc.prefix, tc.p, got, tc.want)
+		}
+	}
+}
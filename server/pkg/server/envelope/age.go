@@ -0,0 +1,55 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"filippo.io/age"
+
+	"github.com/elastic/harp/pkg/sdk/value"
+)
+
+func ageTransformer(recipient string) (value.Transformer, error) {
+	// Decode recipient
+	r, err := age.ParseX25519Recipient(recipient)
+	if err != nil {
+		return nil, fmt.Errorf("envelope: invalid age recipient: %w", err)
+	}
+
+	return sealer(func(_ context.Context, input []byte) ([]byte, error) {
+		var out bytes.Buffer
+
+		// Encrypt for the recipient
+		w, err := age.Encrypt(&out, r)
+		if err != nil {
+			return nil, fmt.Errorf("envelope: unable to initialize age encryption: %w", err)
+		}
+		if _, err := w.Write(input); err != nil {
+			return nil, fmt.Errorf("envelope: unable to encrypt content: %w", err)
+		}
+		if err := w.Close(); err != nil {
+			return nil, fmt.Errorf("envelope: unable to finalize age encryption: %w", err)
+		}
+
+		// No error
+		return out.Bytes(), nil
+	}), nil
+}
@@ -0,0 +1,74 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"strings"
+
+	containerv1 "github.com/elastic/harp/api/gen/go/harp/container/v1"
+	"github.com/elastic/harp/pkg/container"
+	"github.com/elastic/harp/pkg/container/seal"
+	sealv1 "github.com/elastic/harp/pkg/container/seal/v1"
+	sealv2 "github.com/elastic/harp/pkg/container/seal/v2"
+	"github.com/elastic/harp/pkg/sdk/value"
+)
+
+// Content type of the container payload.
+const containerContentType = "application/octet-stream"
+
+func containerTransformer(publicKey string) (value.Transformer, error) {
+	// Select seal strategy from key version
+	var strategy seal.Strategy
+	switch {
+	case strings.HasPrefix(publicKey, sealv1.PublicKeyPrefix):
+		strategy = sealv1.New()
+	case strings.HasPrefix(publicKey, sealv2.PublicKeyPrefix):
+		strategy = sealv2.New()
+	default:
+		return nil, ErrUnsupportedRecipient
+	}
+
+	return sealer(func(_ context.Context, input []byte) ([]byte, error) {
+		// Wrap content in a container
+		c := &containerv1.Container{
+			Headers: &containerv1.Header{
+				ContentType: containerContentType,
+			},
+			Raw: input,
+		}
+
+		// Seal the container for the recipient
+		sealed, err := strategy.Seal(rand.Reader, c, publicKey)
+		if err != nil {
+			return nil, fmt.Errorf("envelope: unable to seal container: %w", err)
+		}
+
+		// Serialize container
+		var out bytes.Buffer
+		if err := container.Dump(&out, sealed); err != nil {
+			return nil, fmt.Errorf("envelope: unable to encode container: %w", err)
+		}
+
+		// No error
+		return out.Bytes(), nil
+	}), nil
+}
@@ -0,0 +1,94 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package envelope provides value transformers sealing content to a client
+// public key.
+package envelope
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/elastic/harp/pkg/sdk/value"
+)
+
+var (
+	// ErrUnsupportedRecipient is raised when the recipient key format is not supported.
+	ErrUnsupportedRecipient = errors.New("envelope: unsupported recipient key")
+	// ErrDecryptionNotSupported is raised when trying to reverse an envelope.
+	ErrDecryptionNotSupported = errors.New("envelope: decryption is not supported")
+)
+
+// Envelope describes the encoding produced by a transformer.
+type Envelope string
+
+const (
+	// Age produces a binary age file.
+	Age Envelope = "age"
+	// Container produces a sealed harp container.
+	Container Envelope = "container"
+	// JWE produces a JWE compact serialization.
+	JWE Envelope = "jwe"
+)
+
+// ContentType returns the HTTP content type of the given envelope.
+func (e Envelope) ContentType() string {
+	switch e {
+	case Age:
+		return "application/age"
+	case Container:
+		return "application/vnd.harp.v1.SealedContainer"
+	case JWE:
+		return "application/jose"
+	default:
+	}
+
+	return "application/octet-stream"
+}
+
+// Transformer returns an encryption transformer sealing values to the given
+// public key. Supported formats are age X25519 recipients (`age1...`) and harp
+// container identity public keys (`v1.sk.`, `v2.sk.`).
+func Transformer(recipient string) (value.Transformer, Envelope, error) {
+	recipient = strings.TrimSpace(recipient)
+
+	switch {
+	case strings.HasPrefix(recipient, "age1"):
+		t, err := ageTransformer(recipient)
+		return t, Age, err
+	case strings.HasPrefix(recipient, "v1.sk."), strings.HasPrefix(recipient, "v2.sk."):
+		t, err := containerTransformer(recipient)
+		return t, Container, err
+	default:
+	}
+
+	return nil, "", ErrUnsupportedRecipient
+}
+
+// -----------------------------------------------------------------------------
+
+// sealer implements value.Transformer for one-way envelopes.
+type sealer func(context.Context, []byte) ([]byte, error)
+
+func (s sealer) To(ctx context.Context, input []byte) ([]byte, error) {
+	return s(ctx, input)
+}
+
+func (s sealer) From(_ context.Context, _ []byte) ([]byte, error) {
+	return nil, ErrDecryptionNotSupported
+}
@@ -0,0 +1,241 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"io"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/awnumar/memguard"
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/elastic/harp/pkg/container"
+	"github.com/elastic/harp/pkg/container/seal"
+	sealv1 "github.com/elastic/harp/pkg/container/seal/v1"
+	sealv2 "github.com/elastic/harp/pkg/container/seal/v2"
+)
+
+var secret = []byte(`{"user":"admin","password":"s3cr3t"}`)
+
+func TestTransformer_Age(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transformer, e, err := Transformer(" " + identity.Recipient().String() + "\n")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e != Age || e.ContentType() != "application/age" {
+		t.Errorf("unexpected envelope %q (%s)", e, e.ContentType())
+	}
+
+	sealed, err := transformer.To(context.Background(), secret)
+	if err != nil {
+		t.Fatalf("unable to seal: %v", err)
+	}
+
+	// Open with the recipient identity
+	r, err := age.Decrypt(bytes.NewReader(sealed), identity)
+	if err != nil {
+		t.Fatalf("unable to decrypt: %v", err)
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, secret) {
+		t.Errorf("got %q, want %q", out, secret)
+	}
+
+	// Other identities can't open the envelope
+	other, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := age.Decrypt(bytes.NewReader(sealed), other); err == nil {
+		t.Error("expected decryption with another identity to fail")
+	}
+}
+
+func TestTransformer_Container(t *testing.T) {
+	testCases := []struct {
+		name     string
+		strategy seal.Strategy
+	}{
+		{name: "v1", strategy: sealv1.New()},
+		{name: "v2", strategy: sealv2.New()},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			publicKey, privateKey, err := tc.strategy.GenerateKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			transformer, e, err := Transformer(publicKey)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if e != Container || e.ContentType() != "application/vnd.harp.v1.SealedContainer" {
+				t.Errorf("unexpected envelope %q (%s)", e, e.ContentType())
+			}
+
+			sealed, err := transformer.To(context.Background(), secret)
+			if err != nil {
+				t.Fatalf("unable to seal: %v", err)
+			}
+
+			// Open with the container identity
+			c, err := container.Load(bytes.NewReader(sealed))
+			if err != nil {
+				t.Fatalf("unable to load container: %v", err)
+			}
+			unsealed, err := container.Unseal(c, memguard.NewBufferFromBytes([]byte(privateKey)))
+			if err != nil {
+				t.Fatalf("unable to unseal container: %v", err)
+			}
+			if !bytes.Equal(unsealed.Raw, secret) {
+				t.Errorf("got %q, want %q", unsealed.Raw, secret)
+			}
+			if unsealed.Headers.ContentType != containerContentType {
+				t.Errorf("unexpected payload content type %q", unsealed.Headers.ContentType)
+			}
+		})
+	}
+}
+
+func TestFromJWK(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name       string
+		privateKey interface{}
+		publicKey  interface{}
+		algorithm  jose.KeyAlgorithm
+	}{
+		{name: "ec", privateKey: ecKey, publicKey: &ecKey.PublicKey, algorithm: jose.ECDH_ES_A256KW},
+		{name: "rsa", privateKey: rsaKey, publicKey: &rsaKey.PublicKey, algorithm: jose.RSA_OAEP_256},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			raw, err := (&jose.JSONWebKey{Key: tc.publicKey, KeyID: "client"}).MarshalJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			transformer, e, err := FromJWK(raw)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if e != JWE || e.ContentType() != "application/jose" {
+				t.Errorf("unexpected envelope %q (%s)", e, e.ContentType())
+			}
+
+			sealed, err := transformer.To(context.Background(), secret)
+			if err != nil {
+				t.Fatalf("unable to seal: %v", err)
+			}
+
+			// Open with the private key
+			obj, err := jose.ParseEncrypted(string(sealed))
+			if err != nil {
+				t.Fatalf("unable to parse JWE: %v", err)
+			}
+			if got := jose.KeyAlgorithm(obj.Header.Algorithm); got != tc.algorithm {
+				t.Errorf("unexpected key algorithm %q", got)
+			}
+			if obj.Header.KeyID != "client" {
+				t.Errorf("unexpected key identifier %q", obj.Header.KeyID)
+			}
+			out, err := obj.Decrypt(tc.privateKey)
+			if err != nil {
+				t.Fatalf("unable to decrypt: %v", err)
+			}
+			if !bytes.Equal(out, secret) {
+				t.Errorf("got %q, want %q", out, secret)
+			}
+		})
+	}
+}
+
+func TestFromJWK_Invalid(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	symmetric, err := (&jose.JSONWebKey{Key: []byte("0123456789abcdef0123456789abcdef")}).MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	private, err := (&jose.JSONWebKey{Key: ecKey}).MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := FromJWK([]byte("not-a-jwk")); err == nil {
+		t.Error("expected an error for an invalid JWK")
+	}
+	if _, _, err := FromJWK(symmetric); err == nil {
+		t.Error("expected an error for a symmetric key")
+	}
+	if _, _, err := FromJWK(private); !errors.Is(err, ErrUnsupportedRecipient) {
+		t.Errorf("expected ErrUnsupportedRecipient for a private key, got %v", err)
+	}
+}
+
+func TestTransformer_Unsupported(t *testing.T) {
+	for _, recipient := range []string{"", "ssh-ed25519 AAAA", "v3.sk.AAAA"} {
+		if _, _, err := Transformer(recipient); !errors.Is(err, ErrUnsupportedRecipient) {
+			t.Errorf("Transformer(%q): expected ErrUnsupportedRecipient, got %v", recipient, err)
+		}
+	}
+	if _, _, err := Transformer("age1invalid"); err == nil {
+		t.Error("expected an error for an invalid age recipient")
+	}
+}
+
+func TestSealer_NoDecryption(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	transformer, _, err := Transformer(identity.Recipient().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := transformer.From(context.Background(), secret); !errors.Is(err, ErrDecryptionNotSupported) {
+		t.Errorf("expected ErrDecryptionNotSupported, got %v", err)
+	}
+}
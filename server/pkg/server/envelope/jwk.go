@@ -0,0 +1,80 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package envelope
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"fmt"
+
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/elastic/harp/pkg/sdk/value"
+)
+
+// FromJWK returns an encryption transformer sealing values to the given JSON
+// Web Key. EC keys use ECDH-ES+A256KW and RSA keys use RSA-OAEP-256.
+func FromJWK(raw []byte) (value.Transformer, Envelope, error) {
+	// Decode the key
+	var jwk jose.JSONWebKey
+	if err := jwk.UnmarshalJSON(raw); err != nil {
+		return nil, "", fmt.Errorf("envelope: invalid JWK: %w", err)
+	}
+	if !jwk.Valid() {
+		return nil, "", fmt.Errorf("envelope: invalid JWK")
+	}
+
+	// Select key management algorithm
+	var alg jose.KeyAlgorithm
+	switch jwk.Key.(type) {
+	case *ecdsa.PublicKey:
+		alg = jose.ECDH_ES_A256KW
+	case *rsa.PublicKey:
+		alg = jose.RSA_OAEP_256
+	default:
+		return nil, "", fmt.Errorf("envelope: JWK must be an EC or RSA public key: %w", ErrUnsupportedRecipient)
+	}
+
+	// Prepare encrypter
+	encrypter, err := jose.NewEncrypter(jose.A256GCM, jose.Recipient{
+		Algorithm: alg,
+		Key:       jwk.Key,
+		KeyID:     jwk.KeyID,
+	}, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("envelope: unable to initialize encrypter: %w", err)
+	}
+
+	return sealer(func(_ context.Context, input []byte) ([]byte, error) {
+		// Encrypt content
+		obj, err := encrypter.Encrypt(input)
+		if err != nil {
+			return nil, fmt.Errorf("envelope: unable to encrypt content: %w", err)
+		}
+
+		// Serialize as compact JWE
+		out, err := obj.CompactSerialize()
+		if err != nil {
+			return nil, fmt.Errorf("envelope: unable to serialize JWE: %w", err)
+		}
+
+		// No error
+		return []byte(out), nil
+	}), JWE, nil
+}
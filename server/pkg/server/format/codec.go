@@ -0,0 +1,255 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/magiconair/properties"
+	"github.com/pelletier/go-toml"
+	"github.com/subosito/gotenv"
+	"sigs.k8s.io/yaml"
+)
+
+// ErrUnsupportedFormat is raised when the content can't be decoded.
+var ErrUnsupportedFormat = errors.New("format: unable to decode content")
+
+// Decode the given content as a key/value object. When hint is empty, the
+// format is detected by trying JSON, TOML, YAML and dotenv decoders in order.
+func Decode(content []byte, hint Format) (map[string]interface{}, error) {
+	decoders := []Format{JSON, TOML, YAML, DotEnv}
+	if hint != "" && hint != Raw {
+		decoders = []Format{hint}
+	}
+
+	for _, f := range decoders {
+		data, err := decode(content, f)
+		if err == nil && data != nil {
+			return data, nil
+		}
+	}
+
+	return nil, ErrUnsupportedFormat
+}
+
+// Encode the given object using the given format.
+func Encode(data map[string]interface{}, f Format) ([]byte, error) {
+	switch f {
+	case JSON, Raw:
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(data); err != nil {
+			return nil, fmt.Errorf("format: unable to encode as JSON: %w", err)
+		}
+		return buf.Bytes(), nil
+	case YAML:
+		out, err := yaml.Marshal(data)
+		if err != nil {
+			return nil, fmt.Errorf("format: unable to encode as YAML: %w", err)
+		}
+		return out, nil
+	case TOML:
+		tree, err := toml.TreeFromMap(integers(data).(map[string]interface{}))
+		if err != nil {
+			return nil, fmt.Errorf("format: unable to encode as TOML: %w", err)
+		}
+		out, err := tree.Marshal()
+		if err != nil {
+			return nil, fmt.Errorf("format: unable to encode as TOML: %w", err)
+		}
+		return out, nil
+	case Properties:
+		p := properties.NewProperties()
+		flat := flatten(data, ".", false)
+		for _, k := range sortedKeys(flat) {
+			if _, _, err := p.Set(k, flat[k]); err != nil {
+				return nil, fmt.Errorf("format: unable to encode as properties: %w", err)
+			}
+		}
+		var buf bytes.Buffer
+		if _, err := p.Write(&buf, properties.UTF8); err != nil {
+			return nil, fmt.Errorf("format: unable to encode as properties: %w", err)
+		}
+		return buf.Bytes(), nil
+	case DotEnv:
+		var buf bytes.Buffer
+		flat := flatten(data, "_", true)
+		for _, k := range sortedKeys(flat) {
+			fmt.Fprintf(&buf, "%s=%s\n", k, quote(flat[k]))
+		}
+		return buf.Bytes(), nil
+	default:
+	}
+
+	return nil, fmt.Errorf("format: unsupported format '%s'", f)
+}
+
+// Field extracts a value from the given object using a dotted path.
+func Field(data map[string]interface{}, path string) (interface{}, bool) {
+	var current interface{} = data
+	for _, part := range strings.Split(path, ".") {
+		obj, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = obj[part]; !ok {
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+// Scalar returns the string representation of a decoded value.
+func Scalar(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	default:
+		out, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(out)
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+func decode(content []byte, f Format) (map[string]interface{}, error) {
+	var data map[string]interface{}
+
+	switch f {
+	case JSON:
+		if err := json.Unmarshal(content, &data); err != nil {
+			return nil, err
+		}
+	case YAML:
+		if err := yaml.Unmarshal(content, &data); err != nil {
+			return nil, err
+		}
+	case TOML:
+		tree, err := toml.LoadBytes(content)
+		if err != nil {
+			return nil, err
+		}
+		data = tree.ToMap()
+	case Properties:
+		p, err := properties.Load(content, properties.UTF8)
+		if err != nil {
+			return nil, err
+		}
+		data = map[string]interface{}{}
+		for k, v := range p.Map() {
+			data[k] = v
+		}
+	case DotEnv:
+		vars, err := gotenv.StrictParse(bytes.NewReader(content))
+		if err != nil {
+			return nil, err
+		}
+		if len(vars) == 0 {
+			return nil, ErrUnsupportedFormat
+		}
+		data = map[string]interface{}{}
+		for k, v := range vars {
+			data[k] = v
+		}
+	default:
+		return nil, fmt.Errorf("format: unsupported format '%s'", f)
+	}
+
+	return data, nil
+}
+
+func flatten(data map[string]interface{}, separator string, upper bool) map[string]string {
+	res := map[string]string{}
+
+	var walk func(prefix string, value interface{})
+	walk = func(prefix string, value interface{}) {
+		if obj, ok := value.(map[string]interface{}); ok {
+			for k, v := range obj {
+				key := k
+				if prefix != "" {
+					key = prefix + separator + k
+				}
+				walk(key, v)
+			}
+			return
+		}
+
+		if upper {
+			prefix = strings.ToUpper(prefix)
+		}
+		res[prefix] = Scalar(value)
+	}
+	walk("", data)
+
+	return res
+}
+
+// integers converts integral float64 values decoded from JSON to int64 so that
+// they are not encoded as floats by TOML encoder.
+func integers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			res[k] = integers(item)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, item := range v {
+			res[i] = integers(item)
+		}
+		return res
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < math.MaxInt64 {
+			return int64(v)
+		}
+	}
+
+	return value
+}
+
+func quote(v string) string {
+	if strings.ContainsAny(v, " \t\n\"'#$\\") {
+		return strconv.Quote(v)
+	}
+
+	return v
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
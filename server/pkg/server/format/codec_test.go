@@ -0,0 +1,174 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package format
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	data := map[string]interface{}{
+		"user": "admin",
+		"port": float64(5432),
+		"tls": map[string]interface{}{
+			"enabled": true,
+			"ca":      "-----BEGIN CERTIFICATE-----\nMII",
+		},
+	}
+
+	testCases := []struct {
+		format Format
+		want   string
+	}{
+		{
+			format: JSON,
+			want:   "{\"port\":5432,\"tls\":{\"ca\":\"-----BEGIN CERTIFICATE-----\\nMII\",\"enabled\":true},\"user\":\"admin\"}\n",
+		},
+		{
+			format: Raw,
+			want:   "{\"port\":5432,\"tls\":{\"ca\":\"-----BEGIN CERTIFICATE-----\\nMII\",\"enabled\":true},\"user\":\"admin\"}\n",
+		},
+		{
+			format: YAML,
+			want:   "port: 5432\ntls:\n  ca: |-\n    -----BEGIN CERTIFICATE-----\n    MII\n  enabled: true\nuser: admin\n",
+		},
+		{
+			format: Properties,
+			want:   "port = 5432\ntls.ca = -----BEGIN CERTIFICATE-----\\nMII\ntls.enabled = true\nuser = admin\n",
+		},
+		{
+			format: DotEnv,
+			want:   "PORT=5432\nTLS_CA=\"-----BEGIN CERTIFICATE-----\\nMII\"\nTLS_ENABLED=true\nUSER=admin\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(string(tc.format), func(t *testing.T) {
+			out, err := Encode(data, tc.format)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(out) != tc.want {
+				t.Errorf("Encode() = %q, want %q", out, tc.want)
+			}
+		})
+	}
+}
+
+func TestEncode_TOML(t *testing.T) {
+	data := map[string]interface{}{
+		"user": "admin",
+		"port": float64(5432),
+		"tls": map[string]interface{}{
+			"enabled": true,
+		},
+	}
+
+	out, err := Encode(data, TOML)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Integral numbers are not encoded as floats
+	decoded, err := Decode(out, TOML)
+	if err != nil {
+		t.Fatalf("unable to decode TOML output %q: %v", out, err)
+	}
+	want := map[string]interface{}{
+		"user": "admin",
+		"port": int64(5432),
+		"tls": map[string]interface{}{
+			"enabled": true,
+		},
+	}
+	if !reflect.DeepEqual(decoded, want) {
+		t.Errorf("Decode(Encode()) = %#v, want %#v", decoded, want)
+	}
+}
+
+func TestEncode_Unsupported(t *testing.T) {
+	if _, err := Encode(map[string]interface{}{}, "xml"); err == nil {
+		t.Error("expected an error for an unsupported format")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		hint    Format
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{name: "json", content: `{"user":"admin"}`, want: map[string]interface{}{"user": "admin"}},
+		{name: "toml", content: "user = \"admin\"\n", want: map[string]interface{}{"user": "admin"}},
+		{name: "yaml", content: "user: admin\n", want: map[string]interface{}{"user": "admin"}},
+		{name: "dotenv", content: "USER=admin\n", hint: DotEnv, want: map[string]interface{}{"USER": "admin"}},
+		{name: "properties", content: "user = admin\n", hint: Properties, want: map[string]interface{}{"user": "admin"}},
+		{name: "hint mismatch", content: "user: admin\n", hint: JSON, wantErr: true},
+		{name: "raw", content: "s3cr3t", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Decode([]byte(tc.content), tc.hint)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Decode() = %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestField(t *testing.T) {
+	data := map[string]interface{}{
+		"user": "admin",
+		"tls": map[string]interface{}{
+			"enabled": true,
+		},
+	}
+
+	if v, ok := Field(data, "tls.enabled"); !ok || v != true {
+		t.Errorf("unexpected nested field %v, %v", v, ok)
+	}
+	if _, ok := Field(data, "user.name"); ok {
+		t.Error("expected scalar traversal to fail")
+	}
+	if _, ok := Field(data, "password"); ok {
+		t.Error("expected missing field to fail")
+	}
+}
+
+func TestScalar(t *testing.T) {
+	testCases := []struct {
+		value interface{}
+		want  string
+	}{
+		{value: "admin", want: "admin"},
+		{value: nil, want: ""},
+		{value: true, want: "true"},
+		{value: float64(5432), want: "5432"},
+		{value: []interface{}{"a", "b"}, want: `["a","b"]`},
+	}
+	for _, tc := range testCases {
+		if got := Scalar(tc.value); got != tc.want {
+			t.Errorf("Scalar(%#v) = %q, want %q", tc.value, got, tc.want)
+		}
+	}
+}
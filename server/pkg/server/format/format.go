@@ -0,0 +1,166 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package format provides secret content format detection and conversion.
+package format
+
+import (
+	"mime"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Format represents a secret content format.
+type Format string
+
+const (
+	// Raw format serves content as-is.
+	Raw Format = "raw"
+	// JSON format.
+	JSON Format = "json"
+	// YAML format.
+	YAML Format = "yaml"
+	// TOML format.
+	TOML Format = "toml"
+	// Properties format (Java .properties).
+	Properties Format = "properties"
+	// DotEnv format (KEY=value).
+	DotEnv Format = "dotenv"
+)
+
+var contentTypes = map[Format]string{
+	Raw:        "application/octet-stream",
+	JSON:       "application/json; charset=utf-8",
+	YAML:       "application/yaml; charset=utf-8",
+	TOML:       "application/toml; charset=utf-8",
+	Properties: "text/x-java-properties; charset=utf-8",
+	DotEnv:     "text/x-dotenv; charset=utf-8",
+}
+
+var mediaTypes = map[string]Format{
+	"application/octet-stream": Raw,
+	"application/json":         JSON,
+	"text/json":                JSON,
+	"application/yaml":         YAML,
+	"application/x-yaml":       YAML,
+	"text/yaml":                YAML,
+	"text/x-yaml":              YAML,
+	"application/toml":         TOML,
+	"text/x-toml":              TOML,
+	"text/x-java-properties":   Properties,
+	"text/x-properties":        Properties,
+	"text/x-dotenv":            DotEnv,
+	"application/x-env":        DotEnv,
+}
+
+// Parse returns the format matching the given name.
+func Parse(name string) (Format, bool) {
+	switch strings.ToLower(name) {
+	case "raw":
+		return Raw, true
+	case "json":
+		return JSON, true
+	case "yaml", "yml":
+		return YAML, true
+	case "toml":
+		return TOML, true
+	case "properties":
+		return Properties, true
+	case "dotenv", "env":
+		return DotEnv, true
+	default:
+	}
+
+	return "", false
+}
+
+// FromPath returns the format matching the path extension.
+func FromPath(p string) (Format, bool) {
+	ext := strings.TrimPrefix(filepath.Ext(p), ".")
+	if ext == "" {
+		return "", false
+	}
+
+	return Parse(ext)
+}
+
+// ContentType returns the HTTP content type of the given format.
+func ContentType(f Format) string {
+	if ct, ok := contentTypes[f]; ok {
+		return ct
+	}
+
+	return contentTypes[Raw]
+}
+
+// Negotiate returns the preferred supported format from an HTTP Accept header
+// value. The boolean is false when the header has no supported media type,
+// an empty header or a wildcard returns Raw.
+func Negotiate(accept string) (Format, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return Raw, true
+	}
+
+	type candidate struct {
+		format  Format
+		quality float64
+		order   int
+	}
+
+	candidates := []candidate{}
+	for i, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		// Extract quality factor
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil {
+				quality = v
+			}
+		}
+		if quality <= 0 {
+			continue
+		}
+
+		// Resolve format
+		f, ok := mediaTypes[mediaType]
+		switch {
+		case ok:
+		case mediaType == "*/*", mediaType == "application/*", mediaType == "text/*":
+			f = Raw
+		default:
+			continue
+		}
+
+		candidates = append(candidates, candidate{format: f, quality: quality, order: i})
+	}
+	if len(candidates) == 0 {
+		return "", false
+	}
+
+	// Highest quality first, declaration order otherwise
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].quality > candidates[j].quality
+	})
+
+	return candidates[0].format, true
+}
@@ -0,0 +1,108 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package format
+
+import "testing"
+
+func TestNegotiate(t *testing.T) {
+	testCases := []struct {
+		name   string
+		accept string
+		want   Format
+		wantOK bool
+	}{
+		{name: "empty", accept: "", want: Raw, wantOK: true},
+		{name: "wildcard", accept: "*/*", want: Raw, wantOK: true},
+		{name: "text wildcard", accept: "text/*", want: Raw, wantOK: true},
+		{name: "json", accept: "application/json", want: JSON, wantOK: true},
+		{name: "yaml alias", accept: "application/x-yaml", want: YAML, wantOK: true},
+		{name: "toml", accept: "application/toml", want: TOML, wantOK: true},
+		{name: "properties", accept: "text/x-java-properties", want: Properties, wantOK: true},
+		{name: "dotenv", accept: "text/x-dotenv", want: DotEnv, wantOK: true},
+		{name: "media type parameters", accept: "application/json; charset=utf-8", want: JSON, wantOK: true},
+		{name: "declaration order", accept: "application/yaml, application/json", want: YAML, wantOK: true},
+		{name: "quality factor", accept: "application/yaml;q=0.5, application/json", want: JSON, wantOK: true},
+		{name: "wildcard fallback", accept: "application/xml, */*;q=0.1", want: Raw, wantOK: true},
+		{name: "unsupported skipped", accept: "text/html, application/toml;q=0.2", want: TOML, wantOK: true},
+		{name: "zero quality", accept: "application/json;q=0", wantOK: false},
+		{name: "unsupported", accept: "text/plain", wantOK: false},
+		{name: "invalid", accept: ";;;", wantOK: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := Negotiate(tc.accept)
+			if ok != tc.wantOK {
+				t.Fatalf("Negotiate(%q) ok = %v, want %v", tc.accept, ok, tc.wantOK)
+			}
+			if got != tc.want {
+				t.Errorf("Negotiate(%q) = %q, want %q", tc.accept, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name   string
+		want   Format
+		wantOK bool
+	}{
+		{name: "raw", want: Raw, wantOK: true},
+		{name: "JSON", want: JSON, wantOK: true},
+		{name: "yml", want: YAML, wantOK: true},
+		{name: "toml", want: TOML, wantOK: true},
+		{name: "properties", want: Properties, wantOK: true},
+		{name: "env", want: DotEnv, wantOK: true},
+		{name: "xml", wantOK: false},
+		{name: "", wantOK: false},
+	}
+	for _, tc := range testCases {
+		got, ok := Parse(tc.name)
+		if ok != tc.wantOK || got != tc.want {
+			t.Errorf("Parse(%q) = %q, %v, want %q, %v", tc.name, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
+
+func TestFromPath(t *testing.T) {
+	testCases := []struct {
+		path   string
+		want   Format
+		wantOK bool
+	}{
+		{path: "app/config.yaml", want: YAML, wantOK: true},
+		{path: "app/.env", want: DotEnv, wantOK: true},
+		{path: "app/database", wantOK: false},
+		{path: "app/archive.tar", wantOK: false},
+	}
+	for _, tc := range testCases {
+		got, ok := FromPath(tc.path)
+		if ok != tc.wantOK || got != tc.want {
+			t.Errorf("FromPath(%q) = %q, %v, want %q, %v", tc.path, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
+
+func TestContentType(t *testing.T) {
+	if got := ContentType(YAML); got != "application/yaml; charset=utf-8" {
+		t.Errorf("unexpected YAML content type %q", got)
+	}
+	if got := ContentType("unknown"); got != "application/octet-stream" {
+		t.Errorf("unexpected fallback content type %q", got)
+	}
+}
@@ -21,22 +21,25 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/url"
-	"strings"
 	"sync"
 
-	"github.com/gosimple/slug"
-
+	"github.com/elastic/harp-plugins/server/pkg/server/namespace"
 	"github.com/elastic/harp-plugins/server/pkg/server/storage"
+	pathDecorator "github.com/elastic/harp-plugins/server/pkg/server/storage/decorators/path"
 	valueDecorator "github.com/elastic/harp-plugins/server/pkg/server/storage/decorators/value"
+	"github.com/elastic/harp/pkg/sdk/log"
 	"github.com/elastic/harp/pkg/sdk/value/encryption"
 )
 
 // Backend declares backend manager contract.
 type Backend interface {
 	GetSecret(context.Context, string, string) ([]byte, error)
+	GetSecretWithMetadata(context.Context, string, string) (*storage.Secret, error)
 	Register(context.Context, string, string) error
 	GetNameSpace(context.Context, string) (storage.Engine, error)
+	Close() error
 }
 
 var (
@@ -58,6 +61,7 @@ func Default() Backend {
 type backendManager struct {
 	sync.RWMutex
 	backends map[string]storage.Engine
+	closers  []io.Closer
 }
 
 func (bm *backendManager) GetSecret(ctx context.Context, namespace, identifier string) ([]byte, error) {
@@ -71,41 +75,77 @@ func (bm *backendManager) GetSecret(ctx context.Context, namespace, identifier s
 	return engine.Get(ctx, identifier)
 }
 
-func (bm *backendManager) Register(ctx context.Context, namespace, uri string) error {
+func (bm *backendManager) GetSecretWithMetadata(ctx context.Context, namespace, identifier string) (*storage.Secret, error) {
+	// Check backend registration
+	engine, err := bm.GetNameSpace(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	// Delegate to engine
+	return storage.GetWithMetadata(ctx, engine, identifier)
+}
+
+func (bm *backendManager) Register(ctx context.Context, ns, uri string) error {
+	// Validate namespace identifier
+	id, err := namespace.Parse(ns)
+	if err != nil {
+		return fmt.Errorf("unable to register secret backend: %w", err)
+	}
+
 	// Check backend registration
-	_, err := bm.GetNameSpace(ctx, namespace)
-	if err == nil {
-		return ErrNamespaceAlreadyRegistered
+	if _, err := bm.GetNameSpace(ctx, id); err == nil {
+		return fmt.Errorf("%w: '%s'", ErrNamespaceAlreadyRegistered, id)
 	}
 
 	// Load backend settings
 	engine, err := storage.Build(uri)
 	if err != nil {
-		return fmt.Errorf("unable to build secret backend (%s:%s): %w", namespace, uri, err)
+		return fmt.Errorf("unable to build secret backend (%s:%s): %w", id, storage.RedactURL(uri), err)
 	}
 
+	// Engines holding resources are released on close
+	closer, _ := engine.(io.Closer)
+
 	// Add encryption backend
 	engine, err = wrapEncryptionEngine(uri, engine)
 	if err != nil {
-		return fmt.Errorf("unable to wrap encryption engine with secret backend (%s:%s): %w", namespace, uri, err)
+		closeEngine(closer)
+		return fmt.Errorf("unable to wrap encryption engine with secret backend (%s:%s): %w", id, storage.RedactURL(uri), err)
+	}
+
+	// Add path mapping
+	engine, err = wrapPathEngine(uri, engine)
+	if err != nil {
+		closeEngine(closer)
+		return fmt.Errorf("unable to wrap path mapping engine with secret backend (%s:%s): %w", id, storage.RedactURL(uri), err)
 	}
 
 	// Add to backend map
 	bm.Lock()
-	bm.backends[clean(namespace)] = engine
+	bm.backends[id] = engine
+	if closer != nil {
+		bm.closers = append(bm.closers, closer)
+	}
 	bm.Unlock()
 
 	// Return no error
 	return nil
 }
 
-func (bm *backendManager) GetNameSpace(ctx context.Context, namespace string) (storage.Engine, error) {
+func (bm *backendManager) GetNameSpace(ctx context.Context, ns string) (storage.Engine, error) {
 	// Lock read
 	bm.RLock()
 	defer bm.RUnlock()
 
+	// Validate namespace identifier
+	id, err := namespace.Parse(ns)
+	if err != nil {
+		return nil, ErrNamespaceNotFound
+	}
+
 	// Check backend registration
-	engine, ok := bm.backends[clean(namespace)]
+	engine, ok := bm.backends[id]
 	if !ok {
 		return nil, ErrNamespaceNotFound
 	}
@@ -114,20 +154,35 @@ func (bm *backendManager) GetNameSpace(ctx context.Context, namespace string) (s
 	return engine, nil
 }
 
+func (bm *backendManager) Close() error {
+	bm.Lock()
+	defer bm.Unlock()
+
+	// Release engine resources
+	var errClose error
+	for _, c := range bm.closers {
+		if err := c.Close(); err != nil && errClose == nil {
+			errClose = fmt.Errorf("unable to close secret backend: %w", err)
+		}
+	}
+	bm.closers = nil
+
+	return errClose
+}
+
 // -----------------------------------------------------------------------------
 
-func clean(ns string) string {
-	// Remove any starting "/"
-	ns = strings.TrimPrefix(ns, "/")
-	// Slugify
-	return slug.Make(ns)
+func closeEngine(closer io.Closer) {
+	if closer != nil {
+		log.CheckErr("Unable to close secret backend", closer.Close())
+	}
 }
 
 func wrapEncryptionEngine(uri string, engine storage.Engine) (storage.Engine, error) {
 	// Parse URL first
 	u, err := url.Parse(uri)
 	if err != nil {
-		return nil, fmt.Errorf("backend: unable to parse backend url: %w", err)
+		return nil, errors.New("backend: unable to parse backend url")
 	}
 
 	// Parse parameters to wrap engine with at-rest / in-transit encryption
@@ -151,3 +206,26 @@ func wrapEncryptionEngine(uri string, engine storage.Engine) (storage.Engine, er
 	// No error
 	return engine, nil
 }
+
+func wrapPathEngine(uri string, engine storage.Engine) (storage.Engine, error) {
+	// Parse URL first
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, errors.New("backend: unable to parse backend url")
+	}
+
+	// Parse path mapping parameters
+	mapping, err := pathDecorator.FromQuery(u.Query())
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize path mapping: %w", err)
+	}
+
+	// mapping is defined
+	if mapping != nil {
+		// Wrap engine using path mapping decorator
+		engine = pathDecorator.Mapper(mapping)(engine)
+	}
+
+	// No error
+	return engine, nil
+}
@@ -0,0 +1,47 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package metrics exposes server counters using expvar.
+package metrics
+
+import (
+	"expvar"
+	"net/http"
+	"sync"
+)
+
+var mu sync.Mutex
+
+// Map returns the named expvar map, it is created on first usage.
+func Map(name string) *expvar.Map {
+	mu.Lock()
+	defer mu.Unlock()
+
+	// Reuse existing variable
+	if v := expvar.Get(name); v != nil {
+		if m, ok := v.(*expvar.Map); ok {
+			return m
+		}
+	}
+
+	return expvar.NewMap(name)
+}
+
+// Handler returns an HTTP handler serving all variables as JSON.
+func Handler() http.Handler {
+	return expvar.Handler()
+}
@@ -0,0 +1,97 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package namespace validates hierarchical namespace identifiers.
+package namespace
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// Root is the namespace used when none is given.
+	Root = "root"
+	// Separator separates nested namespace names.
+	Separator = "/"
+)
+
+// ErrInvalid is raised when a namespace identifier is not valid.
+var ErrInvalid = errors.New("namespace: invalid identifier")
+
+var segmentPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9_-]*[a-z0-9])?$`)
+
+// Parse validates the given identifier and returns its canonical form without
+// leading and trailing separators. Identifiers are case-sensitive, names are
+// not slugified so that distinct identifiers never collide.
+func Parse(id string) (string, error) {
+	canonical := strings.Trim(id, Separator)
+
+	// Check arguments
+	if canonical == "" {
+		return "", fmt.Errorf("%w: namespace is empty", ErrInvalid)
+	}
+
+	for _, name := range strings.Split(canonical, Separator) {
+		if segmentPattern.MatchString(name) {
+			continue
+		}
+		if name != canonical {
+			return "", fmt.Errorf("%w: '%s' contains an invalid name '%s', only lowercase letters, digits, '-' and '_' are allowed", ErrInvalid, id, name)
+		}
+		return "", fmt.Errorf("%w: '%s', only lowercase letters, digits, '-' and '_' are allowed", ErrInvalid, id)
+	}
+
+	// No error
+	return canonical, nil
+}
+
+// Join returns the identifier of the namespace nested in the given parents,
+// following Vault Enterprise semantics: the X-Vault-Namespace header value is
+// joined with the namespace path given in the request. Empty parts are
+// ignored, and the root namespace is returned if all parts are empty.
+func Join(parts ...string) (string, error) {
+	names := []string{}
+	for _, p := range parts {
+		if p = strings.Trim(p, Separator); p != "" {
+			names = append(names, p)
+		}
+	}
+	if len(names) == 0 {
+		return Root, nil
+	}
+
+	return Parse(strings.Join(names, Separator))
+}
+
+// Longest returns the most nested namespace of the given set prefixing the
+// given path, and the remaining path.
+func Longest(namespaces map[string]struct{}, p string) (ns, rest string, ok bool) {
+	p = strings.Trim(p, Separator)
+	for candidate := range namespaces {
+		if p != candidate && !strings.HasPrefix(p, candidate+Separator) {
+			continue
+		}
+		if len(candidate) > len(ns) {
+			ns, rest, ok = candidate, strings.TrimPrefix(p[len(candidate):], Separator), true
+		}
+	}
+
+	return ns, rest, ok
+}
@@ -0,0 +1,127 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package namespace
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name    string
+		id      string
+		want    string
+		wantErr bool
+	}{
+		{name: "single", id: "production", want: "production"},
+		{name: "digits", id: "team1", want: "team1"},
+		{name: "inner dash and underscore", id: "team-a_b", want: "team-a_b"},
+		{name: "single character", id: "a", want: "a"},
+		{name: "nested", id: "team/app/production", want: "team/app/production"},
+		{name: "trimmed separators", id: "/team/app/", want: "team/app"},
+		{name: "empty", id: "", wantErr: true},
+		{name: "separators only", id: "//", wantErr: true},
+		{name: "uppercase", id: "Production", wantErr: true},
+		{name: "leading dash", id: "-team", wantErr: true},
+		{name: "trailing underscore", id: "team_", wantErr: true},
+		{name: "dot", id: "team.app", wantErr: true},
+		{name: "space", id: "team app", wantErr: true},
+		{name: "empty segment", id: "team//app", wantErr: true},
+		{name: "invalid nested segment", id: "team/App", wantErr: true},
+		{name: "parent reference", id: "team/../admin", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Parse(tc.id)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Parse(%q) error = %v, wantErr %v", tc.id, err, tc.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalid) {
+				t.Errorf("expected ErrInvalid, got %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("Parse(%q) = %q, want %q", tc.id, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestJoin(t *testing.T) {
+	testCases := []struct {
+		name    string
+		parts   []string
+		want    string
+		wantErr bool
+	}{
+		{name: "no parts", want: Root},
+		{name: "empty parts", parts: []string{"", "/"}, want: Root},
+		{name: "single", parts: []string{"team"}, want: "team"},
+		{name: "header and path", parts: []string{"team", "app"}, want: "team/app"},
+		{name: "nested parts", parts: []string{"team/app", "/production/"}, want: "team/app/production"},
+		{name: "empty header", parts: []string{"", "team/app"}, want: "team/app"},
+		{name: "invalid part", parts: []string{"team", "App"}, wantErr: true},
+		{name: "empty nested segment", parts: []string{"team//app"}, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Join(tc.parts...)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Join(%q) error = %v, wantErr %v", tc.parts, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("Join(%q) = %q, want %q", tc.parts, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLongest(t *testing.T) {
+	namespaces := map[string]struct{}{
+		"team":            {},
+		"team/app":        {},
+		"team/app/legacy": {},
+		"platform":        {},
+	}
+
+	testCases := []struct {
+		name     string
+		path     string
+		wantNS   string
+		wantRest string
+		wantOK   bool
+	}{
+		{name: "exact", path: "team", wantNS: "team", wantOK: true},
+		{name: "exact with separators", path: "/team/", wantNS: "team", wantOK: true},
+		{name: "secret in namespace", path: "team/db", wantNS: "team", wantRest: "db", wantOK: true},
+		{name: "nested namespace", path: "team/app/db/password", wantNS: "team/app", wantRest: "db/password", wantOK: true},
+		{name: "most nested namespace", path: "team/app/legacy/db", wantNS: "team/app/legacy", wantRest: "db", wantOK: true},
+		{name: "segment boundary", path: "teamx/db", wantOK: false},
+		{name: "nested segment boundary", path: "team/application/db", wantNS: "team", wantRest: "application/db", wantOK: true},
+		{name: "unknown", path: "other/db", wantOK: false},
+		{name: "empty", path: "", wantOK: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ns, rest, ok := Longest(namespaces, tc.path)
+			if ok != tc.wantOK || ns != tc.wantNS || rest != tc.wantRest {
+				t.Errorf("Longest(%q) = %q, %q, %v, want %q, %q, %v", tc.path, ns, rest, ok, tc.wantNS, tc.wantRest, tc.wantOK)
+			}
+		})
+	}
+}
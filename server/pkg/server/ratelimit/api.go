@@ -0,0 +1,132 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package ratelimit provides request rate and backend concurrency limits.
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// ErrLimitExceeded is raised when a request is rejected by a limit.
+var ErrLimitExceeded = errors.New("ratelimit: limit exceeded")
+
+// Scope identifies the limit that rejected a request.
+type Scope string
+
+const (
+	// ScopeClient identifies per client limits.
+	ScopeClient Scope = "client"
+	// ScopeNamespace identifies per namespace limits.
+	ScopeNamespace Scope = "namespace"
+	// ScopeConcurrency identifies the backend concurrency limit.
+	ScopeConcurrency Scope = "concurrency"
+)
+
+// Policy describes a token bucket.
+type Policy struct {
+	// Rate is the number of allowed requests per second, 0 disables the limit.
+	Rate float64
+	// Burst is the maximum number of requests allowed at once.
+	Burst int
+}
+
+// Enabled returns true if the policy limits requests.
+func (p Policy) Enabled() bool {
+	return p.Rate > 0
+}
+
+// Options describes limiter settings.
+type Options struct {
+	Client             Policy
+	Namespace          Policy
+	Namespaces         map[string]Policy
+	MaxConcurrentCalls int
+	ConcurrencyWait    time.Duration
+	IdleTimeout        time.Duration
+	// TrustedProxies lists the peer networks allowed to give the client
+	// address using X-Forwarded-For or X-Real-IP headers.
+	TrustedProxies []*net.IPNet
+}
+
+// ParseNetworks parses the given IP addresses or CIDR ranges.
+func ParseNetworks(values []string) ([]*net.IPNet, error) {
+	res := []*net.IPNet{}
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+
+		// Single address
+		if ip := net.ParseIP(v); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			res = append(res, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, fmt.Errorf("ratelimit: invalid trusted proxy '%s': %w", v, err)
+		}
+		res = append(res, network)
+	}
+
+	// No error
+	return res, nil
+}
+
+// Error describes a rejected request.
+type Error struct {
+	Scope      Scope
+	RetryAfter time.Duration
+}
+
+// Error returns the error message.
+func (e *Error) Error() string {
+	return fmt.Sprintf("ratelimit: %s limit exceeded, retry after %s", e.Scope, e.RetryAfter)
+}
+
+// Is returns true for ErrLimitExceeded comparison.
+func (e *Error) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+// RetryAfter returns the delay to wait before retrying the rejected request.
+func RetryAfter(err error) (time.Duration, bool) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.RetryAfter, true
+	}
+
+	return 0, false
+}
+
+// retryAfterSeconds returns the Retry-After header value, rounded up to the
+// next second.
+func retryAfterSeconds(d time.Duration) int64 {
+	seconds := int64((d + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		return 1
+	}
+
+	return seconds
+}
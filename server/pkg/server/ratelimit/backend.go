@@ -0,0 +1,115 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package ratelimit
+
+import (
+	"context"
+
+	"github.com/elastic/harp-plugins/server/pkg/server/manager"
+	"github.com/elastic/harp-plugins/server/pkg/server/storage"
+)
+
+// Backend returns a backend manager decorator enforcing namespace and
+// backend concurrency limits.
+func Backend(bm manager.Backend, l *Limiter) manager.Backend {
+	return &limitedBackend{
+		Backend: bm,
+		limiter: l,
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+type limitedBackend struct {
+	manager.Backend
+	limiter *Limiter
+}
+
+func (b *limitedBackend) GetSecret(ctx context.Context, namespace, identifier string) ([]byte, error) {
+	// Check backend registration
+	engine, err := b.GetNameSpace(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	// Delegate to limited engine
+	return engine.Get(ctx, identifier)
+}
+
+func (b *limitedBackend) GetSecretWithMetadata(ctx context.Context, namespace, identifier string) (*storage.Secret, error) {
+	// Check backend registration
+	engine, err := b.GetNameSpace(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	// Delegate to limited engine
+	return storage.GetWithMetadata(ctx, engine, identifier)
+}
+
+func (b *limitedBackend) GetNameSpace(ctx context.Context, namespace string) (storage.Engine, error) {
+	// Delegate to original manager
+	engine, err := b.Backend.GetNameSpace(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	// No error
+	return &limitedEngine{
+		next:      engine,
+		namespace: namespace,
+		limiter:   b.limiter,
+	}, nil
+}
+
+type limitedEngine struct {
+	next      storage.Engine
+	namespace string
+	limiter   *Limiter
+}
+
+func (e *limitedEngine) Get(ctx context.Context, id string) ([]byte, error) {
+	secret, err := e.GetWithMetadata(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return secret.Value, nil
+}
+
+func (e *limitedEngine) GetWithMetadata(ctx context.Context, id string) (*storage.Secret, error) {
+	// Check namespace bucket
+	if err := e.limiter.AllowNamespace(e.namespace); err != nil {
+		return nil, err
+	}
+
+	// Wait for a backend call slot
+	release, err := e.limiter.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// Delegate to original storage engine
+	return storage.GetWithMetadata(ctx, e.next, id)
+}
+
+// Unwrap returns the limited engine.
+func (e *limitedEngine) Unwrap() storage.Engine {
+	return e.next
+}
@@ -0,0 +1,83 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor returns a gRPC interceptor enforcing client limits.
+func UnaryServerInterceptor(l *Limiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		// Check client bucket
+		if err := l.AllowClient(clientFromContext(ctx)); err != nil {
+			return nil, Status(ctx, err)
+		}
+
+		// Delegate to handler
+		return handler(ctx, req)
+	}
+}
+
+// Status converts a limit error to a ResourceExhausted status and sets the
+// retry-after response header. It returns nil for other errors.
+func Status(ctx context.Context, err error) error {
+	var e *Error
+	if !errors.As(err, &e) {
+		return nil
+	}
+
+	// Ignore header errors, the status is still meaningful
+	_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.FormatInt(retryAfterSeconds(e.RetryAfter), 10)))
+
+	return status.Error(codes.ResourceExhausted, e.Error())
+}
+
+// -----------------------------------------------------------------------------
+
+// clientFromContext returns the client certificate common name if any, the
+// client IP address otherwise.
+func clientFromContext(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "unknown"
+	}
+
+	if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(tlsInfo.State.PeerCertificates) > 0 {
+		if cn := tlsInfo.State.PeerCertificates[0].Subject.CommonName; cn != "" {
+			return "cn:" + cn
+		}
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		host = p.Addr.String()
+	}
+
+	return "ip:" + host
+}
@@ -0,0 +1,103 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package ratelimit
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Middleware returns an HTTP middleware enforcing client limits. It must be
+// registered before any middleware rewriting the request remote address from
+// forwarded headers, these headers are only honoured for trusted proxies.
+func Middleware(l *Limiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Check client bucket
+			if err := l.AllowClient(l.clientFromRequest(r)); err != nil {
+				WriteError(w, err)
+				return
+			}
+
+			// Delegate to next handler
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// WriteError sends a 429 response with Retry-After header when the given error
+// is a limit error. It returns false for other errors.
+func WriteError(w http.ResponseWriter, err error) bool {
+	var e *Error
+	if !errors.As(err, &e) {
+		return false
+	}
+
+	w.Header().Set("Retry-After", strconv.FormatInt(retryAfterSeconds(e.RetryAfter), 10))
+	http.Error(w, "too many requests", http.StatusTooManyRequests)
+
+	return true
+}
+
+// -----------------------------------------------------------------------------
+
+// clientFromRequest returns the client certificate common name if any, the
+// client IP address otherwise. The address is given by the connection peer, or
+// by the forwarded headers set by a trusted proxy.
+func (l *Limiter) clientFromRequest(r *http.Request) string {
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		if cn := r.TLS.PeerCertificates[0].Subject.CommonName; cn != "" {
+			return "cn:" + cn
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	// Forwarded headers are client-controlled without a trusted proxy
+	if !l.trusted(net.ParseIP(host)) {
+		return "ip:" + host
+	}
+
+	// Use the nearest untrusted hop of the proxy chain
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		hops := strings.Split(xff, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			ip := net.ParseIP(hop)
+			if ip == nil {
+				break
+			}
+			host = hop
+			if !l.trusted(ip) {
+				break
+			}
+		}
+		return "ip:" + host
+	}
+	if xrip := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(xrip) != nil {
+		return "ip:" + xrip
+	}
+
+	return "ip:" + host
+}
@@ -0,0 +1,139 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package ratelimit
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+)
+
+func TestMiddleware(t *testing.T) {
+	l := New(&Options{
+		Client: Policy{Rate: 0.001, Burst: 1},
+	})
+	h := Middleware(l)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "10.0.0.1:4242"
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := send(); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	rec := send()
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("expected a Retry-After header")
+	}
+}
+
+func TestMiddleware_SpoofedForwardedHeaders(t *testing.T) {
+	l := New(&Options{
+		Client: Policy{Rate: 0.001, Burst: 1},
+	})
+
+	// Same middleware order as the dispatchers
+	r := chi.NewRouter()
+	r.Use(Middleware(l))
+	r.Use(middleware.RealIP)
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	for i, headers := range []map[string]string{
+		{"X-Forwarded-For": "198.51.100.1"},
+		{"X-Forwarded-For": "198.51.100.2"},
+		{"X-Real-IP": "198.51.100.3"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "10.0.0.1:4242"
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		want := http.StatusTooManyRequests
+		if i == 0 {
+			want = http.StatusOK
+		}
+		if rec.Code != want {
+			t.Errorf("request %d: expected %d, got %d", i, want, rec.Code)
+		}
+	}
+}
+
+func TestClientFromRequest(t *testing.T) {
+	proxies, err := ParseNetworks([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	l := New(&Options{
+		TrustedProxies: proxies,
+	})
+
+	for _, tc := range []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{name: "direct", remoteAddr: "198.51.100.1:4242", want: "ip:198.51.100.1"},
+		{name: "untrusted forwarded for", remoteAddr: "198.51.100.1:4242", headers: map[string]string{"X-Forwarded-For": "203.0.113.1"}, want: "ip:198.51.100.1"},
+		{name: "untrusted real ip", remoteAddr: "198.51.100.1:4242", headers: map[string]string{"X-Real-IP": "203.0.113.1"}, want: "ip:198.51.100.1"},
+		{name: "trusted forwarded for", remoteAddr: "10.0.0.1:4242", headers: map[string]string{"X-Forwarded-For": "203.0.113.1"}, want: "ip:203.0.113.1"},
+		{name: "trusted proxy chain", remoteAddr: "10.0.0.1:4242", headers: map[string]string{"X-Forwarded-For": "192.0.2.1, 203.0.113.1, 10.0.0.2"}, want: "ip:203.0.113.1"},
+		{name: "trusted real ip", remoteAddr: "10.0.0.1:4242", headers: map[string]string{"X-Real-IP": "203.0.113.1"}, want: "ip:203.0.113.1"},
+		{name: "trusted without header", remoteAddr: "10.0.0.1:4242", want: "ip:10.0.0.1"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tc.remoteAddr
+		for k, v := range tc.headers {
+			req.Header.Set(k, v)
+		}
+		if got := l.clientFromRequest(req); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+
+	// Client certificate identity has precedence
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.TLS = &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{
+			{Subject: pkix.Name{CommonName: "client"}},
+		},
+	}
+	if got := l.clientFromRequest(req); got != "cn:client" {
+		t.Errorf("got %q, want %q", got, "cn:client")
+	}
+}
@@ -0,0 +1,224 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/elastic/harp-plugins/server/pkg/server/metrics"
+	"github.com/elastic/harp-plugins/server/pkg/server/namespace"
+)
+
+var (
+	rejections = metrics.Map("harp_server_ratelimit_rejections")
+	calls      = metrics.Map("harp_server_backend_calls")
+)
+
+// Limiter enforces client, namespace and backend concurrency limits.
+type Limiter struct {
+	clients    *buckets
+	namespaces *buckets
+	overrides  map[string]Policy
+	slots      chan struct{}
+	wait       time.Duration
+	proxies    []*net.IPNet
+}
+
+// New returns a limiter instance.
+func New(opts *Options) *Limiter {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	l := &Limiter{
+		clients:    newBuckets(opts.IdleTimeout),
+		namespaces: newBuckets(0),
+		overrides:  map[string]Policy{},
+		wait:       opts.ConcurrencyWait,
+		proxies:    opts.TrustedProxies,
+	}
+
+	// Client and namespace default policies
+	l.clients.policy = opts.Client
+	l.namespaces.policy = opts.Namespace
+	for ns, p := range opts.Namespaces {
+		l.overrides[namespaceKey(ns)] = p
+	}
+
+	// Backend call slots
+	if opts.MaxConcurrentCalls > 0 {
+		l.slots = make(chan struct{}, opts.MaxConcurrentCalls)
+	}
+
+	return l
+}
+
+// AllowClient consumes a token from the client bucket.
+func (l *Limiter) AllowClient(client string) error {
+	if d, ok := l.clients.allow(client, l.clients.policy); !ok {
+		rejections.Add(string(ScopeClient), 1)
+		return &Error{Scope: ScopeClient, RetryAfter: d}
+	}
+
+	return nil
+}
+
+// AllowNamespace consumes a token from the namespace bucket.
+func (l *Limiter) AllowNamespace(namespace string) error {
+	namespace = namespaceKey(namespace)
+
+	p, ok := l.overrides[namespace]
+	if !ok {
+		p = l.namespaces.policy
+	}
+
+	if d, ok := l.namespaces.allow(namespace, p); !ok {
+		rejections.Add(string(ScopeNamespace), 1)
+		return &Error{Scope: ScopeNamespace, RetryAfter: d}
+	}
+
+	return nil
+}
+
+// Acquire reserves a backend call slot, the returned function must be called
+// to release it.
+func (l *Limiter) Acquire(ctx context.Context) (func(), error) {
+	release := func() {
+		calls.Add("inflight", -1)
+	}
+
+	// No concurrency limit
+	if l.slots == nil {
+		calls.Add("inflight", 1)
+		return release, nil
+	}
+
+	// Wait for a free slot
+	timer := time.NewTimer(l.wait)
+	defer timer.Stop()
+
+	select {
+	case l.slots <- struct{}{}:
+		calls.Add("inflight", 1)
+		return func() {
+			<-l.slots
+			release()
+		}, nil
+	case <-timer.C:
+		rejections.Add(string(ScopeConcurrency), 1)
+		return nil, &Error{Scope: ScopeConcurrency, RetryAfter: time.Second}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+// trusted returns true if the given address belongs to a trusted proxy.
+func (l *Limiter) trusted(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range l.proxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// namespaceKey normalizes the namespace as the backend manager does.
+func namespaceKey(ns string) string {
+	if id, err := namespace.Parse(ns); err == nil {
+		return id
+	}
+
+	return ns
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+type buckets struct {
+	sync.Mutex
+	policy    Policy
+	entries   map[string]*bucket
+	idle      time.Duration
+	lastSweep time.Time
+}
+
+func newBuckets(idle time.Duration) *buckets {
+	return &buckets{
+		entries:   map[string]*bucket{},
+		idle:      idle,
+		lastSweep: time.Now(),
+	}
+}
+
+func (b *buckets) allow(key string, p Policy) (time.Duration, bool) {
+	// Unlimited
+	if !p.Enabled() {
+		return 0, true
+	}
+
+	now := time.Now()
+
+	b.Lock()
+	defer b.Unlock()
+
+	// Release idle buckets
+	if b.idle > 0 && now.Sub(b.lastSweep) > b.idle {
+		for k, e := range b.entries {
+			if now.Sub(e.lastSeen) > b.idle {
+				delete(b.entries, k)
+			}
+		}
+		b.lastSweep = now
+	}
+
+	// Retrieve or create the bucket
+	e, ok := b.entries[key]
+	if !ok {
+		burst := p.Burst
+		if burst < 1 {
+			burst = 1
+		}
+		e = &bucket{
+			limiter: rate.NewLimiter(rate.Limit(p.Rate), burst),
+		}
+		b.entries[key] = e
+	}
+	e.lastSeen = now
+
+	// Consume a token only if available now
+	r := e.limiter.ReserveN(now, 1)
+	if d := r.DelayFrom(now); d > 0 {
+		r.CancelAt(now)
+		return d, false
+	}
+
+	return 0, true
+}
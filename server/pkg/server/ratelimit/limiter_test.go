@@ -0,0 +1,144 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimiter_AllowClient(t *testing.T) {
+	l := New(&Options{
+		Client: Policy{Rate: 1, Burst: 2},
+	})
+
+	// Burst is consumed, then the client is limited
+	for i := 0; i < 2; i++ {
+		if err := l.AllowClient("ip:10.0.0.1"); err != nil {
+			t.Fatalf("request %d: unexpected error: %v", i, err)
+		}
+	}
+	err := l.AllowClient("ip:10.0.0.1")
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected limit error, got %v", err)
+	}
+	if d, ok := RetryAfter(err); !ok || d <= 0 {
+		t.Errorf("expected a retry delay, got %v", d)
+	}
+
+	// Other clients have their own bucket
+	if err := l.AllowClient("ip:10.0.0.2"); err != nil {
+		t.Errorf("unexpected error for another client: %v", err)
+	}
+}
+
+func TestLimiter_Unlimited(t *testing.T) {
+	l := New(nil)
+	for i := 0; i < 100; i++ {
+		if err := l.AllowClient("ip:10.0.0.1"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := l.AllowNamespace("production"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+func TestLimiter_AllowNamespace(t *testing.T) {
+	l := New(&Options{
+		Namespace: Policy{Rate: 1, Burst: 1},
+		Namespaces: map[string]Policy{
+			"/team/app/": {Rate: 1, Burst: 3},
+		},
+	})
+
+	// Default policy
+	if err := l.AllowNamespace("production"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := l.AllowNamespace("production"); !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected limit error, got %v", err)
+	}
+
+	// Override matched by canonical identifier
+	for i := 0; i < 3; i++ {
+		if err := l.AllowNamespace("team/app"); err != nil {
+			t.Fatalf("request %d: unexpected error: %v", i, err)
+		}
+	}
+	var e *Error
+	if err := l.AllowNamespace("team/app"); !errors.As(err, &e) || e.Scope != ScopeNamespace {
+		t.Fatalf("expected namespace limit error, got %v", err)
+	}
+}
+
+func TestLimiter_Acquire(t *testing.T) {
+	l := New(&Options{
+		MaxConcurrentCalls: 1,
+		ConcurrencyWait:    10 * time.Millisecond,
+	})
+
+	release, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// No free slot
+	var e *Error
+	if _, err := l.Acquire(context.Background()); !errors.As(err, &e) || e.Scope != ScopeConcurrency {
+		t.Fatalf("expected concurrency limit error, got %v", err)
+	}
+
+	// Cancelled while waiting
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	l.wait = time.Hour
+	if _, err := l.Acquire(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation error, got %v", err)
+	}
+
+	// Released slot is available again
+	release()
+	release, err = l.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	release()
+}
+
+func TestParseNetworks(t *testing.T) {
+	networks, err := ParseNetworks([]string{"10.0.0.1", "192.168.0.0/16", "::1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := len(networks); got != 3 {
+		t.Fatalf("expected 3 networks, got %d", got)
+	}
+	if got := networks[0].String(); got != "10.0.0.1/32" {
+		t.Errorf("got %q, want %q", got, "10.0.0.1/32")
+	}
+	if got := networks[2].String(); got != "::1/128" {
+		t.Errorf("got %q, want %q", got, "::1/128")
+	}
+
+	if _, err := ParseNetworks([]string{"proxy.local"}); err == nil {
+		t.Error("expected an error for an invalid address")
+	}
+}
@@ -0,0 +1,115 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package reference resolves secret references used in settings.
+package reference
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// ResolverFunc resolves the given reference to its value.
+type ResolverFunc func(ctx context.Context, ref *url.URL) (string, error)
+
+var (
+	mu        sync.RWMutex
+	resolvers = map[string]ResolverFunc{}
+
+	// ErrResolverAlreadyRegistered is raised when trying to register an existent resolver.
+	ErrResolverAlreadyRegistered = errors.New("reference: resolver already registered")
+)
+
+// Register a new reference resolver.
+func Register(scheme string, resolver ResolverFunc) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, ok := resolvers[scheme]; ok {
+		return ErrResolverAlreadyRegistered
+	}
+	resolvers[scheme] = resolver
+
+	// No error
+	return nil
+}
+
+// MustRegister try to register the resolver and panic on error.
+func MustRegister(scheme string, resolver ResolverFunc) {
+	if err := Register(scheme, resolver); err != nil {
+		panic(err)
+	}
+}
+
+// IsReference returns true if the value uses a registered reference scheme.
+func IsReference(value string) bool {
+	_, ok := lookup(value)
+	return ok
+}
+
+// Resolve returns the value of the given reference, other values are returned
+// as-is. Errors never contain the resolved value.
+func Resolve(ctx context.Context, value string) (string, error) {
+	resolver, ok := lookup(value)
+	if !ok {
+		return value, nil
+	}
+
+	// Parse reference
+	ref, err := url.Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("reference: invalid reference '%s'", describe(value))
+	}
+
+	// Delegate to resolver
+	out, err := resolver(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("reference: unable to resolve '%s': %w", describe(value), err)
+	}
+
+	// No error
+	return out, nil
+}
+
+// -----------------------------------------------------------------------------
+
+func lookup(value string) (ResolverFunc, bool) {
+	idx := strings.Index(value, "://")
+	if idx <= 0 {
+		return nil, false
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	resolver, ok := resolvers[value[:idx]]
+	return resolver, ok
+}
+
+// describe returns a printable and bounded representation of the reference.
+func describe(value string) string {
+	const maxLength = 64
+	if len(value) > maxLength {
+		return value[:maxLength] + "..."
+	}
+
+	return value
+}
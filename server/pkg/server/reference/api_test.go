@@ -0,0 +1,318 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package reference
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+
+	"github.com/elastic/harp/pkg/bundle"
+	"github.com/elastic/harp/pkg/sdk/value/encryption/envelope"
+	"github.com/elastic/harp/pkg/sdk/value/encryption/secretbox"
+)
+
+func TestIsReference(t *testing.T) {
+	testCases := []struct {
+		value string
+		want  bool
+	}{
+		{value: "env://APP_SECRET", want: true},
+		{value: "file:///run/secrets/key", want: true},
+		{value: "vault://secret/app#password", want: true},
+		{value: "harp-bundle:///tmp/secrets.bundle?secret=app#key", want: true},
+		{value: "awskms://AAAA", want: true},
+		{value: "https://example.com", want: false},
+		{value: "://missing-scheme", want: false},
+		{value: "plain-value", want: false},
+		{value: "", want: false},
+	}
+	for _, tc := range testCases {
+		if got := IsReference(tc.value); got != tc.want {
+			t.Errorf("IsReference(%q) = %v, want %v", tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestResolve_Passthrough(t *testing.T) {
+	for _, value := range []string{"plain-value", "https://example.com/path", ""} {
+		got, err := Resolve(context.Background(), value)
+		if err != nil {
+			t.Fatalf("Resolve(%q): unexpected error: %v", value, err)
+		}
+		if got != value {
+			t.Errorf("Resolve(%q) = %q, want value unchanged", value, got)
+		}
+	}
+}
+
+func TestResolve_ErrorDescription(t *testing.T) {
+	t.Setenv("REFERENCE_TEST_UNDEFINED", "")
+	if err := os.Unsetenv("REFERENCE_TEST_UNDEFINED"); err != nil {
+		t.Fatal(err)
+	}
+
+	value := "env://REFERENCE_TEST_UNDEFINED" + strings.Repeat("_X", 64)
+	_, err := Resolve(context.Background(), value)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if strings.Contains(err.Error(), value) {
+		t.Errorf("expected the reference to be truncated, got %q", err.Error())
+	}
+	if !strings.Contains(err.Error(), value[:64]+"...") {
+		t.Errorf("expected the truncated reference in the error, got %q", err.Error())
+	}
+}
+
+func TestRegister_Duplicate(t *testing.T) {
+	err := Register("env", resolveEnv)
+	if !errors.Is(err, ErrResolverAlreadyRegistered) {
+		t.Fatalf("expected ErrResolverAlreadyRegistered, got %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected MustRegister to panic")
+		}
+	}()
+	MustRegister("file", resolveFile)
+}
+
+// -----------------------------------------------------------------------------
+
+func TestResolve_Env(t *testing.T) {
+	t.Setenv("REFERENCE_TEST_SECRET", "  s3cr3t\n")
+
+	got, err := Resolve(context.Background(), "env://REFERENCE_TEST_SECRET")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "s3cr3t" {
+		t.Errorf("got %q, want %q", got, "s3cr3t")
+	}
+
+	if err := os.Unsetenv("REFERENCE_TEST_SECRET"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Resolve(context.Background(), "env://REFERENCE_TEST_SECRET"); err == nil {
+		t.Error("expected an error for an undefined variable")
+	}
+	if _, err := Resolve(context.Background(), "env://"); err == nil {
+		t.Error("expected an error for an empty variable name")
+	}
+}
+
+func TestResolve_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret.txt")
+	if err := os.WriteFile(path, []byte("s3cr3t\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Resolve(context.Background(), "file://"+path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "s3cr3t" {
+		t.Errorf("got %q, want %q", got, "s3cr3t")
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing.txt")
+	_, err = Resolve(context.Background(), "file://"+missing)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected a not exist error, got %v", err)
+	}
+	if _, err := Resolve(context.Background(), "file://"); err == nil {
+		t.Error("expected an error for an empty path")
+	}
+}
+
+func TestResolve_Vault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/sys/internal/ui/mounts/application/database":
+			fmt.Fprint(w, `{"data":{"type":"kv","path":"application/","options":{"version":"2"}}}`)
+		case "/v1/application/data/database":
+			fmt.Fprint(w, `{"data":{"data":{"user":"admin","port":5432},"metadata":{}}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "test-token")
+	t.Setenv("VAULT_MAX_RETRIES", "0")
+
+	testCases := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{name: "string field", value: "vault://application/database#user", want: "admin"},
+		{name: "number field", value: "vault://application/database#port", want: "5432"},
+		{name: "unknown field", value: "vault://application/database#password", wantErr: true},
+		{name: "missing field", value: "vault://application/database", wantErr: true},
+		{name: "missing path", value: "vault://#user", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Resolve(context.Background(), tc.value)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestResolve_Bundle(t *testing.T) {
+	b, err := bundle.FromMap(map[string]bundle.KV{
+		"app/production/database": {
+			"user":     "admin",
+			"password": "s3cr3t",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := bundle.ToContainerWriter(&buf, b); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "secrets.bundle")
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{name: "valid", value: "harp-bundle://" + path + "?secret=app/production/database#password", want: "s3cr3t"},
+		{name: "unknown field", value: "harp-bundle://" + path + "?secret=app/production/database#token", wantErr: true},
+		{name: "unknown secret", value: "harp-bundle://" + path + "?secret=app/production/cache#password", wantErr: true},
+		{name: "missing secret", value: "harp-bundle://" + path + "#password", wantErr: true},
+		{name: "missing field", value: "harp-bundle://" + path + "?secret=app/production/database", wantErr: true},
+		{name: "missing bundle", value: "harp-bundle://" + path + ".missing?secret=app/production/database#password", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Resolve(context.Background(), tc.value)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+// fakeKMS reverses data keys instead of encrypting them.
+type fakeKMS struct {
+	kmsiface.KMSAPI
+}
+
+func (fakeKMS) EncryptWithContext(_ aws.Context, in *kms.EncryptInput, _ ...request.Option) (*kms.EncryptOutput, error) {
+	return &kms.EncryptOutput{CiphertextBlob: reverse(in.Plaintext)}, nil
+}
+
+func (fakeKMS) DecryptWithContext(_ aws.Context, in *kms.DecryptInput, _ ...request.Option) (*kms.DecryptOutput, error) {
+	return &kms.DecryptOutput{Plaintext: reverse(in.CiphertextBlob)}, nil
+}
+
+func reverse(in []byte) []byte {
+	out := make([]byte, len(in))
+	for i, c := range in {
+		out[len(in)-1-i] = c
+	}
+	return out
+}
+
+func TestResolve_AWSKMS(t *testing.T) {
+	original := kmsClient
+	kmsClient = func(*session.Session) kmsiface.KMSAPI { return fakeKMS{} }
+	defer func() { kmsClient = original }()
+
+	// Seal a value with the fake KMS service
+	transformer, err := envelope.Transformer(&kmsService{client: fakeKMS{}, keyID: "alias/test"}, secretbox.Transformer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := transformer.To(context.Background(), []byte("s3cr3t"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(encrypted)
+
+	testCases := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{name: "default payload", value: "awskms://" + encoded + "?region=us-east-1", want: "s3cr3t"},
+		{name: "explicit payload", value: "awskms://" + encoded + "?region=us-east-1&payload=secretbox", want: "s3cr3t"},
+		{name: "padded envelope", value: "awskms://" + encoded + "==?region=us-east-1", want: "s3cr3t"},
+		{name: "payload mismatch", value: "awskms://" + encoded + "?region=us-east-1&payload=aes-gcm", wantErr: true},
+		{name: "unsupported payload", value: "awskms://" + encoded + "?payload=rot13", wantErr: true},
+		{name: "invalid encoding", value: "awskms://not*base64", wantErr: true},
+		{name: "missing envelope", value: "awskms://?region=us-east-1", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Resolve(context.Background(), tc.value)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestKMSService_EncryptRequiresKey(t *testing.T) {
+	s := &kmsService{client: fakeKMS{}}
+	if _, err := s.Encrypt(context.Background(), []byte("key")); err == nil {
+		t.Error("expected an error without key identifier")
+	}
+}
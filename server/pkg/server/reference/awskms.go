@@ -0,0 +1,165 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package reference
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+
+	"github.com/elastic/harp/pkg/sdk/value/encryption"
+	"github.com/elastic/harp/pkg/sdk/value/encryption/aead"
+	"github.com/elastic/harp/pkg/sdk/value/encryption/envelope"
+	"github.com/elastic/harp/pkg/sdk/value/encryption/fernet"
+	"github.com/elastic/harp/pkg/sdk/value/encryption/secretbox"
+)
+
+func init() {
+	MustRegister("awskms", resolveAWSKMS)
+}
+
+// Payload transformers usable with envelope encryption.
+var payloadTransformers = map[string]encryption.TransformerFactoryFunc{
+	"secretbox":         secretbox.Transformer,
+	"aes-gcm":           aead.AESGCM,
+	"chacha20":          aead.Chacha20Poly1305,
+	"xchacha20":         aead.XChacha20Poly1305,
+	"fernet":            fernet.Transformer,
+	"aes-siv":           aead.AESSIV,
+	"aes-pmac-siv":      aead.AESPMACSIV,
+	"chacha20poly1305":  aead.Chacha20Poly1305,
+	"xchacha20poly1305": aead.XChacha20Poly1305,
+}
+
+// kmsClient builds the KMS client used to decrypt data keys.
+var kmsClient = func(sess *session.Session) kmsiface.KMSAPI {
+	return kms.New(sess)
+}
+
+// resolveAWSKMS resolves `awskms://<base64url envelope>?region=<region>&payload=<algorithm>`
+// references. The envelope is a harp envelope encrypted value, the data key is
+// decrypted using AWS KMS.
+func resolveAWSKMS(ctx context.Context, ref *url.URL) (string, error) {
+	var (
+		q       = ref.Query()
+		payload = q.Get("payload")
+	)
+
+	// Decode envelope
+	encoded := strings.TrimRight(ref.Host+strings.TrimPrefix(ref.Path, "/"), "=")
+	if encoded == "" {
+		return "", errors.New("encrypted value is mandatory")
+	}
+	encrypted, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", errors.New("unable to decode encrypted value")
+	}
+
+	// Select payload transformer
+	if payload == "" {
+		payload = "secretbox"
+	}
+	factory, ok := payloadTransformers[payload]
+	if !ok {
+		return "", fmt.Errorf("unsupported payload algorithm '%s'", payload)
+	}
+
+	// Initialize AWS session
+	sess, err := session.NewSession(&aws.Config{
+		Region: regionOrNil(q.Get("region")),
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to initialize aws session: %w", err)
+	}
+
+	// Prepare envelope transformer
+	transformer, err := envelope.Transformer(&kmsService{
+		client: kmsClient(sess),
+		keyID:  q.Get("key_id"),
+	}, factory)
+	if err != nil {
+		return "", fmt.Errorf("unable to initialize envelope transformer: %w", err)
+	}
+
+	// Decrypt value
+	out, err := transformer.From(ctx, encrypted)
+	if err != nil {
+		return "", fmt.Errorf("unable to decrypt value: %w", err)
+	}
+
+	// No error
+	return string(out), nil
+}
+
+// -----------------------------------------------------------------------------
+
+// kmsService implements envelope.Service using AWS KMS.
+type kmsService struct {
+	client kmsiface.KMSAPI
+	keyID  string
+}
+
+func (s *kmsService) Decrypt(ctx context.Context, encrypted []byte) ([]byte, error) {
+	input := &kms.DecryptInput{
+		CiphertextBlob: encrypted,
+	}
+	if s.keyID != "" {
+		input.KeyId = aws.String(s.keyID)
+	}
+
+	out, err := s.client.DecryptWithContext(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("awskms: unable to decrypt data key: %w", err)
+	}
+
+	// No error
+	return out.Plaintext, nil
+}
+
+func (s *kmsService) Encrypt(ctx context.Context, cleartext []byte) ([]byte, error) {
+	if s.keyID == "" {
+		return nil, errors.New("awskms: key identifier is mandatory for encryption")
+	}
+
+	out, err := s.client.EncryptWithContext(ctx, &kms.EncryptInput{
+		KeyId:     aws.String(s.keyID),
+		Plaintext: cleartext,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("awskms: unable to encrypt data key: %w", err)
+	}
+
+	// No error
+	return out.CiphertextBlob, nil
+}
+
+func regionOrNil(region string) *string {
+	if region == "" {
+		return nil
+	}
+
+	return aws.String(region)
+}
@@ -0,0 +1,80 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package reference
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/elastic/harp-plugins/server/pkg/server/format"
+	"github.com/elastic/harp/pkg/bundle"
+)
+
+func init() {
+	MustRegister("harp-bundle", resolveBundle)
+}
+
+// resolveBundle resolves `harp-bundle:///path/to/file.bundle?secret=app/db#field`
+// references from an unsealed bundle container file.
+func resolveBundle(_ context.Context, ref *url.URL) (string, error) {
+	var (
+		bundlePath = ref.Host + ref.Path
+		secretPath = ref.Query().Get("secret")
+		field      = ref.Fragment
+	)
+	if bundlePath == "" {
+		return "", errors.New("bundle path is mandatory")
+	}
+	if secretPath == "" {
+		return "", errors.New("secret path is mandatory")
+	}
+	if field == "" {
+		return "", errors.New("secret field is mandatory")
+	}
+
+	// Open bundle file
+	f, err := os.Open(bundlePath)
+	if err != nil {
+		return "", errors.New("unable to open bundle file")
+	}
+	defer f.Close()
+
+	// Extract bundle from container
+	b, err := bundle.FromContainerReader(f)
+	if err != nil {
+		return "", fmt.Errorf("unable to load bundle: %w", err)
+	}
+
+	// Read secret
+	data, err := bundle.Read(b, secretPath)
+	if err != nil {
+		return "", fmt.Errorf("unable to read secret '%s'", secretPath)
+	}
+
+	// Extract field
+	value, ok := format.Field(data, field)
+	if !ok {
+		return "", fmt.Errorf("field '%s' not found", field)
+	}
+
+	// No error
+	return format.Scalar(value), nil
+}
@@ -0,0 +1,69 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package reference
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+func init() {
+	MustRegister("env", resolveEnv)
+	MustRegister("file", resolveFile)
+}
+
+// resolveEnv resolves `env://NAME` references.
+func resolveEnv(_ context.Context, ref *url.URL) (string, error) {
+	name := ref.Host + ref.Path
+	if name == "" {
+		return "", errors.New("variable name is mandatory")
+	}
+
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable '%s' is not defined", name)
+	}
+
+	// No error
+	return strings.TrimSpace(v), nil
+}
+
+// resolveFile resolves `file:///path` references.
+func resolveFile(_ context.Context, ref *url.URL) (string, error) {
+	path := ref.Host + ref.Path
+	if path == "" {
+		return "", errors.New("file path is mandatory")
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		// Don't expose full error chain
+		var pathErr *os.PathError
+		if errors.As(err, &pathErr) {
+			err = pathErr.Err
+		}
+		return "", fmt.Errorf("unable to read file: %w", err)
+	}
+
+	// No error
+	return strings.TrimSpace(string(content)), nil
+}
@@ -0,0 +1,76 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package reference
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/hashicorp/vault/api"
+
+	"github.com/elastic/harp-plugins/server/pkg/server/format"
+	"github.com/elastic/harp/pkg/vault/kv"
+)
+
+func init() {
+	MustRegister("vault", resolveVault)
+}
+
+// resolveVault resolves `vault://path#field` references using the Vault client
+// environment settings (VAULT_ADDR, VAULT_TOKEN, ...).
+func resolveVault(ctx context.Context, ref *url.URL) (string, error) {
+	var (
+		secretPath = ref.Host + ref.Path
+		field      = ref.Fragment
+	)
+	if secretPath == "" {
+		return "", errors.New("secret path is mandatory")
+	}
+	if field == "" {
+		return "", errors.New("secret field is mandatory")
+	}
+
+	// Initialize Vault connection
+	client, err := api.NewClient(api.DefaultConfig())
+	if err != nil {
+		return "", fmt.Errorf("unable to initialize vault connection: %w", err)
+	}
+
+	// Retrieve a secret reader
+	reader, err := kv.New(client, secretPath)
+	if err != nil {
+		return "", fmt.Errorf("unable to initialize kv service: %w", err)
+	}
+
+	// Read secret
+	data, _, err := reader.Read(ctx, secretPath)
+	if err != nil {
+		return "", fmt.Errorf("unable to read secret: %w", err)
+	}
+
+	// Extract field
+	value, ok := format.Field(data, field)
+	if !ok {
+		return "", fmt.Errorf("field '%s' not found", field)
+	}
+
+	// No error
+	return format.Scalar(value), nil
+}
@@ -21,6 +21,7 @@ import (
 	"context"
 	"errors"
 	"net/url"
+	"time"
 )
 
 // ErrSecretNotFound is raised when trying to access non-existing secret.
@@ -35,3 +36,87 @@ type EngineFactoryFunc func(*url.URL) (Engine, error)
 type Engine interface {
 	Get(ctx context.Context, id string) ([]byte, error)
 }
+
+// Lister is implemented by engines able to enumerate their secret identifiers.
+type Lister interface {
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// Notifier is implemented by engines able to notify secret changes, such as
+// engines watching their source, so that watchers don't wait for next poll.
+type Notifier interface {
+	// Notify registers fn to be called on secret changes. The returned function
+	// unregisters it.
+	Notify(fn func()) func()
+}
+
+// Wrapper is implemented by decorators to expose the decorated engine.
+type Wrapper interface {
+	Unwrap() Engine
+}
+
+// Notify registers fn to be called on secret changes of the given engine or
+// the first decorated engine supporting notifications. It returns false when
+// no engine supports notifications.
+func Notify(engine Engine, fn func()) (func(), bool) {
+	for engine != nil {
+		// Use extended contract if supported
+		if n, ok := engine.(Notifier); ok {
+			return n.Notify(fn), true
+		}
+
+		// Check decorated engine
+		w, ok := engine.(Wrapper)
+		if !ok {
+			break
+		}
+		engine = w.Unwrap()
+	}
+
+	return nil, false
+}
+
+// Metadata describes a secret value version.
+type Metadata struct {
+	// Version is the engine specific secret version identifier.
+	Version string
+	// Created is the creation time of the secret version.
+	Created time.Time
+	// CustomMetadata holds user defined secret metadata.
+	CustomMetadata map[string]string
+	// ContentType is the secret value content type, if known.
+	ContentType string
+	// Origin identifies the source serving the secret, if known.
+	Origin string
+}
+
+// Secret represents a secret value with its metadata.
+type Secret struct {
+	Value    []byte
+	Metadata Metadata
+}
+
+// MetadataGetter is implemented by engines able to return secret metadata.
+type MetadataGetter interface {
+	GetWithMetadata(ctx context.Context, id string) (*Secret, error)
+}
+
+// GetWithMetadata returns the secret value and its metadata from the given
+// engine. Metadata are empty when the engine doesn't support them.
+func GetWithMetadata(ctx context.Context, engine Engine, id string) (*Secret, error) {
+	// Use extended contract if supported
+	if mg, ok := engine.(MetadataGetter); ok {
+		return mg.GetWithMetadata(ctx, id)
+	}
+
+	// Delegate to engine
+	value, err := engine.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// No error
+	return &Secret{
+		Value: value,
+	}, nil
+}
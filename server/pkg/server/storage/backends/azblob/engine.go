@@ -23,18 +23,15 @@ import (
 	"fmt"
 	"io"
 	"net/url"
-	"os"
-
-	storage "github.com/Azure/azure-sdk-for-go/storage"
 
+	"github.com/elastic/harp-plugins/server/pkg/cloud/azure/session"
 	cloudstorage "github.com/elastic/harp-plugins/server/pkg/cloud/storage"
 	serverstorage "github.com/elastic/harp-plugins/server/pkg/server/storage"
+	"github.com/elastic/harp-plugins/server/pkg/server/storage/backends/object"
 )
 
 type engine struct {
-	client     *storage.Client
-	bucketName string
-	prefix     string
+	backend cloudstorage.Backend
 }
 
 func build(u *url.URL) (serverstorage.Engine, error) {
@@ -45,22 +42,29 @@ func build(u *url.URL) (serverstorage.Engine, error) {
 
 	q := u.Query()
 
-	azureConnString := os.Getenv("AZURE_CONNECTION_STRING")
-	if azureConnString == "" {
-		return nil, errors.New("AZURE_CONNECTION_STRING env. variable must be set for azblob backend")
+	// Select local object storage backend
+	backend, local, err := object.FromURL(u, u.Hostname(), q.Get("prefix"))
+	if err != nil {
+		return nil, err
 	}
+	if !local {
+		// Build client options from url
+		opts, err := session.FromURL(u.String())
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse session URL: %w", err)
+		}
 
-	// Create an Azure Stroage client
-	client, err := storage.NewClientFromConnectionString(azureConnString)
-	if err != nil {
-		return nil, fmt.Errorf("azblob: unable to initialize storage client: %w", err)
+		// Create a Azure Storage client
+		client, err := session.NewClient(opts)
+		if err != nil {
+			return nil, fmt.Errorf("azblob: %w", err)
+		}
+		backend = cloudstorage.AzureBlob(client, u.Hostname(), q.Get("prefix"))
 	}
 
 	// Build engine instance
 	return &engine{
-		client:     &client,
-		bucketName: u.Hostname(),
-		prefix:     q.Get("prefix"),
+		backend: backend,
 	}, nil
 }
 
@@ -71,20 +75,46 @@ func init() {
 
 // Reader returns the file Reader
 func (d *engine) Get(ctx context.Context, key string) ([]byte, error) {
+	secret, err := d.GetWithMetadata(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return secret.Value, nil
+}
+
+func (d *engine) GetWithMetadata(ctx context.Context, key string) (*serverstorage.Secret, error) {
 	// Create an Azure Stroage client
-	if d.client == nil {
-		return nil, fmt.Errorf("azblob: unable proceed with nil client")
+	if d.backend == nil {
+		return nil, fmt.Errorf("azblob: unable proceed with nil backend")
 	}
 
 	// Retrieve using Azure storage backend
-	result, err := cloudstorage.AzureBlob(d.client, d.bucketName, d.prefix).GetObject(ctx, key)
+	result, err := d.backend.GetObject(ctx, key)
 	if err != nil {
+		if errors.Is(err, cloudstorage.ErrNotFound) {
+			return nil, serverstorage.ErrSecretNotFound
+		}
 		return nil, fmt.Errorf("cloudstorage error: %w", err)
 	}
 	if result == nil {
 		return nil, errors.New("azblob: nil object returned")
 	}
+	defer result.Content.Close()
+
+	// Drain content
+	content, err := io.ReadAll(result.Content)
+	if err != nil {
+		return nil, fmt.Errorf("azblob: unable to read object content: %w", err)
+	}
 
 	// No error
-	return io.ReadAll(result.Content)
+	return &serverstorage.Secret{
+		Value: content,
+		Metadata: serverstorage.Metadata{
+			Version:     result.VersionID,
+			Created:     result.LastModified,
+			ContentType: result.ContentType,
+		},
+	}, nil
 }
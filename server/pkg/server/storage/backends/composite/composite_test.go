@@ -0,0 +1,266 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package composite
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"io"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/elastic/harp-plugins/server/pkg/server/storage"
+)
+
+type staticEngine struct {
+	value []byte
+	meta  storage.Metadata
+}
+
+func (e *staticEngine) Get(ctx context.Context, id string) ([]byte, error) {
+	if e.value == nil {
+		return nil, storage.ErrSecretNotFound
+	}
+	return e.value, nil
+}
+
+func (e *staticEngine) GetWithMetadata(ctx context.Context, id string) (*storage.Secret, error) {
+	if e.value == nil {
+		return nil, storage.ErrSecretNotFound
+	}
+	return &storage.Secret{Value: e.value, Metadata: e.meta}, nil
+}
+
+type blockingEngine struct{}
+
+func (e *blockingEngine) Get(ctx context.Context, id string) ([]byte, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func failureCount(name string) int64 {
+	v, ok := failures.Get(name).(*expvar.Int)
+	if !ok {
+		return 0
+	}
+	return v.Value()
+}
+
+func TestRace_CancelledChildrenAreNotFailures(t *testing.T) {
+	e := &raceEngine{children: []*child{
+		{name: "test-race/0:slow", engine: &blockingEngine{}},
+		{name: "test-race/1:fast", engine: &staticEngine{value: []byte("value")}},
+	}}
+
+	out, err := e.Get(context.Background(), "/app/db")
+	if err != nil || string(out) != "value" {
+		t.Fatalf("Get() = %q, %v", out, err)
+	}
+
+	// Wait for the loser to be cancelled
+	time.Sleep(20 * time.Millisecond)
+	if count := failureCount("test-race/0:slow"); count != 0 {
+		t.Fatalf("expected cancelled child not to be counted as failure, got %d", count)
+	}
+}
+
+func TestRace_TimeoutIsFailure(t *testing.T) {
+	e := &raceEngine{children: []*child{
+		{name: "test-race-timeout/0:slow", engine: &blockingEngine{}, timeout: 10 * time.Millisecond},
+	}}
+
+	if _, err := e.Get(context.Background(), "/app/db"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded error, got %v", err)
+	}
+	if count := failureCount("test-race-timeout/0:slow"); count != 1 {
+		t.Fatalf("expected child timeout to be counted as failure, got %d", count)
+	}
+}
+
+func TestFallback_CancelledContext(t *testing.T) {
+	engine := &staticEngine{value: []byte("value")}
+	e := &fallbackEngine{children: []*child{
+		{name: "test-fallback/0:static", engine: engine},
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := e.Get(ctx, "/app/db"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation error, got %v", err)
+	}
+	if count := failureCount("test-fallback/0:static"); count != 0 {
+		t.Fatalf("expected no failure, got %d", count)
+	}
+}
+
+func TestMerge_GetWithMetadata(t *testing.T) {
+	older := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+
+	e := &mergeEngine{children: []*child{
+		{name: "merge/0:a", engine: &staticEngine{
+			value: []byte(`{"db":{"user":"app","password":"old"}}`),
+			meta:  storage.Metadata{Version: "1", Created: newer},
+		}},
+		{name: "merge/1:b", engine: &staticEngine{}},
+		{name: "merge/2:c", engine: &staticEngine{
+			value: []byte(`{"db":{"password":"new"}}`),
+			meta:  storage.Metadata{Version: "7", Created: older},
+		}},
+	}}
+
+	secret, err := storage.GetWithMetadata(context.Background(), e, "/app/db")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := string(secret.Value); got != "{\"db\":{\"password\":\"new\",\"user\":\"app\"}}\n" {
+		t.Fatalf("unexpected merged value: %s", got)
+	}
+	if secret.Metadata.Version != "" {
+		t.Fatalf("expected no version for merged value, got %q", secret.Metadata.Version)
+	}
+	if !secret.Metadata.Created.Equal(newer) {
+		t.Fatalf("expected latest modification time, got %s", secret.Metadata.Created)
+	}
+	if secret.Metadata.Origin != "merge/0:a,merge/2:c" {
+		t.Fatalf("unexpected origin: %s", secret.Metadata.Origin)
+	}
+	if secret.Metadata.ContentType != "application/json" {
+		t.Fatalf("unexpected content type: %s", secret.Metadata.ContentType)
+	}
+
+	// Single contributor keeps its version
+	e.children = e.children[1:]
+	secret, err = e.GetWithMetadata(context.Background(), "/app/db")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if secret.Metadata.Version != "7" || secret.Metadata.Origin != "merge/2:c" {
+		t.Fatalf("unexpected metadata: %+v", secret.Metadata)
+	}
+}
+
+type closableEngine struct {
+	staticEngine
+	closed    int
+	closeErr  error
+	listeners map[int]func()
+	nextID    int
+}
+
+func (e *closableEngine) Close() error {
+	e.closed++
+	return e.closeErr
+}
+
+func (e *closableEngine) Notify(fn func()) func() {
+	if e.listeners == nil {
+		e.listeners = map[int]func(){}
+	}
+	id := e.nextID
+	e.nextID++
+	e.listeners[id] = fn
+
+	return func() {
+		delete(e.listeners, id)
+	}
+}
+
+func (e *closableEngine) notify() {
+	for _, fn := range e.listeners {
+		fn()
+	}
+}
+
+func TestComposite_CloseAndNotify(t *testing.T) {
+	for _, build := range []func([]*child) storage.Engine{
+		func(c []*child) storage.Engine { return &fallbackEngine{children: c} },
+		func(c []*child) storage.Engine { return &mergeEngine{children: c} },
+		func(c []*child) storage.Engine { return &raceEngine{children: c} },
+	} {
+		first := &closableEngine{closeErr: errors.New("close failure")}
+		second := &closableEngine{}
+		e := build([]*child{
+			{name: "test/0:first", engine: first},
+			{name: "test/1:static", engine: &staticEngine{}},
+			{name: "test/2:second", engine: second},
+		})
+
+		// Child notifications are forwarded
+		calls := 0
+		unregister, ok := storage.Notify(e, func() { calls++ })
+		if !ok {
+			t.Fatalf("%T: expected notification support", e)
+		}
+		first.notify()
+		second.notify()
+		if calls != 2 {
+			t.Errorf("%T: expected 2 notifications, got %d", e, calls)
+		}
+		unregister()
+		if len(first.listeners) != 0 || len(second.listeners) != 0 {
+			t.Errorf("%T: expected listeners to be unregistered", e)
+		}
+
+		// All children are closed even if one fails
+		closer, ok := e.(io.Closer)
+		if !ok {
+			t.Fatalf("%T: expected engine to be closable", e)
+		}
+		if err := closer.Close(); err == nil {
+			t.Errorf("%T: expected close error", e)
+		}
+		if first.closed != 1 || second.closed != 1 {
+			t.Errorf("%T: expected all children to be closed, got %d and %d", e, first.closed, second.closed)
+		}
+	}
+}
+
+var builtEngines []*closableEngine
+
+func init() {
+	storage.MustRegister("test-composite-closable", func(u *url.URL) (storage.Engine, error) {
+		e := &closableEngine{}
+		builtEngines = append(builtEngines, e)
+		return e, nil
+	})
+}
+
+func TestBuild_ClosesChildrenOnError(t *testing.T) {
+	builtEngines = nil
+
+	u, err := url.Parse("fallback://?b=test-composite-closable://&b=test-composite-closable://&b=test-composite-unknown://")
+	if err != nil {
+		t.Fatalf("unable to parse url: %v", err)
+	}
+	if _, err := build(u); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if len(builtEngines) != 2 {
+		t.Fatalf("expected 2 built children, got %d", len(builtEngines))
+	}
+	for i, e := range builtEngines {
+		if e.closed != 1 {
+			t.Errorf("child #%d: expected to be closed", i)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package composite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/elastic/harp-plugins/server/pkg/server/metrics"
+	"github.com/elastic/harp-plugins/server/pkg/server/storage"
+	"github.com/elastic/harp/pkg/sdk/log"
+)
+
+const (
+	schemeFallback = "fallback"
+	schemeMerge    = "merge"
+	schemeOverlay  = "overlay"
+	schemeRace     = "race"
+)
+
+var (
+	answers  = metrics.Map("harp_server_composite_answers")
+	failures = metrics.Map("harp_server_composite_failures")
+)
+
+// child describes a composite engine member.
+type child struct {
+	name    string
+	engine  storage.Engine
+	timeout time.Duration
+}
+
+func (c *child) getWithMetadata(ctx context.Context, id string) (*storage.Secret, error) {
+	childCtx := ctx
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		childCtx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	// Delegate to child engine
+	out, err := storage.GetWithMetadata(childCtx, c.engine, id)
+	if err != nil {
+		// Cancelled queries (race loser, client gone) are not child failures
+		if ctx.Err() == nil && !errors.Is(err, context.Canceled) {
+			failures.Add(c.name, 1)
+		}
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// -----------------------------------------------------------------------------
+
+func build(u *url.URL) (storage.Engine, error) {
+	// Check arguments
+	if u == nil {
+		return nil, fmt.Errorf("unable to prepare composite with nil url")
+	}
+
+	var (
+		q        = u.Query()
+		backends = q["b"]
+		timeouts = q["timeout"]
+	)
+
+	// Check arguments
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("%s: at least one backend must be declared using 'b' parameter", u.Scheme)
+	}
+	if len(timeouts) > 1 && len(timeouts) != len(backends) {
+		return nil, fmt.Errorf("%s: timeout count must be 1 or match the backend count", u.Scheme)
+	}
+
+	// Build children
+	children := make([]*child, 0, len(backends))
+	for i, b := range backends {
+		// Resolve child timeout
+		var timeout time.Duration
+		switch {
+		case len(timeouts) == 1:
+			timeout = parseTimeout(timeouts[0])
+		case len(timeouts) > 1:
+			timeout = parseTimeout(timeouts[i])
+		}
+		if timeout < 0 {
+			log.CheckErr("Unable to close composite backends", closeChildren(children))
+			return nil, fmt.Errorf("%s: invalid timeout value for backend #%d", u.Scheme, i)
+		}
+
+		// Delegate to storage factory
+		engine, err := storage.Build(b)
+		if err != nil {
+			// Release already built children
+			log.CheckErr("Unable to close composite backends", closeChildren(children))
+			return nil, fmt.Errorf("%s: unable to build backend #%d: %w", u.Scheme, i, err)
+		}
+
+		children = append(children, &child{
+			name:    childName(u.Scheme, i, b),
+			engine:  engine,
+			timeout: timeout,
+		})
+	}
+
+	// Build engine according to strategy
+	switch u.Scheme {
+	case schemeFallback:
+		return &fallbackEngine{children: children}, nil
+	case schemeMerge, schemeOverlay:
+		return &mergeEngine{children: children}, nil
+	case schemeRace:
+		return &raceEngine{children: children}, nil
+	default:
+	}
+
+	log.CheckErr("Unable to close composite backends", closeChildren(children))
+	return nil, fmt.Errorf("composite: unsupported scheme '%s'", u.Scheme)
+}
+
+func init() {
+	// Register to storage factory
+	storage.MustRegister(schemeFallback, build)
+	storage.MustRegister(schemeMerge, build)
+	storage.MustRegister(schemeOverlay, build)
+	storage.MustRegister(schemeRace, build)
+}
+
+// -----------------------------------------------------------------------------
+
+// list returns the union of identifiers exposed by listable children.
+func list(ctx context.Context, children []*child, prefix string) ([]string, error) {
+	index := map[string]struct{}{}
+	for _, c := range children {
+		l, ok := c.engine.(storage.Lister)
+		if !ok {
+			continue
+		}
+
+		ids, err := l.List(ctx, prefix)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list '%s' backend: %w", c.name, err)
+		}
+		for _, id := range ids {
+			index[id] = struct{}{}
+		}
+	}
+
+	res := make([]string, 0, len(index))
+	for id := range index {
+		res = append(res, id)
+	}
+	sort.Strings(res)
+
+	// No error
+	return res, nil
+}
+
+// notify registers fn on every child supporting change notifications.
+func notify(children []*child, fn func()) func() {
+	unregisters := []func(){}
+	for _, c := range children {
+		if unregister, ok := storage.Notify(c.engine, fn); ok {
+			unregisters = append(unregisters, unregister)
+		}
+	}
+
+	return func() {
+		for _, unregister := range unregisters {
+			unregister()
+		}
+	}
+}
+
+// closeChildren releases resources held by children engines, all children are
+// closed even if one fails.
+func closeChildren(children []*child) error {
+	var firstErr error
+	for _, c := range children {
+		closer, ok := c.engine.(io.Closer)
+		if !ok {
+			continue
+		}
+		if err := closer.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("unable to close '%s' backend: %w", c.name, err)
+		}
+	}
+
+	return firstErr
+}
+
+func isNotFound(err error) bool {
+	return errors.Is(err, storage.ErrSecretNotFound) || errors.Is(err, fs.ErrNotExist)
+}
+
+func parseTimeout(raw string) time.Duration {
+	if raw == "" {
+		return 0
+	}
+
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		return -1
+	}
+
+	return d
+}
+
+func childName(scheme string, idx int, uri string) string {
+	// Only keep child scheme to prevent secret leaks from URL
+	childScheme := "unknown"
+	if i := strings.Index(uri, "://"); i > 0 {
+		childScheme = uri[:i]
+	}
+
+	return fmt.Sprintf("%s/%d:%s", scheme, idx, childScheme)
+}
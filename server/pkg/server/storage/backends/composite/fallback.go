@@ -0,0 +1,85 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package composite
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/elastic/harp-plugins/server/pkg/server/storage"
+	"github.com/elastic/harp/pkg/sdk/log"
+)
+
+// fallbackEngine queries children in order and returns the first success.
+type fallbackEngine struct {
+	children []*child
+}
+
+func (e *fallbackEngine) Get(ctx context.Context, id string) ([]byte, error) {
+	secret, err := e.GetWithMetadata(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return secret.Value, nil
+}
+
+func (e *fallbackEngine) GetWithMetadata(ctx context.Context, id string) (*storage.Secret, error) {
+	var (
+		lastErr  error
+		notFound = true
+	)
+
+	for _, c := range e.children {
+		// Don't query next backends when the request is over
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("fallback: unable to retrieve '%s': %w", id, err)
+		}
+
+		out, err := c.getWithMetadata(ctx, id)
+		if err == nil {
+			answers.Add(c.name, 1)
+			return out, nil
+		}
+
+		log.For(ctx).Debug("Backend failed, trying next one", zap.String("backend", c.name), zap.Error(err))
+
+		lastErr = err
+		notFound = notFound && isNotFound(err)
+	}
+
+	if notFound {
+		return nil, fmt.Errorf("fallback: '%s' not found in any backend: %w", id, storage.ErrSecretNotFound)
+	}
+
+	return nil, fmt.Errorf("fallback: all backends failed: %w", lastErr)
+}
+
+func (e *fallbackEngine) List(ctx context.Context, prefix string) ([]string, error) {
+	return list(ctx, e.children, prefix)
+}
+
+func (e *fallbackEngine) Notify(fn func()) func() {
+	return notify(e.children, fn)
+}
+
+func (e *fallbackEngine) Close() error {
+	return closeChildren(e.children)
+}
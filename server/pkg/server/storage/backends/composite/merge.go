@@ -0,0 +1,139 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package composite
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/elastic/harp-plugins/server/pkg/server/storage"
+)
+
+// mergeEngine resolves a secret across all children, later children override
+// earlier ones. JSON objects are deeply merged, other values are replaced.
+type mergeEngine struct {
+	children []*child
+}
+
+func (e *mergeEngine) Get(ctx context.Context, id string) ([]byte, error) {
+	secret, err := e.GetWithMetadata(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return secret.Value, nil
+}
+
+// GetWithMetadata returns the merged secret. Metadata are the ones of the
+// highest precedence contributing child, the version is only kept when a
+// single child contributed to the value.
+func (e *mergeEngine) GetWithMetadata(ctx context.Context, id string) (*storage.Secret, error) {
+	var (
+		raw     []byte
+		merged  map[string]interface{}
+		meta    storage.Metadata
+		origins []string
+	)
+
+	for _, c := range e.children {
+		secret, err := c.getWithMetadata(ctx, id)
+		switch {
+		case err == nil:
+		case isNotFound(err):
+			continue
+		default:
+			return nil, fmt.Errorf("merge: unable to retrieve secret from '%s' backend: %w", c.name, err)
+		}
+
+		answers.Add(c.name, 1)
+
+		// Keep latest modification time
+		created := meta.Created
+		if secret.Metadata.Created.Before(created) {
+			secret.Metadata.Created = created
+		}
+		meta = secret.Metadata
+
+		// Try to decode as JSON object
+		var obj map[string]interface{}
+		if errDecode := json.Unmarshal(secret.Value, &obj); errDecode != nil || obj == nil {
+			// Not mergeable, replace the whole value
+			raw, merged, origins = secret.Value, nil, []string{c.name}
+			continue
+		}
+
+		if merged == nil {
+			merged, origins = obj, []string{c.name}
+		} else {
+			merged, origins = deepMerge(merged, obj), append(origins, c.name)
+		}
+		raw = nil
+	}
+
+	if len(origins) == 0 {
+		return nil, fmt.Errorf("merge: '%s' not found in any backend: %w", id, storage.ErrSecretNotFound)
+	}
+	if len(origins) > 1 {
+		meta.Version = ""
+	}
+	meta.Origin = strings.Join(origins, ",")
+	if merged == nil {
+		return &storage.Secret{Value: raw, Metadata: meta}, nil
+	}
+
+	// Encode merged object
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(merged); err != nil {
+		return nil, fmt.Errorf("merge: unable to encode secret: %w", err)
+	}
+
+	// No error
+	meta.ContentType = "application/json"
+	return &storage.Secret{Value: buf.Bytes(), Metadata: meta}, nil
+}
+
+func (e *mergeEngine) List(ctx context.Context, prefix string) ([]string, error) {
+	return list(ctx, e.children, prefix)
+}
+
+func (e *mergeEngine) Notify(fn func()) func() {
+	return notify(e.children, fn)
+}
+
+func (e *mergeEngine) Close() error {
+	return closeChildren(e.children)
+}
+
+// -----------------------------------------------------------------------------
+
+func deepMerge(dst, src map[string]interface{}) map[string]interface{} {
+	for k, v := range src {
+		srcMap, srcOk := v.(map[string]interface{})
+		dstMap, dstOk := dst[k].(map[string]interface{})
+		if srcOk && dstOk {
+			dst[k] = deepMerge(dstMap, srcMap)
+			continue
+		}
+		dst[k] = v
+	}
+
+	return dst
+}
@@ -0,0 +1,93 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package composite
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/elastic/harp-plugins/server/pkg/server/storage"
+)
+
+// raceEngine queries all children concurrently and returns the first success.
+type raceEngine struct {
+	children []*child
+}
+
+type raceResult struct {
+	child *child
+	out   *storage.Secret
+	err   error
+}
+
+func (e *raceEngine) Get(ctx context.Context, id string) ([]byte, error) {
+	secret, err := e.GetWithMetadata(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return secret.Value, nil
+}
+
+func (e *raceEngine) GetWithMetadata(ctx context.Context, id string) (*storage.Secret, error) {
+	// Cancel pending queries on first success
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Buffered to never block late children
+	results := make(chan *raceResult, len(e.children))
+	for _, c := range e.children {
+		go func(c *child) {
+			out, err := c.getWithMetadata(ctx, id)
+			results <- &raceResult{child: c, out: out, err: err}
+		}(c)
+	}
+
+	var (
+		lastErr  error
+		notFound = true
+	)
+	for range e.children {
+		res := <-results
+		if res.err == nil {
+			answers.Add(res.child.name, 1)
+			return res.out, nil
+		}
+
+		lastErr = res.err
+		notFound = notFound && isNotFound(res.err)
+	}
+
+	if notFound {
+		return nil, fmt.Errorf("race: '%s' not found in any backend: %w", id, storage.ErrSecretNotFound)
+	}
+
+	return nil, fmt.Errorf("race: all backends failed: %w", lastErr)
+}
+
+func (e *raceEngine) List(ctx context.Context, prefix string) ([]string, error) {
+	return list(ctx, e.children, prefix)
+}
+
+func (e *raceEngine) Notify(fn func()) func() {
+	return notify(e.children, fn)
+}
+
+func (e *raceEngine) Close() error {
+	return closeChildren(e.children)
+}
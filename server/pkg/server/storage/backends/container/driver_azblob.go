@@ -23,27 +23,22 @@ import (
 	"fmt"
 	"io"
 
-	storage "github.com/Azure/azure-sdk-for-go/storage"
-
 	cloudstorage "github.com/elastic/harp-plugins/server/pkg/cloud/storage"
 )
 
 type azureBlobLoader struct {
-	connString string
-	bucketName string
-	prefix     string
+	backend cloudstorage.Backend
 }
 
 // Reader returns the file Reader
 func (d *azureBlobLoader) Reader(ctx context.Context, key string) (io.ReadCloser, error) {
-	// Create an Azure Stroage client
-	client, err := storage.NewClientFromConnectionString(d.connString)
-	if err != nil {
-		return nil, fmt.Errorf("azblob: unable to initialize storage client: %w", err)
+	// Check fields
+	if d.backend == nil {
+		return nil, errors.New("azblob: backend is nil")
 	}
 
 	// Retrieve using Azure storage backend
-	result, err := cloudstorage.AzureBlob(&client, d.bucketName, d.prefix).GetObject(ctx, key)
+	result, err := d.backend.GetObject(ctx, key)
 	if err != nil {
 		return nil, fmt.Errorf("azblob: cloudstorage error: %w", err)
 	}
@@ -21,6 +21,7 @@ import (
 	"context"
 	"io"
 	"io/fs"
+	"strings"
 )
 
 type fileLoader struct {
@@ -29,5 +30,6 @@ type fileLoader struct {
 
 // Reader returns the file Reader
 func (d *fileLoader) Reader(_ context.Context, key string) (io.ReadCloser, error) {
-	return d.fs.Open(key)
+	// Filesystem paths are relative to the filesystem root
+	return d.fs.Open(strings.TrimPrefix(key, "/"))
 }
@@ -23,26 +23,22 @@ import (
 	"fmt"
 	"io"
 
-	"cloud.google.com/go/storage"
-
 	cloudstorage "github.com/elastic/harp-plugins/server/pkg/cloud/storage"
 )
 
 type gcsLoader struct {
-	bucketName string
-	prefix     string
+	backend cloudstorage.Backend
 }
 
 // Reader returns the file Reader
 func (d *gcsLoader) Reader(ctx context.Context, key string) (io.ReadCloser, error) {
-	// Create a Google Storage client
-	client, err := storage.NewClient(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("gcs: unable to initialize storage client: %w", err)
+	// Check fields
+	if d.backend == nil {
+		return nil, errors.New("gcs: backend is nil")
 	}
 
-	// Retrieve using S3 storage backend
-	result, err := cloudstorage.GCS(client, d.bucketName, d.prefix).GetObject(ctx, key)
+	// Retrieve using GCS storage backend
+	result, err := d.backend.GetObject(ctx, key)
 	if err != nil {
 		return nil, fmt.Errorf("gcs: cloudstorage error: %w", err)
 	}
@@ -24,22 +24,19 @@ import (
 	"io"
 	"strings"
 
-	"github.com/aws/aws-sdk-go/service/s3/s3iface"
-
 	"github.com/elastic/harp-plugins/server/pkg/cloud/storage"
 )
 
 type s3Loader struct {
-	s3api      s3iface.S3API
+	backend    storage.Backend
 	bucketName string
-	prefix     string
 }
 
 // Reader returns the file Reader
 func (d *s3Loader) Reader(ctx context.Context, key string) (io.ReadCloser, error) {
 	// Check fields
-	if d.s3api == nil {
-		return nil, fmt.Errorf("s3 service is nil")
+	if d.backend == nil {
+		return nil, fmt.Errorf("s3 backend is nil")
 	}
 	if d.bucketName == "" {
 		return nil, fmt.Errorf("bucktName is blank")
@@ -49,7 +46,7 @@ func (d *s3Loader) Reader(ctx context.Context, key string) (io.ReadCloser, error
 	key = strings.TrimPrefix(key, fmt.Sprintf("/%s/", d.bucketName))
 
 	// Retrieve using S3 storage backend
-	result, err := storage.S3(d.s3api, d.bucketName, d.prefix).GetObject(ctx, key)
+	result, err := d.backend.GetObject(ctx, key)
 	if err != nil {
 		return nil, fmt.Errorf("s3: cloudstorage error: %w", err)
 	}
@@ -19,25 +19,198 @@ package container
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/fs"
 	"net/url"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/elastic/harp-plugins/server/pkg/server/storage"
+	bundlev1 "github.com/elastic/harp/api/gen/go/harp/bundle/v1"
+	"github.com/elastic/harp/pkg/bundle/secret"
+)
+
+// keySeparator separates the package name from the secret key in identifiers.
+const keySeparator = "#"
+
+// ContentTypePackage is the content type of a whole package, served as the
+// serialized bundlev1.Package protobuf message.
+const ContentTypePackage = "application/vnd.harp.bundle.v1.package+protobuf"
+
+const (
+	contentTypeJSON   = "application/json"
+	contentTypeText   = "text/plain; charset=utf-8"
+	contentTypeBinary = "application/octet-stream"
 )
 
 type engine struct {
-	u  *url.URL
-	fs fs.ReadFileFS
+	u       *url.URL
+	fs      fs.ReadFileFS
+	origins map[string]string
 }
 
 // -----------------------------------------------------------------------------
 
 func (e *engine) Get(ctx context.Context, id string) ([]byte, error) {
+	s, err := e.GetWithMetadata(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// No error
+	return s.Value, nil
+}
+
+// GetWithMetadata returns the serialized package, or a single secret value
+// when the identifier is suffixed by '#<key>'. Package annotations are exposed
+// as custom metadata, and the source container as origin for merged bundles.
+func (e *engine) GetWithMetadata(ctx context.Context, id string) (*storage.Secret, error) {
+	// Split package name and secret key
+	name, key := strings.TrimPrefix(id, "/"), ""
+	if idx := strings.Index(name, keySeparator); idx >= 0 {
+		name, key = name[:idx], name[idx+len(keySeparator):]
+	}
+
 	// Open and read all file content
-	out, err := fs.ReadFile(e.fs, id)
+	out, err := fs.ReadFile(e.fs, name)
 	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, storage.ErrSecretNotFound
+		}
 		return nil, fmt.Errorf("bundle: unable to read file content: %w", err)
 	}
 
+	// Decode package
+	var p bundlev1.Package
+	if err := proto.Unmarshal(out, &p); err != nil {
+		return nil, fmt.Errorf("bundle: unable to decode package '%s': %w", name, err)
+	}
+
+	// Prepare metadata
+	meta := storage.Metadata{
+		CustomMetadata: p.Annotations,
+		ContentType:    ContentTypePackage,
+		Origin:         e.origins[name],
+	}
+
+	// Whole package requested
+	if key == "" {
+		return &storage.Secret{Value: out, Metadata: meta}, nil
+	}
+
+	// Unpack secret values
+	data, err := unpack(&p)
+	if err != nil {
+		return nil, err
+	}
+
+	// Lookup secret key
+	v, ok := data[key]
+	if !ok {
+		return nil, storage.ErrSecretNotFound
+	}
+
+	// Encode value according to its type
+	var body []byte
+	switch val := v.(type) {
+	case string:
+		body, meta.ContentType = []byte(val), contentTypeText
+	case []byte:
+		body, meta.ContentType = val, contentTypeBinary
+	default:
+		meta.ContentType = contentTypeJSON
+		body, err = json.Marshal(val)
+		if err != nil {
+			return nil, fmt.Errorf("bundle: unable to encode secret '%s' value: %w", key, err)
+		}
+	}
+
+	// No error
+	return &storage.Secret{Value: body, Metadata: meta}, nil
+}
+
+// List returns the package names starting with the given prefix.
+func (e *engine) List(ctx context.Context, prefix string) ([]string, error) {
+	prefix = strings.TrimPrefix(prefix, "/")
+
+	res := []string{}
+	err := fs.WalkDir(e.fs, "", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.HasPrefix(name, prefix) {
+			res = append(res, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("bundle: unable to list packages: %w", err)
+	}
+	sort.Strings(res)
+
+	// No error
+	return res, nil
+}
+
+// -----------------------------------------------------------------------------
+
+// JSON returns the given secret with a whole package converted to a JSON object
+// of its secret values, other secrets are returned as-is. It is used by
+// dispatchers exposing packages as documents, the gRPC BundleAPI serves the
+// serialized package.
+func JSON(s *storage.Secret) (*storage.Secret, error) {
+	// Check arguments
+	if s == nil || s.Metadata.ContentType != ContentTypePackage {
+		return s, nil
+	}
+
+	// Decode package
+	var p bundlev1.Package
+	if err := proto.Unmarshal(s.Value, &p); err != nil {
+		return nil, fmt.Errorf("bundle: unable to decode package: %w", err)
+	}
+
+	// Unpack secret values
+	data, err := unpack(&p)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("bundle: unable to encode package '%s': %w", p.Name, err)
+	}
+
+	// Content type is updated, other metadata are preserved
+	meta := s.Metadata
+	meta.ContentType = contentTypeJSON
+
+	// No error
+	return &storage.Secret{Value: body, Metadata: meta}, nil
+}
+
+// unpack returns the decoded secret values of the given package.
+func unpack(p *bundlev1.Package) (map[string]interface{}, error) {
+	data := map[string]interface{}{}
+
+	// Check arguments
+	if p.Secrets == nil {
+		return data, nil
+	}
+
+	for _, s := range p.Secrets.Data {
+		// Unpack secret value
+		var v interface{}
+		if err := secret.Unpack(s.Value, &v); err != nil {
+			return nil, fmt.Errorf("bundle: unable to unpack secret '%s' value of package '%s': %w", s.Key, p.Name, err)
+		}
+		data[s.Key] = v
+	}
+
 	// No error
-	return out, nil
+	return data, nil
 }
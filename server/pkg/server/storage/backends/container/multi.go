@@ -0,0 +1,135 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package container
+
+import (
+	"fmt"
+	"net/url"
+
+	"go.uber.org/zap"
+
+	"github.com/elastic/harp-plugins/server/pkg/server/storage"
+	bundlev1 "github.com/elastic/harp/api/gen/go/harp/bundle/v1"
+	"github.com/elastic/harp/pkg/sdk/log"
+)
+
+const schemeBundleMulti = "bundle+multi"
+
+// Package conflict resolution policies.
+const (
+	conflictFirstWins = "first-wins"
+	conflictLastWins  = "last-wins"
+	conflictError     = "error"
+)
+
+// buildMulti returns an engine serving the merged content of several
+// containers, each source being a bundle URL with its own keys.
+func buildMulti(u *url.URL) (storage.Engine, error) {
+	var (
+		q        = u.Query()
+		sources  = q["b"]
+		conflict = withDefault(q, "conflict", conflictError)
+	)
+
+	// Check arguments
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("%s: at least one container must be declared using 'b' parameter", u.Scheme)
+	}
+	switch conflict {
+	case conflictFirstWins, conflictLastWins, conflictError:
+	default:
+		return nil, fmt.Errorf("%s: invalid conflict policy '%s', expected %s, %s or %s", u.Scheme, conflict, conflictFirstWins, conflictLastWins, conflictError)
+	}
+	if q.Get("cid") != "" || q.Get("unlock") != "" {
+		return nil, fmt.Errorf("%s: container keys must be declared in each source url", u.Scheme)
+	}
+
+	// Prepare merged package selection
+	sel, err := selectionFromURL(q)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", u.Scheme, err)
+	}
+
+	var (
+		merged  = &bundlev1.Bundle{}
+		index   = map[string]int{}
+		origins = map[string]string{}
+	)
+	for i, raw := range sources {
+		// Parse source URL
+		su, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid source #%d url", u.Scheme, i)
+		}
+		if su.Scheme == schemeBundleMulti {
+			return nil, fmt.Errorf("%s: source #%d could not be a merged container", u.Scheme, i)
+		}
+		origin := originName(su)
+
+		// Load source bundle
+		loader, err := newLoader(su)
+		if err != nil {
+			return nil, fmt.Errorf("%s: unable to prepare source '%s': %w", u.Scheme, origin, err)
+		}
+		b, err := loadBundle(su, loader)
+		if err != nil {
+			return nil, fmt.Errorf("%s: unable to load source '%s': %w", u.Scheme, origin, err)
+		}
+
+		// Merge packages
+		for _, p := range b.Packages {
+			idx, exists := index[p.Name]
+			if !exists {
+				index[p.Name] = len(merged.Packages)
+				origins[p.Name] = origin
+				merged.Packages = append(merged.Packages, p)
+				continue
+			}
+
+			// Resolve conflict
+			switch conflict {
+			case conflictError:
+				return nil, fmt.Errorf("%s: package '%s' is defined in '%s' and '%s'", u.Scheme, p.Name, origins[p.Name], origin)
+			case conflictLastWins:
+				log.Bg().Warn("Package overridden by a later container", zap.String("package", p.Name), zap.String("previous", origins[p.Name]), zap.String("origin", origin))
+				merged.Packages[idx] = p
+				origins[p.Name] = origin
+			default:
+				log.Bg().Warn("Package ignored, already defined by a previous container", zap.String("package", p.Name), zap.String("origin", origin), zap.String("served", origins[p.Name]))
+			}
+		}
+	}
+
+	// Restrict exposed packages
+	merged, err = sel.apply(merged)
+	if err != nil {
+		return nil, fmt.Errorf("%s: unable to select bundle packages: %w", u.Scheme, err)
+	}
+
+	// Build engine instance
+	return newEngine(u, merged, origins)
+}
+
+// originName returns the source URL without parameters to prevent key leaks.
+func originName(u *url.URL) string {
+	return (&url.URL{
+		Scheme: u.Scheme,
+		Host:   u.Host,
+		Path:   u.Path,
+	}).String()
+}
@@ -20,7 +20,6 @@ package container
 import (
 	"context"
 	"encoding/base64"
-	"errors"
 	"fmt"
 	"io"
 	"net/url"
@@ -33,7 +32,11 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/elastic/harp-plugins/server/pkg/cloud/aws/session"
+	azuresession "github.com/elastic/harp-plugins/server/pkg/cloud/azure/session"
+	gcpsession "github.com/elastic/harp-plugins/server/pkg/cloud/gcp/session"
+	cloudstorage "github.com/elastic/harp-plugins/server/pkg/cloud/storage"
 	"github.com/elastic/harp-plugins/server/pkg/server/storage"
+	"github.com/elastic/harp-plugins/server/pkg/server/storage/backends/object"
 	bundlev1 "github.com/elastic/harp/api/gen/go/harp/bundle/v1"
 	containerv1 "github.com/elastic/harp/api/gen/go/harp/container/v1"
 	"github.com/elastic/harp/pkg/bundle"
@@ -79,6 +82,7 @@ func init() {
 		storage.MustRegister(schemeBundleFromGCS, build)
 		storage.MustRegister(schemeBundleFromAzBlob, build)
 		storage.MustRegister(schemeBundleStdin, build)
+		storage.MustRegister(schemeBundleMulti, buildMulti)
 	})
 }
 
@@ -93,9 +97,20 @@ func withDefault(q url.Values, key, defaultValue string) string {
 	return v
 }
 
+func build(u *url.URL) (storage.Engine, error) {
+	// Prepare bundle loader
+	loader, err := newLoader(u)
+	if err != nil {
+		return nil, err
+	}
+
+	// Delegate to loader
+	return buildWithLoader(u, loader)
+}
+
 // To refactor implements strategy pattern and probably plugins extension
 // via named pipe or gRPC servers like TF providers.
-func build(u *url.URL) (storage.Engine, error) {
+func newLoader(u *url.URL) (Loader, error) {
 	q := u.Query()
 
 	switch u.Scheme {
@@ -105,47 +120,83 @@ func build(u *url.URL) (storage.Engine, error) {
 		if err != nil {
 			return nil, fmt.Errorf("unable to parse session URL: %w", err)
 		}
-		// Build AWS session
-		sess, err := session.NewSession(opts)
+		// Select local object storage backend
+		backend, local, err := object.FromURL(u, opts.BucketName, "")
 		if err != nil {
-			return nil, fmt.Errorf("unable to initialize session: %w", err)
+			return nil, err
+		}
+		if !local {
+			// Build AWS session
+			sess, err := session.NewSession(opts)
+			if err != nil {
+				return nil, fmt.Errorf("unable to initialize session: %w", err)
+			}
+			backend = cloudstorage.S3(s3.New(sess), opts.BucketName, "")
 		}
-		// Delegate to loader
-		return buildWithLoader(u, &s3Loader{
-			s3api:      s3.New(sess),
+		return &s3Loader{
+			backend:    backend,
 			bucketName: opts.BucketName,
-		})
+		}, nil
 	case schemeBundleFromAzBlob:
-		azureConnString := os.Getenv("AZURE_CONNECTION_STRING")
-		if azureConnString == "" {
-			return nil, errors.New("AZURE_CONNECTION_STRING env. variable must be set for azblob backend")
+		// Select local object storage backend
+		backend, local, err := object.FromURL(u, u.Hostname(), withDefault(q, "prefix", ""))
+		if err != nil {
+			return nil, err
+		}
+		if !local {
+			// Build Azure client from url
+			opts, err := azuresession.FromURL(u.String())
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse session URL: %w", err)
+			}
+			client, err := azuresession.NewClient(opts)
+			if err != nil {
+				return nil, fmt.Errorf("azblob: %w", err)
+			}
+			backend = cloudstorage.AzureBlob(client, u.Hostname(), withDefault(q, "prefix", ""))
 		}
-		return buildWithLoader(u, &azureBlobLoader{
-			bucketName: u.Hostname(),
-			prefix:     withDefault(q, "prefix", ""),
-			connString: azureConnString,
-		})
+		return &azureBlobLoader{
+			backend: backend,
+		}, nil
 	case schemeBundleFromGCS:
-		return buildWithLoader(u, &gcsLoader{
-			bucketName: u.Hostname(),
-			prefix:     withDefault(q, "prefix", ""),
-		})
+		// Select local object storage backend
+		backend, local, err := object.FromURL(u, u.Hostname(), withDefault(q, "prefix", ""))
+		if err != nil {
+			return nil, err
+		}
+		if !local {
+			// Build Google client from url
+			opts, err := gcpsession.FromURL(u.String())
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse session URL: %w", err)
+			}
+			// Containers are only read
+			opts.ReadOnly = true
+			client, err := gcpsession.NewClient(opts)
+			if err != nil {
+				return nil, fmt.Errorf("gcs: %w", err)
+			}
+			backend = cloudstorage.GCS(client, u.Hostname(), withDefault(q, "prefix", ""))
+		}
+		return &gcsLoader{
+			backend: backend,
+		}, nil
 	case schemeBundleFromHTTP:
-		return buildWithLoader(u, &httpLoader{
+		return &httpLoader{
 			scheme: "http",
 			host:   u.Host,
-		})
+		}, nil
 	case schemeBundleFromHTTPS:
-		return buildWithLoader(u, &httpLoader{
+		return &httpLoader{
 			scheme: "https",
 			host:   u.Host,
-		})
+		}, nil
 	case schemeBundleDefault, schemeBundleFromFile:
-		return buildWithLoader(u, &fileLoader{
+		return &fileLoader{
 			fs: os.DirFS("/"),
-		})
+		}, nil
 	case schemeBundleStdin:
-		return buildWithLoader(u, &stdinLoader{})
+		return &stdinLoader{}, nil
 
 	default:
 	}
@@ -154,6 +205,25 @@ func build(u *url.URL) (storage.Engine, error) {
 }
 
 func buildWithLoader(u *url.URL, loader Loader) (storage.Engine, error) {
+	// Load bundle using loader
+	b, err := loadBundle(u, loader)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build engine instance
+	return newEngine(u, b, nil)
+}
+
+// loadBundle retrieves, unseals and filters the bundle described by the given
+// URL.
+func loadBundle(u *url.URL, loader Loader) (*bundlev1.Bundle, error) {
+	// Prepare package selection before loading the bundle
+	sel, err := selectionFromURL(u.Query())
+	if err != nil {
+		return nil, err
+	}
+
 	// Initialize context
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -163,6 +233,7 @@ func buildWithLoader(u *url.URL, loader Loader) (storage.Engine, error) {
 	if errDriver != nil {
 		return nil, fmt.Errorf("unable to load container content: %w", errDriver)
 	}
+	defer br.Close()
 
 	// Extract bundle container key form url
 	var (
@@ -177,6 +248,19 @@ func buildWithLoader(u *url.URL, loader Loader) (storage.Engine, error) {
 		return nil, fmt.Errorf("unable to extract bundle: %w", err)
 	}
 
+	// Restrict exposed packages
+	b, err = sel.apply(b)
+	if err != nil {
+		return nil, fmt.Errorf("unable to select bundle packages: %w", err)
+	}
+
+	// No error
+	return b, nil
+}
+
+// newEngine returns an engine serving the given bundle. Origins associate
+// package names to the container they have been loaded from.
+func newEngine(u *url.URL, b *bundlev1.Bundle, origins map[string]string) (storage.Engine, error) {
 	// Initialize virtual filesystem
 	bfs, err := fs.FromBundle(b)
 	if err != nil {
@@ -185,8 +269,9 @@ func buildWithLoader(u *url.URL, loader Loader) (storage.Engine, error) {
 
 	// Build engine instance
 	return &engine{
-		u:  u,
-		fs: bfs,
+		u:       u,
+		fs:      bfs,
+		origins: origins,
 	}, nil
 }
 
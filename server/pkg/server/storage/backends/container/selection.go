@@ -0,0 +1,159 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package container
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/jmespath/go-jmespath"
+	"k8s.io/apimachinery/pkg/labels"
+
+	bundlev1 "github.com/elastic/harp/api/gen/go/harp/bundle/v1"
+	"github.com/elastic/harp/pkg/bundle/patch"
+	"github.com/elastic/harp/pkg/bundle/selector"
+)
+
+// selection describes the bundle packages exposed by the engine.
+type selection struct {
+	patch       *bundlev1.Patch
+	labels      labels.Selector
+	annotations map[string]string
+	prefix      string
+	query       selector.Specification
+}
+
+// selectionFromURL builds package selection from URL parameters, nil is
+// returned if no filter is defined.
+func selectionFromURL(q url.Values) (*selection, error) {
+	s := &selection{}
+	defined := false
+
+	// Bundle patch applied before filters
+	if path := q.Get("patch"); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open bundle patch '%s': %w", path, err)
+		}
+		defer f.Close()
+
+		s.patch, err = patch.YAML(f)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse bundle patch '%s': %w", path, err)
+		}
+		defined = true
+	}
+
+	// Package label selector
+	if v := q.Get("label-selector"); v != "" {
+		sel, err := labels.Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid label selector '%s': %w", v, err)
+		}
+		s.labels = sel
+		defined = true
+	}
+
+	// Package annotations
+	for _, v := range q["annotation"] {
+		parts := strings.SplitN(v, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid annotation matcher '%s', expected <key>=<value>", v)
+		}
+		if s.annotations == nil {
+			s.annotations = map[string]string{}
+		}
+		s.annotations[parts[0]] = parts[1]
+		defined = true
+	}
+
+	// Package name prefix
+	if v := q.Get("package-prefix"); v != "" {
+		s.prefix = strings.TrimPrefix(v, "/")
+		defined = true
+	}
+
+	// JMESPath package query
+	if v := q.Get("query"); v != "" {
+		exp, err := jmespath.Compile(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid package query '%s': %w", v, err)
+		}
+		s.query = selector.MatchJMESPath(exp)
+		defined = true
+	}
+
+	if !defined {
+		return nil, nil
+	}
+
+	// No error
+	return s, nil
+}
+
+// apply restricts the given bundle to selected packages.
+func (s *selection) apply(b *bundlev1.Bundle) (*bundlev1.Bundle, error) {
+	// Check arguments
+	if s == nil {
+		return b, nil
+	}
+
+	// Apply bundle patch
+	if s.patch != nil {
+		var err error
+		b, err = patch.Apply(s.patch, b, nil)
+		if err != nil {
+			return nil, fmt.Errorf("unable to apply bundle patch: %w", err)
+		}
+	}
+
+	// Filter packages
+	packages := []*bundlev1.Package{}
+	for _, p := range b.Packages {
+		if p == nil || !s.match(p) {
+			continue
+		}
+		packages = append(packages, p)
+	}
+
+	b.Packages = packages
+
+	// No error
+	return b, nil
+}
+
+func (s *selection) match(p *bundlev1.Package) bool {
+	if s.prefix != "" && !strings.HasPrefix(p.Name, s.prefix) {
+		return false
+	}
+	if s.labels != nil && !s.labels.Matches(labels.Set(p.Labels)) {
+		return false
+	}
+	for k, v := range s.annotations {
+		if actual, ok := p.Annotations[k]; !ok || actual != v {
+			return false
+		}
+	}
+	if s.query != nil && !s.query.IsSatisfiedBy(p) {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,256 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	// Register SQL drivers
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+
+	"github.com/elastic/harp-plugins/server/pkg/server/storage"
+	"github.com/elastic/harp/pkg/sdk/log"
+)
+
+const (
+	schemeSQLite   = "sqlite"
+	schemePostgres = "postgres"
+
+	defaultTable       = "secrets"
+	defaultKeyColumn   = "path"
+	defaultValueColumn = "value"
+	defaultTimeout     = 5 * time.Second
+)
+
+// PostgreSQL driver query parameters passed through the DSN, all others are
+// engine or decorator settings.
+var postgresParams = []string{
+	"sslmode", "sslcert", "sslkey", "sslrootcert", "sslinline",
+	"connect_timeout", "application_name", "fallback_application_name",
+	"krbsrvname", "krbspn", "search_path",
+}
+
+var identifierRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+type engine struct {
+	db      *sql.DB
+	timeout time.Duration
+	get     *sql.Stmt
+	list    *sql.Stmt
+}
+
+func build(u *url.URL) (storage.Engine, error) {
+	// Check arguments
+	if u == nil {
+		return nil, fmt.Errorf("unable to prepare database with nil url")
+	}
+
+	var (
+		q           = u.Query()
+		table       = withDefault(q, "table", defaultTable)
+		keyColumn   = withDefault(q, "key-column", defaultKeyColumn)
+		valueColumn = withDefault(q, "value-column", defaultValueColumn)
+	)
+
+	// Validate identifiers to prevent SQL injection
+	for _, ident := range []string{table, keyColumn, valueColumn} {
+		if !identifierRegex.MatchString(ident) {
+			return nil, fmt.Errorf("database: invalid identifier '%s'", ident)
+		}
+	}
+
+	// Parse query timeout
+	timeout := defaultTimeout
+	if raw := q.Get("timeout"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return nil, fmt.Errorf("database: invalid timeout value: %w", err)
+		}
+		timeout = d
+	}
+
+	// Prepare driver settings
+	var driver, dsn, placeholder string
+	switch u.Scheme {
+	case schemeSQLite:
+		if u.Path == "" {
+			return nil, errors.New("database: sqlite file path is mandatory")
+		}
+		driver, dsn, placeholder = "sqlite", u.Path, "?"
+	case schemePostgres:
+		driver, dsn, placeholder = "postgres", driverDSN(u, postgresParams), "$1"
+	default:
+		return nil, fmt.Errorf("database: unsupported scheme '%s'", u.Scheme)
+	}
+
+	// Open database
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("database: unable to open connection: %w", err)
+	}
+
+	// Delegate to engine constructor
+	e, err := newEngine(db, timeout, placeholder, table, keyColumn, valueColumn)
+	if err != nil {
+		// Release connection pool
+		log.CheckErr("Unable to close database connection", db.Close())
+		return nil, err
+	}
+
+	// No error
+	return e, nil
+}
+
+func init() {
+	// Register to storage factory
+	storage.MustRegister(schemeSQLite, build)
+	storage.MustRegister(schemePostgres, build)
+}
+
+func newEngine(db *sql.DB, timeout time.Duration, placeholder, table, keyColumn, valueColumn string) (storage.Engine, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Check connection
+	if err := db.PingContext(ctx); err != nil {
+		return nil, fmt.Errorf("database: unable to connect: %w", err)
+	}
+
+	// Prepare statements
+	get, err := db.PrepareContext(ctx, fmt.Sprintf(`SELECT %s FROM %s WHERE %s = %s`, valueColumn, table, keyColumn, placeholder))
+	if err != nil {
+		return nil, fmt.Errorf("database: unable to prepare get statement: %w", err)
+	}
+	list, err := db.PrepareContext(ctx, fmt.Sprintf(`SELECT %s FROM %s WHERE %s LIKE %s ESCAPE '\' ORDER BY %s`, keyColumn, table, keyColumn, placeholder, keyColumn))
+	if err != nil {
+		log.CheckErr("Unable to close get statement", get.Close())
+		return nil, fmt.Errorf("database: unable to prepare list statement: %w", err)
+	}
+
+	// Build engine instance
+	return &engine{
+		db:      db,
+		timeout: timeout,
+		get:     get,
+		list:    list,
+	}, nil
+}
+
+// -----------------------------------------------------------------------------
+
+func (e *engine) Get(ctx context.Context, id string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	// Query value
+	var value []byte
+	err := e.get.QueryRowContext(ctx, strings.TrimPrefix(id, "/")).Scan(&value)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, fmt.Errorf("database: '%s' not found: %w", id, storage.ErrSecretNotFound)
+	case err != nil:
+		return nil, fmt.Errorf("database: unable to query secret: %w", err)
+	}
+
+	// No error
+	return value, nil
+}
+
+func (e *engine) List(ctx context.Context, prefix string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	// Escape LIKE wildcards
+	pattern := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(strings.TrimPrefix(prefix, "/"))
+
+	// Query keys
+	rows, err := e.list.QueryContext(ctx, pattern+"%")
+	if err != nil {
+		return nil, fmt.Errorf("database: unable to list secrets: %w", err)
+	}
+	defer rows.Close()
+
+	res := []string{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("database: unable to read secret key: %w", err)
+		}
+		res = append(res, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("database: unable to list secrets: %w", err)
+	}
+
+	// No error
+	return res, nil
+}
+
+// Close releases prepared statements and the connection pool.
+func (e *engine) Close() error {
+	var firstErr error
+	for _, c := range []io.Closer{e.get, e.list, e.db} {
+		if err := c.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return fmt.Errorf("database: unable to close engine: %w", firstErr)
+	}
+
+	// No error
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+
+func withDefault(q url.Values, key, defaultValue string) string {
+	v := q.Get(key)
+	if v == "" {
+		return defaultValue
+	}
+
+	return v
+}
+
+// driverDSN returns the URL with only the given driver query parameters.
+func driverDSN(u *url.URL, params []string) string {
+	// Copy URL
+	dsn := *u
+
+	// Keep allowed driver settings
+	q := u.Query()
+	allowed := url.Values{}
+	for _, p := range params {
+		if v, ok := q[p]; ok {
+			allowed[p] = v
+		}
+	}
+	dsn.RawQuery = allowed.Encode()
+
+	return dsn.String()
+}
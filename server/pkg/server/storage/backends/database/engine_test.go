@@ -0,0 +1,153 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/elastic/harp-plugins/server/pkg/server/storage"
+)
+
+func memoryEngine(t *testing.T) storage.Engine {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("unable to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	// In-memory databases are bound to their connection
+	db.SetMaxOpenConns(1)
+
+	for _, stmt := range []string{
+		`CREATE TABLE secrets (path TEXT PRIMARY KEY, value BLOB)`,
+		`INSERT INTO secrets VALUES ('app/db', 'db-secret'), ('app/api', 'api-secret'), ('app_x/key', 'x'), ('infra/tls', 'tls')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("unable to prepare database: %v", err)
+		}
+	}
+
+	e, err := newEngine(db, time.Second, "?", defaultTable, defaultKeyColumn, defaultValueColumn)
+	if err != nil {
+		t.Fatalf("unable to build engine: %v", err)
+	}
+
+	return e
+}
+
+func TestEngine_Get(t *testing.T) {
+	e := memoryEngine(t)
+
+	value, err := e.Get(context.Background(), "/app/db")
+	if err != nil || string(value) != "db-secret" {
+		t.Fatalf("Get() = %q, %v", value, err)
+	}
+
+	if _, err := e.Get(context.Background(), "/app/missing"); !errors.Is(err, storage.ErrSecretNotFound) {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
+
+func TestEngine_List(t *testing.T) {
+	l, ok := memoryEngine(t).(storage.Lister)
+	if !ok {
+		t.Fatal("expected engine to support listing")
+	}
+
+	// LIKE wildcards in prefix are escaped
+	ids, err := l.List(context.Background(), "/app_")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(ids, []string{"app_x/key"}) {
+		t.Fatalf("unexpected identifiers: %v", ids)
+	}
+
+	ids, err = l.List(context.Background(), "/app/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(ids, []string{"app/api", "app/db"}) {
+		t.Fatalf("unexpected identifiers: %v", ids)
+	}
+}
+
+func TestDriverDSN(t *testing.T) {
+	u, err := url.Parse("postgres://user:pass@db:5432/harp?sslmode=verify-full&table=app&key=secret-key&enc_revert=true&path_template=x&connect_timeout=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, expected := driverDSN(u, postgresParams), "postgres://user:pass@db:5432/harp?connect_timeout=5&sslmode=verify-full"; got != expected {
+		t.Fatalf("driverDSN() = %s, expected %s", got, expected)
+	}
+}
+
+func TestEngine_Close(t *testing.T) {
+	e := memoryEngine(t)
+
+	closer, ok := e.(io.Closer)
+	if !ok {
+		t.Fatal("expected engine to be closable")
+	}
+	if err := closer.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Statements and connection pool are released
+	if _, err := e.Get(context.Background(), "/app/db"); err == nil {
+		t.Fatal("expected an error after close")
+	}
+	if err := e.(*engine).db.Ping(); err == nil {
+		t.Fatal("expected connection pool to be closed")
+	}
+}
+
+func TestBuild_SQLite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.db")
+
+	// Prepare database file
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("unable to open database: %v", err)
+	}
+	if _, err := db.Exec(`CREATE TABLE secrets (path TEXT PRIMARY KEY, value BLOB)`); err != nil {
+		t.Fatalf("unable to prepare database: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("unable to close database: %v", err)
+	}
+
+	e, err := build(&url.URL{Scheme: schemeSQLite, Path: path})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := e.(io.Closer).Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,250 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package env
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/subosito/gotenv"
+	"go.uber.org/zap"
+
+	"github.com/elastic/harp-plugins/server/pkg/server/storage"
+	"github.com/elastic/harp/pkg/sdk/log"
+)
+
+const (
+	schemeEnv    = "env"
+	schemeDotEnv = "dotenv"
+
+	defaultSeparator = "_"
+)
+
+// sourceFunc returns the variable set to expose.
+type sourceFunc func() (map[string]string, error)
+
+type engine struct {
+	source    sourceFunc
+	prefix    string
+	separator string
+}
+
+func build(u *url.URL) (storage.Engine, error) {
+	// Check arguments
+	if u == nil {
+		return nil, fmt.Errorf("unable to prepare env with nil url")
+	}
+
+	q := u.Query()
+
+	// Prepare engine
+	e := &engine{
+		prefix:    q.Get("prefix"),
+		separator: q.Get("separator"),
+	}
+	if e.separator == "" {
+		e.separator = defaultSeparator
+	}
+
+	switch u.Scheme {
+	case schemeEnv:
+		// Prevent server environment exposure (credentials, tokens)
+		if e.prefix == "" {
+			return nil, fmt.Errorf("env: prefix is mandatory to restrict exposed variables")
+		}
+		e.source = fromEnviron
+	case schemeDotEnv:
+		if u.Path == "" {
+			return nil, fmt.Errorf("dotenv: file path is mandatory")
+		}
+		e.source = fromFile(u.Path)
+	default:
+		return nil, fmt.Errorf("env: unsupported scheme '%s'", u.Scheme)
+	}
+
+	// Check source and report variables without path
+	_, ignored, err := e.tree()
+	if err != nil {
+		return nil, err
+	}
+	if len(ignored) > 0 {
+		sort.Strings(ignored)
+		log.Bg().Warn("Variables without path segment are not exposed", zap.String("scheme", u.Scheme), zap.Strings("names", ignored))
+	}
+
+	// No error
+	return e, nil
+}
+
+func init() {
+	// Register to storage factory
+	storage.MustRegister(schemeEnv, build)
+	storage.MustRegister(schemeDotEnv, build)
+}
+
+// -----------------------------------------------------------------------------
+
+func (e *engine) Get(_ context.Context, id string) ([]byte, error) {
+	// Retrieve grouped variables
+	tree, _, err := e.tree()
+	if err != nil {
+		return nil, err
+	}
+
+	id = strings.Trim(id, "/")
+
+	// Path matches a group of variables
+	if values, ok := tree[id]; ok {
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(values); err != nil {
+			return nil, fmt.Errorf("env: unable to encode secret: %w", err)
+		}
+
+		return buf.Bytes(), nil
+	}
+
+	// Path matches a single variable
+	idx := strings.LastIndex(id, "/")
+	if idx > 0 {
+		if values, ok := tree[id[:idx]]; ok {
+			if v, ok := values[id[idx+1:]]; ok {
+				return []byte(v), nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("env: '%s' not found: %w", id, storage.ErrSecretNotFound)
+}
+
+func (e *engine) List(_ context.Context, prefix string) ([]string, error) {
+	// Retrieve grouped variables
+	tree, _, err := e.tree()
+	if err != nil {
+		return nil, err
+	}
+
+	prefix = strings.TrimPrefix(prefix, "/")
+
+	res := []string{}
+	for p := range tree {
+		if strings.HasPrefix(p, prefix) {
+			res = append(res, p)
+		}
+	}
+
+	// Sort for stable output
+	sort.Strings(res)
+
+	// No error
+	return res, nil
+}
+
+// -----------------------------------------------------------------------------
+
+// tree groups variables by path. The last name segment is used as object key,
+// preceding segments as secret path. Variables without path segment are
+// returned as ignored names. Names differing only by case designate the same
+// key, they are rejected instead of being silently overwritten.
+//
+// APP_DB_USER=foo with prefix APP_ => db: {"user": "foo"}
+func (e *engine) tree() (map[string]map[string]string, []string, error) {
+	// Retrieve variables
+	vars, err := e.source()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Process names in order for stable collision reports
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	res := map[string]map[string]string{}
+	origins := map[[2]string]string{}
+	ignored := []string{}
+	for _, fullName := range names {
+		// Filter by prefix
+		if !strings.HasPrefix(fullName, e.prefix) {
+			continue
+		}
+		name := strings.TrimPrefix(fullName, e.prefix)
+
+		// Split name as path segments
+		parts := strings.Split(strings.ToLower(name), strings.ToLower(e.separator))
+		if len(parts) < 2 {
+			ignored = append(ignored, fullName)
+			continue
+		}
+
+		// Check key collisions
+		p, key := strings.Join(parts[:len(parts)-1], "/"), parts[len(parts)-1]
+		if previous, ok := origins[[2]string{p, key}]; ok {
+			return nil, nil, fmt.Errorf("env: variables '%s' and '%s' designate the same key '%s' in '%s'", previous, fullName, key, p)
+		}
+		origins[[2]string{p, key}] = fullName
+
+		// Group by path
+		if _, ok := res[p]; !ok {
+			res[p] = map[string]string{}
+		}
+		res[p][key] = vars[fullName]
+	}
+
+	// No error
+	return res, ignored, nil
+}
+
+func fromEnviron() (map[string]string, error) {
+	res := map[string]string{}
+	for _, kv := range os.Environ() {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		res[parts[0]] = parts[1]
+	}
+
+	return res, nil
+}
+
+func fromFile(path string) sourceFunc {
+	return func() (map[string]string, error) {
+		// Open file
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("dotenv: unable to open file: %w", err)
+		}
+		defer f.Close()
+
+		// Parse file content
+		vars, err := gotenv.StrictParse(f)
+		if err != nil {
+			return nil, fmt.Errorf("dotenv: unable to parse file content: %w", err)
+		}
+
+		return vars, nil
+	}
+}
@@ -0,0 +1,225 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package env
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/elastic/harp-plugins/server/pkg/server/storage"
+)
+
+func mustBuild(t *testing.T, raw string) *engine {
+	t.Helper()
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := build(u)
+	if err != nil {
+		t.Fatalf("unable to build engine: %v", err)
+	}
+
+	return e.(*engine)
+}
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name    string
+		url     string
+		wantErr bool
+	}{
+		{name: "env without prefix", url: "env://", wantErr: true},
+		{name: "dotenv without path", url: "dotenv://", wantErr: true},
+		{name: "dotenv missing file", url: "dotenv:///" + filepath.Join(t.TempDir(), "missing.env"), wantErr: true},
+		{name: "env with prefix", url: "env://?prefix=ENV_TEST_BUILD_"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u, err := url.Parse(tc.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := build(u); (err != nil) != tc.wantErr {
+				t.Errorf("error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestEngine_PrefixFilter(t *testing.T) {
+	t.Setenv("ENV_TEST_DB_USER", "admin")
+	t.Setenv("ENV_TEST_DB_PASSWORD", "s3cr3t")
+	t.Setenv("ENV_TEST_CACHE_URL", "redis://cache")
+	t.Setenv("ENV_TESTX_DB_USER", "other")
+	t.Setenv("ENV_TEST_TOKEN", "ignored")
+
+	e := mustBuild(t, "env://?prefix=ENV_TEST_")
+	ctx := context.Background()
+
+	// Groups are exposed as JSON objects
+	out, err := e.Get(ctx, "/db")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(out) != "{\"password\":\"s3cr3t\",\"user\":\"admin\"}\n" {
+		t.Errorf("unexpected group content %q", out)
+	}
+
+	// Single variables are exposed as raw values
+	out, err = e.Get(ctx, "cache/url")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(out) != "redis://cache" {
+		t.Errorf("unexpected variable content %q", out)
+	}
+
+	// Variables without path segment and other prefixes are not exposed
+	for _, id := range []string{"token", "x/db", "db/token"} {
+		if _, err := e.Get(ctx, id); !errors.Is(err, storage.ErrSecretNotFound) {
+			t.Errorf("Get(%q): expected ErrSecretNotFound, got %v", id, err)
+		}
+	}
+
+	// Only prefixed groups are listed
+	list, err := e.List(ctx, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(list, []string{"cache", "db"}) {
+		t.Errorf("unexpected list %v", list)
+	}
+	list, err = e.List(ctx, "/d")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(list, []string{"db"}) {
+		t.Errorf("unexpected filtered list %v", list)
+	}
+}
+
+func TestEngine_Separator(t *testing.T) {
+	t.Setenv("ENV_SEP__DB__USER_NAME", "admin")
+	t.Setenv("ENV_SEP__DB__TLS__CA_CERT", "ca")
+
+	e := mustBuild(t, "env://?prefix=ENV_SEP__&separator=__")
+	ctx := context.Background()
+
+	out, err := e.Get(ctx, "db/user_name")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(out) != "admin" {
+		t.Errorf("unexpected content %q", out)
+	}
+
+	out, err = e.Get(ctx, "db/tls")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(out) != "{\"ca_cert\":\"ca\"}\n" {
+		t.Errorf("unexpected nested group content %q", out)
+	}
+}
+
+func TestEngine_CaseCollision(t *testing.T) {
+	t.Setenv("ENV_CASE_DB_USER", "admin")
+	t.Setenv("ENV_CASE_db_user", "other")
+
+	u, err := url.Parse("env://?prefix=ENV_CASE_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = build(u)
+	if err == nil {
+		t.Fatal("expected an error for colliding names")
+	}
+	if !strings.Contains(err.Error(), "ENV_CASE_DB_USER") || !strings.Contains(err.Error(), "ENV_CASE_db_user") {
+		t.Errorf("expected both variable names in the error, got %q", err.Error())
+	}
+
+	// Different keys of the same group don't collide
+	t.Setenv("ENV_CASE_db_user", "")
+	if err := os.Unsetenv("ENV_CASE_db_user"); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ENV_CASE_db_password", "s3cr3t")
+	e := mustBuild(t, "env://?prefix=ENV_CASE_")
+	out, err := e.Get(context.Background(), "db")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(out) != "{\"password\":\"s3cr3t\",\"user\":\"admin\"}\n" {
+		t.Errorf("unexpected group content %q", out)
+	}
+}
+
+func TestEngine_DotEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	content := "APP_DB_USER=admin\nAPP_DB_PASSWORD=\"s3 cr3t\"\n# comment\nOTHER_DB_USER=other\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	e := mustBuild(t, "dotenv:///"+path+"?prefix=APP_")
+	ctx := context.Background()
+
+	out, err := e.Get(ctx, "db/password")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(out) != "s3 cr3t" {
+		t.Errorf("unexpected content %q", out)
+	}
+
+	list, err := e.List(ctx, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(list, []string{"db"}) {
+		t.Errorf("unexpected list %v", list)
+	}
+
+	// File content is read on each call
+	if err := os.WriteFile(path, []byte("APP_DB_USER=updated\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	out, err = e.Get(ctx, "db/user")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(out) != "updated" {
+		t.Errorf("expected updated content, got %q", out)
+	}
+
+	// Invalid content is reported
+	if err := os.WriteFile(path, []byte("not a dotenv line\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Get(ctx, "db/user"); err == nil {
+		t.Error("expected an error for invalid content")
+	}
+}
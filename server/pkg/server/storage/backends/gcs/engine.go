@@ -41,15 +41,14 @@ import (
 	"io"
 	"net/url"
 
-	"cloud.google.com/go/storage"
-
+	"github.com/elastic/harp-plugins/server/pkg/cloud/gcp/session"
 	cloudstorage "github.com/elastic/harp-plugins/server/pkg/cloud/storage"
 	serverstorage "github.com/elastic/harp-plugins/server/pkg/server/storage"
+	"github.com/elastic/harp-plugins/server/pkg/server/storage/backends/object"
 )
 
 type engine struct {
-	bucketName string
-	prefix     string
+	backend cloudstorage.Backend
 }
 
 func build(u *url.URL) (serverstorage.Engine, error) {
@@ -60,10 +59,32 @@ func build(u *url.URL) (serverstorage.Engine, error) {
 
 	q := u.Query()
 
+	// Select local object storage backend
+	backend, local, err := object.FromURL(u, u.Hostname(), q.Get("prefix"))
+	if err != nil {
+		return nil, err
+	}
+	if !local {
+		// Build client options from url
+		opts, err := session.FromURL(u.String())
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse session URL: %w", err)
+		}
+
+		// Secrets are only read
+		opts.ReadOnly = true
+
+		// Create a Google Storage client
+		client, err := session.NewClient(opts)
+		if err != nil {
+			return nil, fmt.Errorf("gcs: %w", err)
+		}
+		backend = cloudstorage.GCS(client, u.Hostname(), q.Get("prefix"))
+	}
+
 	// Build engine instance
 	return &engine{
-		bucketName: u.Hostname(),
-		prefix:     q.Get("prefix"),
+		backend: backend,
 	}, nil
 }
 
@@ -74,21 +95,46 @@ func init() {
 
 // Get returns the file Reader
 func (d *engine) Get(ctx context.Context, key string) ([]byte, error) {
-	// Create a Google Storage client
-	client, err := storage.NewClient(ctx)
+	secret, err := d.GetWithMetadata(ctx, key)
 	if err != nil {
-		return nil, fmt.Errorf("gcs: unable to initialize storage client: %w", err)
+		return nil, err
 	}
 
-	// Retrieve using S3 storage backend
-	result, err := cloudstorage.GCS(client, d.bucketName, d.prefix).GetObject(ctx, key)
+	return secret.Value, nil
+}
+
+func (d *engine) GetWithMetadata(ctx context.Context, key string) (*serverstorage.Secret, error) {
+	// Check client
+	if d.backend == nil {
+		return nil, fmt.Errorf("gcs: unable proceed with nil backend")
+	}
+
+	// Retrieve using GCS storage backend
+	result, err := d.backend.GetObject(ctx, key)
 	if err != nil {
+		if errors.Is(err, cloudstorage.ErrNotFound) {
+			return nil, serverstorage.ErrSecretNotFound
+		}
 		return nil, fmt.Errorf("cloudstorage error: %w", err)
 	}
 	if result == nil {
 		return nil, errors.New("gcs: nil object returned")
 	}
+	defer result.Content.Close()
+
+	// Drain content
+	content, err := io.ReadAll(result.Content)
+	if err != nil {
+		return nil, fmt.Errorf("gcs: unable to read object content: %w", err)
+	}
 
 	// No error
-	return io.ReadAll(result.Content)
+	return &serverstorage.Secret{
+		Value: content,
+		Metadata: serverstorage.Metadata{
+			Version:     result.VersionID,
+			Created:     result.LastModified,
+			ContentType: result.ContentType,
+		},
+	}, nil
 }
@@ -0,0 +1,70 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package k8s
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// newClient builds a Kubernetes client from URL parameters.
+//
+// An explicit kubeconfig path has precedence, then the in-cluster service
+// account, then the default kubeconfig loading rules (KUBECONFIG, ~/.kube/config).
+func newClient(q url.Values) (kubernetes.Interface, error) {
+	var (
+		kubeconfig  = q.Get("kubeconfig")
+		kubecontext = q.Get("context")
+	)
+
+	// Try in-cluster configuration first when no kubeconfig is given
+	if kubeconfig == "" && kubecontext == "" {
+		cfg, err := rest.InClusterConfig()
+		switch {
+		case err == nil:
+			return kubernetes.NewForConfig(cfg)
+		case errors.Is(err, rest.ErrNotInCluster):
+			// Fallback to kubeconfig
+		default:
+			return nil, fmt.Errorf("k8s: unable to load in-cluster configuration: %w", err)
+		}
+	}
+
+	// Prepare kubeconfig loader
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if kubeconfig != "" {
+		rules.ExplicitPath = kubeconfig
+	}
+	overrides := &clientcmd.ConfigOverrides{
+		CurrentContext: kubecontext,
+	}
+
+	// Load client configuration
+	cfg, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("k8s: unable to load kubeconfig: %w", err)
+	}
+
+	// Build client
+	return kubernetes.NewForConfig(cfg)
+}
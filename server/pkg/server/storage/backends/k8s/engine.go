@@ -0,0 +1,389 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/elastic/harp-plugins/server/pkg/server/storage"
+)
+
+const (
+	kindAll       = "all"
+	kindSecret    = "secret"
+	kindConfigMap = "configmap"
+)
+
+type engine struct {
+	namespace  string
+	kind       string
+	secrets    corelisters.SecretNamespaceLister
+	configMaps corelisters.ConfigMapNamespaceLister
+
+	stop      chan struct{}
+	closeOnce sync.Once
+
+	listenersMu sync.RWMutex
+	listeners   map[uint64]func()
+	nextID      uint64
+}
+
+// Options defines engine settings.
+type Options struct {
+	Namespace     string
+	Kind          string
+	LabelSelector string
+	Resync        time.Duration
+	SyncTimeout   time.Duration
+}
+
+func build(u *url.URL) (storage.Engine, error) {
+	// Check arguments
+	if u == nil {
+		return nil, fmt.Errorf("unable to prepare k8s with nil url")
+	}
+
+	q := u.Query()
+
+	// Parse durations
+	resync, err := durationOrDefault(q.Get("resync"), 10*time.Minute)
+	if err != nil {
+		return nil, fmt.Errorf("k8s: invalid resync value: %w", err)
+	}
+	syncTimeout, err := durationOrDefault(q.Get("sync-timeout"), 30*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("k8s: invalid sync-timeout value: %w", err)
+	}
+
+	// Initialize Kubernetes client
+	client, err := newClient(q)
+	if err != nil {
+		return nil, err
+	}
+
+	// Delegate to engine constructor
+	return New(context.Background(), client, &Options{
+		Namespace:     u.Hostname(),
+		Kind:          q.Get("kind"),
+		LabelSelector: q.Get("label-selector"),
+		Resync:        resync,
+		SyncTimeout:   syncTimeout,
+	})
+}
+
+func init() {
+	// Register to storage factory
+	storage.MustRegister("k8s", build)
+}
+
+// New builds a Kubernetes engine instance using the given client. Resources
+// are watched using an informer cache so that reads never hit the API server.
+// Informers are stopped when the context is done or the engine is closed.
+func New(ctx context.Context, client kubernetes.Interface, opts *Options) (storage.Engine, error) {
+	// Check arguments
+	if client == nil {
+		return nil, fmt.Errorf("k8s: unable to build engine with nil client")
+	}
+	if opts == nil {
+		return nil, fmt.Errorf("k8s: unable to build engine with nil options")
+	}
+	if opts.Namespace == "" {
+		return nil, fmt.Errorf("k8s: namespace is mandatory")
+	}
+
+	// Check resource kind
+	kind := strings.ToLower(opts.Kind)
+	switch kind {
+	case "":
+		kind = kindAll
+	case kindAll, kindSecret, kindConfigMap:
+	default:
+		return nil, fmt.Errorf("k8s: unsupported resource kind '%s'", opts.Kind)
+	}
+
+	// Check label selector syntax
+	if _, err := labels.Parse(opts.LabelSelector); err != nil {
+		return nil, fmt.Errorf("k8s: invalid label selector: %w", err)
+	}
+
+	// Prepare a namespace scoped informer factory
+	factory := informers.NewSharedInformerFactoryWithOptions(client, opts.Resync,
+		informers.WithNamespace(opts.Namespace),
+		informers.WithTweakListOptions(func(lo *metav1.ListOptions) {
+			lo.LabelSelector = opts.LabelSelector
+		}),
+	)
+
+	e := &engine{
+		namespace: opts.Namespace,
+		kind:      kind,
+		stop:      make(chan struct{}),
+		listeners: map[uint64]func(){},
+	}
+
+	// Notify changes to listeners
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc: func(_ interface{}) { e.notify() },
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			// Ignore periodic resync
+			oldMeta, errOld := meta.Accessor(oldObj)
+			newMeta, errNew := meta.Accessor(newObj)
+			if errOld == nil && errNew == nil && oldMeta.GetResourceVersion() == newMeta.GetResourceVersion() {
+				return
+			}
+			e.notify()
+		},
+		DeleteFunc: func(_ interface{}) { e.notify() },
+	}
+
+	// Register required informers
+	synced := []cache.InformerSynced{}
+	if kind == kindAll || kind == kindSecret {
+		informer := factory.Core().V1().Secrets()
+		informer.Informer().AddEventHandler(handler)
+		synced = append(synced, informer.Informer().HasSynced)
+		e.secrets = informer.Lister().Secrets(opts.Namespace)
+	}
+	if kind == kindAll || kind == kindConfigMap {
+		informer := factory.Core().V1().ConfigMaps()
+		informer.Informer().AddEventHandler(handler)
+		synced = append(synced, informer.Informer().HasSynced)
+		e.configMaps = informer.Lister().ConfigMaps(opts.Namespace)
+	}
+
+	// Start informers until engine is closed
+	factory.Start(e.stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			e.Close()
+		case <-e.stop:
+		}
+	}()
+
+	// Wait for initial cache synchronization
+	syncCtx := ctx
+	if opts.SyncTimeout > 0 {
+		var cancel context.CancelFunc
+		syncCtx, cancel = context.WithTimeout(ctx, opts.SyncTimeout)
+		defer cancel()
+	}
+	if !cache.WaitForCacheSync(syncCtx.Done(), synced...) {
+		e.Close()
+		return nil, fmt.Errorf("k8s: unable to synchronize '%s' namespace resource cache", opts.Namespace)
+	}
+
+	// No error
+	return e, nil
+}
+
+// -----------------------------------------------------------------------------
+
+func (e *engine) Get(_ context.Context, id string) ([]byte, error) {
+	// Split identifier as resource name and key
+	name, key := split(id)
+	if name == "" {
+		return nil, fmt.Errorf("k8s: resource name is mandatory")
+	}
+
+	// Resolve resource content
+	data, err := e.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return the whole resource as a JSON object
+	if key == "" {
+		values := map[string]string{}
+		for k, v := range data {
+			values[k] = string(v)
+		}
+
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(values); err != nil {
+			return nil, fmt.Errorf("k8s: unable to encode secret: %w", err)
+		}
+
+		return buf.Bytes(), nil
+	}
+
+	// Extract a single key
+	value, ok := data[key]
+	if !ok {
+		return nil, fmt.Errorf("k8s: key '%s' not found in '%s': %w", key, name, storage.ErrSecretNotFound)
+	}
+
+	// No error
+	return value, nil
+}
+
+func (e *engine) List(_ context.Context, prefix string) ([]string, error) {
+	prefix = strings.TrimPrefix(prefix, "/")
+
+	res := []string{}
+	appendKeys := func(name string, keys []string) {
+		for _, k := range keys {
+			if p := fmt.Sprintf("%s/%s", name, k); strings.HasPrefix(p, prefix) {
+				res = append(res, p)
+			}
+		}
+	}
+
+	// Enumerate secrets
+	if e.secrets != nil {
+		items, err := e.secrets.List(labels.Everything())
+		if err != nil {
+			return nil, fmt.Errorf("k8s: unable to list secrets: %w", err)
+		}
+		for _, s := range items {
+			keys := []string{}
+			for k := range s.Data {
+				keys = append(keys, k)
+			}
+			appendKeys(s.Name, keys)
+		}
+	}
+
+	// Enumerate config maps
+	if e.configMaps != nil {
+		items, err := e.configMaps.List(labels.Everything())
+		if err != nil {
+			return nil, fmt.Errorf("k8s: unable to list configmaps: %w", err)
+		}
+		for _, cm := range items {
+			keys := []string{}
+			for k := range cm.Data {
+				keys = append(keys, k)
+			}
+			for k := range cm.BinaryData {
+				keys = append(keys, k)
+			}
+			appendKeys(cm.Name, keys)
+		}
+	}
+
+	// Sort for stable output
+	sort.Strings(res)
+
+	// No error
+	return res, nil
+}
+
+// Notify registers fn to be called when a watched resource changes.
+func (e *engine) Notify(fn func()) func() {
+	e.listenersMu.Lock()
+	defer e.listenersMu.Unlock()
+
+	id := e.nextID
+	e.nextID++
+	e.listeners[id] = fn
+
+	return func() {
+		e.listenersMu.Lock()
+		defer e.listenersMu.Unlock()
+		delete(e.listeners, id)
+	}
+}
+
+// Close stops resource informers.
+func (e *engine) Close() error {
+	e.closeOnce.Do(func() {
+		close(e.stop)
+	})
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+
+func (e *engine) notify() {
+	e.listenersMu.RLock()
+	defer e.listenersMu.RUnlock()
+
+	for _, fn := range e.listeners {
+		fn()
+	}
+}
+
+func (e *engine) lookup(name string) (map[string][]byte, error) {
+	// Secrets have precedence over config maps
+	if e.secrets != nil {
+		s, err := e.secrets.Get(name)
+		switch {
+		case err == nil:
+			return s.Data, nil
+		case apierrors.IsNotFound(err):
+		default:
+			return nil, fmt.Errorf("k8s: unable to retrieve secret '%s': %w", name, err)
+		}
+	}
+
+	if e.configMaps != nil {
+		cm, err := e.configMaps.Get(name)
+		switch {
+		case err == nil:
+			data := map[string][]byte{}
+			for k, v := range cm.BinaryData {
+				data[k] = v
+			}
+			for k, v := range cm.Data {
+				data[k] = []byte(v)
+			}
+			return data, nil
+		case apierrors.IsNotFound(err):
+		default:
+			return nil, fmt.Errorf("k8s: unable to retrieve configmap '%s': %w", name, err)
+		}
+	}
+
+	return nil, fmt.Errorf("k8s: resource '%s' not found in '%s' namespace: %w", name, e.namespace, storage.ErrSecretNotFound)
+}
+
+func split(id string) (name, key string) {
+	parts := strings.SplitN(strings.Trim(id, "/"), "/", 2)
+	if len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+
+	return parts[0], ""
+}
+
+func durationOrDefault(raw string, defaultValue time.Duration) (time.Duration, error) {
+	if raw == "" {
+		return defaultValue, nil
+	}
+
+	return time.ParseDuration(raw)
+}
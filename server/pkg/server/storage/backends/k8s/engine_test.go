@@ -0,0 +1,203 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package k8s
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/elastic/harp-plugins/server/pkg/server/storage"
+)
+
+func testClient() *fake.Clientset {
+	return fake.NewSimpleClientset(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "apps", Labels: map[string]string{"harp": "true"}},
+			Data:       map[string][]byte{"user": []byte("app"), "password": []byte("secret")},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "private", Namespace: "apps"},
+			Data:       map[string][]byte{"token": []byte("hidden")},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "settings", Namespace: "apps", Labels: map[string]string{"harp": "true"}},
+			Data:       map[string]string{"level": "debug"},
+			BinaryData: map[string][]byte{"blob": {0x01}},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "other"},
+			Data:       map[string][]byte{"user": []byte("other")},
+		},
+	)
+}
+
+func testEngine(t *testing.T, client *fake.Clientset, opts *Options) storage.Engine {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	opts.Namespace = "apps"
+	opts.SyncTimeout = 5 * time.Second
+
+	e, err := New(ctx, client, opts)
+	if err != nil {
+		t.Fatalf("unable to build engine: %v", err)
+	}
+
+	return e
+}
+
+func TestEngine_Get(t *testing.T) {
+	e := testEngine(t, testClient(), &Options{})
+
+	testCases := []struct {
+		id       string
+		expected string
+		notFound bool
+	}{
+		{id: "/db/password", expected: "secret"},
+		{id: "/db", expected: "{\"password\":\"secret\",\"user\":\"app\"}\n"},
+		{id: "/settings/level", expected: "debug"},
+		{id: "/settings/blob", expected: "\x01"},
+		{id: "/db/missing", notFound: true},
+		{id: "/missing/key", notFound: true},
+	}
+	for _, tc := range testCases {
+		value, err := e.Get(context.Background(), tc.id)
+		if tc.notFound {
+			if !errors.Is(err, storage.ErrSecretNotFound) {
+				t.Errorf("%s: expected not found error, got %v", tc.id, err)
+			}
+			continue
+		}
+		if err != nil || string(value) != tc.expected {
+			t.Errorf("%s: Get() = %q, %v", tc.id, value, err)
+		}
+	}
+}
+
+func TestEngine_List(t *testing.T) {
+	e := testEngine(t, testClient(), &Options{})
+
+	ids, err := e.(storage.Lister).List(context.Background(), "/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"db/password", "db/user", "private/token", "settings/blob", "settings/level"}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Fatalf("List() = %v, expected %v", ids, expected)
+	}
+
+	// Restricted kind
+	e = testEngine(t, testClient(), &Options{Kind: kindConfigMap})
+	ids, err = e.(storage.Lister).List(context.Background(), "/settings/l")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(ids, []string{"settings/level"}) {
+		t.Fatalf("unexpected identifiers: %v", ids)
+	}
+}
+
+func TestEngine_LabelSelector(t *testing.T) {
+	e := testEngine(t, testClient(), &Options{LabelSelector: "harp=true"})
+
+	ids, err := e.(storage.Lister).List(context.Background(), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"db/password", "db/user", "settings/blob", "settings/level"}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Fatalf("List() = %v, expected %v", ids, expected)
+	}
+
+	if _, err := e.Get(context.Background(), "/private/token"); !errors.Is(err, storage.ErrSecretNotFound) {
+		t.Fatalf("expected unselected secret to be not found, got %v", err)
+	}
+}
+
+func TestEngine_Notify(t *testing.T) {
+	client := testClient()
+	e := testEngine(t, client, &Options{})
+
+	changed := make(chan struct{}, 10)
+	unregister, ok := storage.Notify(e, func() { changed <- struct{}{} })
+	if !ok {
+		t.Fatal("expected engine to support notifications")
+	}
+	defer unregister()
+
+	// Update secret
+	_, err := client.CoreV1().Secrets("apps").Update(context.Background(), &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "apps", Labels: map[string]string{"harp": "true"}, ResourceVersion: "2"},
+		Data:       map[string][]byte{"user": []byte("app"), "password": []byte("rotated")},
+	}, metav1.UpdateOptions{})
+	if err != nil {
+		t.Fatalf("unable to update secret: %v", err)
+	}
+
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected change notification")
+	}
+
+	// Informer cache is updated
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		value, err := e.Get(context.Background(), "/db/password")
+		if err == nil && string(value) == "rotated" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected updated value, got %q, %v", value, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestEngine_Close(t *testing.T) {
+	e := testEngine(t, testClient(), &Options{})
+
+	closer, ok := e.(io.Closer)
+	if !ok {
+		t.Fatal("expected engine to be closable")
+	}
+	if err := closer.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Close is idempotent
+	if err := closer.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	select {
+	case <-e.(*engine).stop:
+	default:
+		t.Fatal("expected informers to be stopped")
+	}
+}
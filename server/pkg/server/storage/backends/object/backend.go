@@ -0,0 +1,159 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package object selects local object storage backends for cloud storage
+// engines and container loaders, for integration tests and offline usage.
+package object
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	cloudstorage "github.com/elastic/harp-plugins/server/pkg/cloud/storage"
+)
+
+const (
+	paramBackend     = "backend"
+	paramRoot        = "backend-root"
+	paramLatency     = "backend-latency"
+	paramFailureRate = "backend-failure-rate"
+	paramFailureOps  = "backend-failure-ops"
+
+	backendFile   = "file"
+	backendMemory = "memory"
+)
+
+// FromURL returns the local object storage backend selected by the `backend`
+// URL parameter. It returns false when no local backend is selected, so that
+// the cloud backend should be used.
+//
+// Bucket objects are read from the `<backend-root>/<bucket>` directory by the
+// `file` backend, or loaded once from it by the `memory` backend.
+func FromURL(u *url.URL, bucket, prefix string) (cloudstorage.Backend, bool, error) {
+	// Check arguments
+	if u == nil {
+		return nil, false, fmt.Errorf("object: unable to select backend with nil url")
+	}
+
+	q := u.Query()
+
+	// Bucket directory
+	root := ""
+	if r := q.Get(paramRoot); r != "" {
+		root = filepath.Join(r, filepath.FromSlash(bucket))
+	}
+
+	switch q.Get(paramBackend) {
+	case "":
+		return nil, false, nil
+	case backendFile:
+		if root == "" {
+			return nil, false, fmt.Errorf("object: '%s' parameter is mandatory for '%s' backend", paramRoot, backendFile)
+		}
+		return cloudstorage.Filesystem(root, prefix), true, nil
+	case backendMemory:
+		backend, err := memory(q, root, prefix)
+		if err != nil {
+			return nil, false, err
+		}
+		return backend, true, nil
+	default:
+	}
+
+	return nil, false, fmt.Errorf("object: unsupported backend '%s', expected '%s' or '%s'", q.Get(paramBackend), backendFile, backendMemory)
+}
+
+// -----------------------------------------------------------------------------
+
+func memory(q url.Values, root, prefix string) (cloudstorage.Backend, error) {
+	opts := []cloudstorage.MemoryOption{}
+
+	// Injected latency
+	if v := q.Get(paramLatency); v != "" {
+		latency, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("object: invalid latency '%s': %w", v, err)
+		}
+		opts = append(opts, cloudstorage.WithLatency(latency))
+	}
+
+	// Injected failures
+	if v := q.Get(paramFailureRate); v != "" {
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("object: invalid failure rate '%s', expected a value between 0 and 1", v)
+		}
+		ops := []string{}
+		if o := q.Get(paramFailureOps); o != "" {
+			ops = strings.Split(o, ",")
+		}
+		opts = append(opts, cloudstorage.WithFailures(cloudstorage.FailureRate(rate, ops...)))
+	}
+
+	// Seed content from the bucket directory, object paths are relative to prefix
+	if root != "" {
+		objects, err := seed(cloudstorage.Filesystem(root, prefix))
+		if err != nil {
+			return nil, fmt.Errorf("object: unable to seed memory backend from '%s': %w", root, err)
+		}
+		opts = append(opts, objects...)
+	}
+
+	// No error
+	return cloudstorage.Memory(prefix, opts...), nil
+}
+
+// seed reads all objects from source as initial memory backend objects.
+func seed(source cloudstorage.Backend) ([]cloudstorage.MemoryOption, error) {
+	ctx := context.Background()
+
+	res := []cloudstorage.MemoryOption{}
+	opts := &cloudstorage.ListOptions{}
+	for {
+		page, err := source.ListObjects(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range page.Objects {
+			obj, err := source.GetObject(ctx, info.Path)
+			if err != nil {
+				return nil, err
+			}
+			content, err := io.ReadAll(obj.Content)
+			obj.Content.Close()
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, cloudstorage.WithObject(info.Path, content, &cloudstorage.PutOptions{
+				ContentType: obj.ContentType,
+			}))
+		}
+		if page.NextContinuationToken == "" {
+			break
+		}
+		opts.ContinuationToken = page.NextContinuationToken
+	}
+
+	// No error
+	return res, nil
+}
@@ -0,0 +1,180 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package object_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	cloudstorage "github.com/elastic/harp-plugins/server/pkg/cloud/storage"
+	"github.com/elastic/harp-plugins/server/pkg/server/storage"
+	"github.com/elastic/harp-plugins/server/pkg/server/storage/backends/object"
+	_ "github.com/elastic/harp-plugins/server/pkg/server/storage/backends/azblob"
+	_ "github.com/elastic/harp-plugins/server/pkg/server/storage/backends/gcs"
+	_ "github.com/elastic/harp-plugins/server/pkg/server/storage/backends/s3"
+)
+
+func TestEngines_LocalBackend(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "bucket", "secrets", "app"), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "bucket", "secrets", "app", "db"), []byte("value"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, uri := range []string{
+		"s3:///bucket/secrets?prefix=secrets&backend=file&backend-root=" + root,
+		"gcs://bucket?prefix=secrets&backend=file&backend-root=" + root,
+		"azblob://bucket?prefix=secrets&backend=file&backend-root=" + root,
+		"s3:///bucket/secrets?prefix=secrets&backend=memory&backend-root=" + root,
+		"gcs://bucket?prefix=secrets&backend=memory&backend-root=" + root,
+		"azblob://bucket?prefix=secrets&backend=memory&backend-root=" + root,
+	} {
+		engine, err := storage.Build(uri)
+		if err != nil {
+			t.Errorf("%s: unable to build engine: %v", uri, err)
+			continue
+		}
+
+		value, err := engine.Get(context.Background(), "/app/db")
+		if err != nil || string(value) != "value" {
+			t.Errorf("%s: Get() = %q, %v", uri, value, err)
+		}
+		if _, err := engine.Get(context.Background(), "/app/missing"); !errors.Is(err, storage.ErrSecretNotFound) {
+			t.Errorf("%s: expected not found error, got %v", uri, err)
+		}
+	}
+}
+
+func TestEngines_InvalidBackend(t *testing.T) {
+	for _, uri := range []string{
+		"s3:///bucket/secrets?backend=file",
+		"gcs://bucket?backend=unknown",
+	} {
+		if _, err := storage.Build(uri); err == nil {
+			t.Errorf("%s: expected error", uri)
+		}
+	}
+}
+
+func fromURL(t *testing.T, raw string) cloudstorage.Backend {
+	t.Helper()
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, ok, err := object.FromURL(u, "bucket", "secrets")
+	if err != nil || !ok {
+		t.Fatalf("%s: unable to select backend: %v", raw, err)
+	}
+
+	return b
+}
+
+func TestFromURL_MemorySeed(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "bucket", "secrets", "app")
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("key%d", i)), []byte(fmt.Sprintf("value%d", i)), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b := fromURL(t, "s3:///bucket?backend=memory&backend-root="+root)
+
+	// All objects are seeded, paths are relative to prefix
+	page, err := b.ListObjects(context.Background(), &cloudstorage.ListOptions{Prefix: "app/", MaxKeys: 3})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page.Objects) != 3 || page.Objects[0].Path != "app/key0" || page.NextContinuationToken == "" {
+		t.Fatalf("unexpected first page %+v", page)
+	}
+	page, err = b.ListObjects(context.Background(), &cloudstorage.ListOptions{Prefix: "app/", MaxKeys: 3, ContinuationToken: page.NextContinuationToken})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page.Objects) != 2 || page.Objects[1].Path != "app/key4" || page.NextContinuationToken != "" {
+		t.Fatalf("unexpected last page %+v", page)
+	}
+
+	obj, err := b.GetObject(context.Background(), "app/key4")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	content, _ := io.ReadAll(obj.Content)
+	if string(content) != "value4" {
+		t.Errorf("unexpected content %q", content)
+	}
+}
+
+func TestFromURL_MemoryInjection(t *testing.T) {
+	// Injected latency
+	b := fromURL(t, "s3:///bucket?backend=memory&backend-latency=50ms")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if _, err := b.StatObject(ctx, "app/db"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline error, got %v", err)
+	}
+
+	// Injected failures on selected operations
+	b = fromURL(t, "s3:///bucket?backend=memory&backend-failure-rate=1&backend-failure-ops=get,list")
+	if _, err := b.GetObject(context.Background(), "app/db"); !errors.Is(err, cloudstorage.ErrInjectedFailure) {
+		t.Errorf("expected injected failure, got %v", err)
+	}
+	if _, err := b.ListObjects(context.Background(), nil); !errors.Is(err, cloudstorage.ErrInjectedFailure) {
+		t.Errorf("expected injected failure, got %v", err)
+	}
+	if _, err := b.StatObject(context.Background(), "app/db"); !errors.Is(err, cloudstorage.ErrNotFound) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}
+
+func TestFromURL_InvalidParameters(t *testing.T) {
+	for _, raw := range []string{
+		"s3:///bucket?backend=memory&backend-latency=soon",
+		"s3:///bucket?backend=memory&backend-failure-rate=2",
+		"s3:///bucket?backend=memory&backend-failure-rate=-0.5",
+	} {
+		u, err := url.Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := object.FromURL(u, "bucket", "secrets"); err == nil {
+			t.Errorf("%s: expected error", raw)
+		}
+	}
+
+	// Cloud backend is used when no local backend is selected
+	u, _ := url.Parse("s3:///bucket")
+	if b, ok, err := object.FromURL(u, "bucket", "secrets"); err != nil || ok || b != nil {
+		t.Errorf("expected no local backend, got %v %v %v", b, ok, err)
+	}
+}
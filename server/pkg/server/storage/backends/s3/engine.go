@@ -19,6 +19,7 @@ package s3
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
@@ -26,17 +27,20 @@ import (
 	"strings"
 
 	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 
 	"github.com/elastic/harp-plugins/server/pkg/cloud/aws/session"
 	cloudstorage "github.com/elastic/harp-plugins/server/pkg/cloud/storage"
 	"github.com/elastic/harp-plugins/server/pkg/server/storage"
+	"github.com/elastic/harp-plugins/server/pkg/server/storage/backends/object"
 )
 
+// sseCustomerKeySize is the AES256 customer-provided key size.
+const sseCustomerKeySize = 32
+
 type engine struct {
-	s3api      s3iface.S3API
+	backend    cloudstorage.Backend
 	bucketName string
-	basePath   string
+	versionID  string
 }
 
 func build(u *url.URL) (storage.Engine, error) {
@@ -50,19 +54,63 @@ func build(u *url.URL) (storage.Engine, error) {
 	if err != nil {
 		return nil, fmt.Errorf("unable to parse session URL: %w", err)
 	}
-	// Build AWS session
-	sess, err := session.NewSession(opts)
+
+	q := u.Query()
+
+	// Select local object storage backend
+	backend, local, err := object.FromURL(u, opts.BucketName, q.Get("prefix"))
 	if err != nil {
-		return nil, fmt.Errorf("unable to initialize session: %w", err)
+		return nil, err
+	}
+	if !local {
+		// Object storage options
+		options, err := backendOptions(q)
+		if err != nil {
+			return nil, err
+		}
+
+		// Build AWS session
+		sess, err := session.NewSession(opts)
+		if err != nil {
+			return nil, fmt.Errorf("unable to initialize session: %w", err)
+		}
+		backend = cloudstorage.S3(s3.New(sess), opts.BucketName, q.Get("prefix"), options...)
 	}
 
 	// Build engine instance
 	return &engine{
-		s3api:      s3.New(sess),
+		backend:    backend,
 		bucketName: opts.BucketName,
+		versionID:  q.Get("version-id"),
 	}, nil
 }
 
+// backendOptions builds cloud storage options from URL parameters.
+func backendOptions(q url.Values) ([]cloudstorage.S3Option, error) {
+	options := []cloudstorage.S3Option{}
+
+	if v := q.Get("sse-customer-key"); v != "" {
+		// Decode key
+		key, err := base64.StdEncoding.DecodeString(v)
+		if err != nil || len(key) != sseCustomerKeySize {
+			return nil, fmt.Errorf("s3: SSE-C key must be a base64 encoded %d bytes key", sseCustomerKeySize)
+		}
+		options = append(options, cloudstorage.WithSSECustomerKey(key))
+	}
+	if v := q.Get("sse-kms-key-id"); v != "" {
+		if q.Get("sse-customer-key") != "" {
+			return nil, errors.New("s3: SSE-C and SSE-KMS can't be used together")
+		}
+		options = append(options, cloudstorage.WithSSEKMSKeyID(v))
+	}
+	if q.Get("requester-pays") == "true" {
+		options = append(options, cloudstorage.WithRequesterPays())
+	}
+
+	// No error
+	return options, nil
+}
+
 func init() {
 	// Register to storage factory
 	storage.MustRegister("s3", build)
@@ -71,9 +119,18 @@ func init() {
 // -----------------------------------------------------------------------------
 
 func (e *engine) Get(ctx context.Context, key string) ([]byte, error) {
+	secret, err := e.GetWithMetadata(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return secret.Value, nil
+}
+
+func (e *engine) GetWithMetadata(ctx context.Context, key string) (*storage.Secret, error) {
 	// Check fields
-	if e.s3api == nil {
-		return nil, fmt.Errorf("s3 service is nil")
+	if e.backend == nil {
+		return nil, fmt.Errorf("s3 backend is nil")
 	}
 	if e.bucketName == "" {
 		return nil, fmt.Errorf("bucketName is blank")
@@ -83,16 +140,35 @@ func (e *engine) Get(ctx context.Context, key string) ([]byte, error) {
 	key = strings.TrimPrefix(key, fmt.Sprintf("/%s/", e.bucketName))
 
 	// Retrieve using S3 storage backend
-	result, err := cloudstorage.S3(e.s3api, e.bucketName, e.basePath).GetObject(ctx, key)
+	result, err := e.backend.GetObjectWithOptions(ctx, key, &cloudstorage.GetOptions{
+		VersionID: e.versionID,
+	})
 	if err != nil {
+		if errors.Is(err, cloudstorage.ErrNotFound) {
+			return nil, storage.ErrSecretNotFound
+		}
 		return nil, fmt.Errorf("cloudstorage error: %w", err)
 	}
 	if result == nil {
 		return nil, errors.New("s3: nil object returned")
 	}
+	defer result.Content.Close()
+
+	// Drain content
+	content, err := io.ReadAll(result.Content)
+	if err != nil {
+		return nil, fmt.Errorf("s3: unable to read object content: %w", err)
+	}
 
 	// No error
-	return io.ReadAll(result.Content)
+	return &storage.Secret{
+		Value: content,
+		Metadata: storage.Metadata{
+			Version:     result.VersionID,
+			Created:     result.LastModified,
+			ContentType: result.ContentType,
+		},
+	}, nil
 }
 
 // -----------------------------------------------------------------------------
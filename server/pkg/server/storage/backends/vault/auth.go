@@ -0,0 +1,242 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package vault
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/hashicorp/vault/api"
+	"go.uber.org/zap"
+
+	"github.com/elastic/harp/pkg/sdk/log"
+)
+
+// Delay between failed login attempts.
+const loginRetryDelay = 10 * time.Second
+
+// login authenticates the client using the configured method and returns the
+// resulting token secret.
+func login(ctx context.Context, client *api.Client, opts *Options) (*api.Secret, error) {
+	switch opts.Auth {
+	case authToken:
+		return loginToken(client, opts)
+	case authAppRole:
+		return loginWrite(client, opts.AuthMount, map[string]interface{}{
+			"role_id":   opts.RoleID,
+			"secret_id": opts.SecretID,
+		})
+	case authKubernetes:
+		jwt, err := readFile(opts.JWTFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read service account token: %w", err)
+		}
+		return loginWrite(client, opts.AuthMount, map[string]interface{}{
+			"role": opts.Role,
+			"jwt":  jwt,
+		})
+	case authAWS:
+		data, err := awsLoginData(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		return loginWrite(client, opts.AuthMount, data)
+	default:
+	}
+
+	// Environment token is used as-is
+	return nil, nil
+}
+
+// renew keeps the client token alive and logs in again when the token could
+// not be renewed anymore.
+func renew(ctx context.Context, client *api.Client, opts *Options, secret *api.Secret) {
+	for {
+		if secret != nil && secret.Auth != nil && secret.Auth.Renewable {
+			watcher, err := client.NewLifetimeWatcher(&api.LifetimeWatcherInput{
+				Secret: secret,
+			})
+			if err != nil {
+				log.For(ctx).Error("vault: unable to initialize token renewal", zap.Error(err))
+				return
+			}
+
+			go watcher.Start()
+			if !waitWatcher(ctx, watcher) {
+				return
+			}
+		} else if secret != nil && secret.Auth != nil && secret.Auth.LeaseDuration > 0 {
+			// Not renewable, login again before expiration
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Duration(secret.Auth.LeaseDuration) * time.Second * 2 / 3):
+			}
+		} else {
+			// Nothing to renew
+			return
+		}
+
+		// Login again
+		for {
+			var err error
+			secret, err = login(ctx, client, opts)
+			if err == nil {
+				client.SetToken(secret.Auth.ClientToken)
+				break
+			}
+
+			log.For(ctx).Error("vault: unable to login, retrying", zap.Error(err), zap.String("address", client.Address()))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(loginRetryDelay):
+			}
+		}
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+func waitWatcher(ctx context.Context, watcher *api.LifetimeWatcher) bool {
+	defer watcher.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case err := <-watcher.DoneCh():
+			if err != nil {
+				log.For(ctx).Warn("vault: token renewal failed", zap.Error(err))
+			}
+			return true
+		case <-watcher.RenewCh():
+		}
+	}
+}
+
+func loginToken(client *api.Client, opts *Options) (*api.Secret, error) {
+	token, err := readFile(opts.TokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read token file: %w", err)
+	}
+
+	// Lookup token properties
+	client.SetToken(token)
+	self, err := client.Auth().Token().LookupSelf()
+	if err != nil {
+		return nil, fmt.Errorf("unable to lookup token: %w", err)
+	}
+
+	renewable, err := self.TokenIsRenewable()
+	if err != nil {
+		return nil, fmt.Errorf("unable to check token renewability: %w", err)
+	}
+	ttl, err := self.TokenTTL()
+	if err != nil {
+		return nil, fmt.Errorf("unable to check token ttl: %w", err)
+	}
+
+	// No error
+	return &api.Secret{
+		Auth: &api.SecretAuth{
+			ClientToken:   token,
+			Renewable:     renewable,
+			LeaseDuration: int(ttl.Seconds()),
+		},
+	}, nil
+}
+
+func loginWrite(client *api.Client, mount string, data map[string]interface{}) (*api.Secret, error) {
+	secret, err := client.Logical().Write(fmt.Sprintf("auth/%s/login", strings.Trim(mount, "/")), data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to login using '%s' method: %w", mount, err)
+	}
+	if secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "" {
+		return nil, fmt.Errorf("unable to login using '%s' method: no token returned", mount)
+	}
+
+	// No error
+	return secret, nil
+}
+
+func awsLoginData(ctx context.Context, opts *Options) (map[string]interface{}, error) {
+	// Initialize AWS session
+	cfg := &aws.Config{}
+	if opts.AWSRegion != "" {
+		cfg.Region = aws.String(opts.AWSRegion)
+	}
+	sess, err := session.NewSession(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize aws session: %w", err)
+	}
+
+	// Prepare a signed GetCallerIdentity request
+	req, _ := sts.New(sess).GetCallerIdentityRequest(&sts.GetCallerIdentityInput{})
+	req.SetContext(ctx)
+	if opts.AWSHeader != "" {
+		req.HTTPRequest.Header.Add("X-Vault-AWS-IAM-Server-ID", opts.AWSHeader)
+	}
+	if err := req.Sign(); err != nil {
+		return nil, fmt.Errorf("unable to sign aws identity request: %w", err)
+	}
+
+	// Serialize request
+	headers, err := json.Marshal(req.HTTPRequest.Header)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode aws identity request headers: %w", err)
+	}
+	body, err := io.ReadAll(req.HTTPRequest.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read aws identity request body: %w", err)
+	}
+
+	// No error
+	return map[string]interface{}{
+		"role":                    opts.Role,
+		"iam_http_request_method": req.HTTPRequest.Method,
+		"iam_request_url":         base64.StdEncoding.EncodeToString([]byte(req.HTTPRequest.URL.String())),
+		"iam_request_headers":     base64.StdEncoding.EncodeToString(headers),
+		"iam_request_body":        base64.StdEncoding.EncodeToString(body),
+	}, nil
+}
+
+func readFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		// Don't expose full error chain
+		var pathErr *os.PathError
+		if errors.As(err, &pathErr) {
+			err = pathErr.Err
+		}
+		return "", err
+	}
+
+	// No error
+	return strings.TrimSpace(string(content)), nil
+}
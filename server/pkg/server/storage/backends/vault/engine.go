@@ -21,43 +21,51 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/vault/api"
 
+	"github.com/elastic/harp-plugins/server/pkg/server/format"
 	"github.com/elastic/harp-plugins/server/pkg/server/storage"
+	"github.com/elastic/harp/pkg/sdk/log"
 	"github.com/elastic/harp/pkg/vault/kv"
 )
 
+// Secret identifier version suffix (<path>@<version>)
+var versionSuffix = regexp.MustCompile(`@([0-9]+)$`)
+
 type engine struct {
-	u        *url.URL
-	basePath string
+	opts *Options
 
 	client  *api.Client
 	service kv.SecretReader
+
+	// Token renewal lifetime
+	cancel context.CancelFunc
+	done   chan struct{}
 }
 
 func build(u *url.URL) (storage.Engine, error) {
-	// Initialize Vault connection
-	client, err := api.NewClient(api.DefaultConfig())
-	if err != nil {
-		return nil, fmt.Errorf("vault: unable to initialize connection: %w", err)
+	// Check arguments
+	if u == nil {
+		return nil, fmt.Errorf("unable to prepare vault with nil url")
 	}
 
-	// Retrieve a secret reader
-	reader, err := kv.New(client, u.Path)
+	// Parse settings
+	opts, err := parseOptions(u)
 	if err != nil {
-		return nil, fmt.Errorf("unable to initialize kv service : %w", err)
+		return nil, fmt.Errorf("vault: %w", err)
 	}
 
-	// Build engine instance
-	return &engine{
-		u:        u,
-		basePath: u.Path,
-		client:   client,
-		service:  reader,
-	}, nil
+	// Delegate to engine constructor
+	return New(context.Background(), opts)
 }
 
 func init() {
@@ -65,16 +73,111 @@ func init() {
 	storage.MustRegister("vault", build)
 }
 
+// New returns a Vault engine instance. Token renewal is stopped when the given
+// context is cancelled or when the engine is closed.
+func New(ctx context.Context, opts *Options) (storage.Engine, error) {
+	// Check arguments
+	if opts == nil {
+		return nil, fmt.Errorf("vault: options must not be nil")
+	}
+
+	// Initialize Vault connection
+	client, err := newClient(opts)
+	if err != nil {
+		return nil, fmt.Errorf("vault: unable to initialize connection: %w", err)
+	}
+
+	// Engine owns the token renewal lifetime
+	ctx, cancel := context.WithCancel(ctx)
+	e := &engine{
+		opts:   opts,
+		client: client,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	// Authenticate
+	secret, err := login(ctx, client, opts)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("vault: %w", err)
+	}
+	if secret != nil {
+		client.SetToken(secret.Auth.ClientToken)
+		go func() {
+			defer close(e.done)
+			renew(ctx, client, opts, secret)
+		}()
+	} else {
+		close(e.done)
+	}
+
+	// Retrieve a secret reader
+	e.service, err = kv.New(client, opts.BasePath, kv.WithVaultMetatadata(opts.CustomMetadata))
+	if err != nil {
+		// Stop token renewal
+		log.CheckErr("Unable to close vault engine", e.Close())
+		return nil, fmt.Errorf("vault: unable to initialize kv service : %w", err)
+	}
+
+	// No error
+	return e, nil
+}
+
 // -----------------------------------------------------------------------------
 
+// Close stops the token renewal.
+func (e *engine) Close() error {
+	e.cancel()
+	<-e.done
+
+	// No error
+	return nil
+}
+
 func (e *engine) Get(ctx context.Context, id string) ([]byte, error) {
+	secret, err := e.GetWithMetadata(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return secret.Value, nil
+}
+
+func (e *engine) GetWithMetadata(ctx context.Context, id string) (*storage.Secret, error) {
+	// Extract field and version from identifier (<path>[@<version>][#<field>])
+	secretPath, version, field, err := e.parseID(id)
+	if err != nil {
+		return nil, fmt.Errorf("vault: %w", err)
+	}
+
 	// Read from Vault
-	secretData, _, err := e.service.Read(ctx, id)
+	secretData, secretMeta, err := e.service.ReadVersion(ctx, secretPath, version)
+	if errors.Is(err, kv.ErrPathNotFound) || errors.Is(err, kv.ErrNoData) {
+		return nil, storage.ErrSecretNotFound
+	}
 	if err != nil {
 		return nil, fmt.Errorf("vault: unable to read secret from vault server: %w", err)
 	}
 	if secretData == nil {
-		return []byte{}, storage.ErrSecretNotFound
+		return nil, storage.ErrSecretNotFound
+	}
+
+	// Convert metadata
+	meta := metadata(secretData, secretMeta)
+
+	// Extract field value
+	if field != "" {
+		value, ok := format.Field(secretData, field)
+		if !ok {
+			return nil, storage.ErrSecretNotFound
+		}
+
+		meta.ContentType = "text/plain; charset=utf-8"
+		return &storage.Secret{
+			Value:    []byte(format.Scalar(value)),
+			Metadata: meta,
+		}, nil
 	}
 
 	// Encode secret as json
@@ -84,5 +187,115 @@ func (e *engine) Get(ctx context.Context, id string) ([]byte, error) {
 	}
 
 	// Return secret
-	return buf.Bytes(), nil
+	meta.ContentType = "application/json"
+	return &storage.Secret{
+		Value:    buf.Bytes(),
+		Metadata: meta,
+	}, nil
+}
+
+// -----------------------------------------------------------------------------
+
+func (e *engine) parseID(id string) (secretPath string, version uint32, field string, err error) {
+	// Default values
+	version, field = e.opts.Version, e.opts.Field
+
+	// Extract field
+	if idx := strings.LastIndex(id, "#"); idx >= 0 {
+		id, field = id[:idx], id[idx+1:]
+	}
+
+	// Extract version
+	if m := versionSuffix.FindStringSubmatch(id); m != nil {
+		v, errParse := strconv.ParseUint(m[1], 10, 32)
+		if errParse != nil {
+			return "", 0, "", fmt.Errorf("invalid secret version")
+		}
+		id, version = strings.TrimSuffix(id, m[0]), uint32(v)
+	}
+
+	// Secret path is relative to base path
+	secretPath = path.Join(e.opts.BasePath, strings.TrimPrefix(id, "/"))
+
+	// No error
+	return secretPath, version, field, nil
+}
+
+// metadata extracts secret metadata from KV response. Custom metadata stored in
+// secret data, when Vault custom metadata are not used, are removed from data.
+func metadata(data kv.SecretData, meta kv.SecretMetadata) storage.Metadata {
+	var res storage.Metadata
+
+	// Version and creation time (KV v2 only)
+	if v, ok := meta["version"]; ok && v != nil {
+		res.Version = fmt.Sprintf("%v", v)
+	}
+	if raw, ok := meta["created_time"].(string); ok {
+		if t, err := time.Parse(time.RFC3339Nano, raw); err == nil {
+			res.Created = t
+		}
+	}
+
+	// Custom metadata
+	custom := map[string]interface{}{}
+	if m, ok := meta["custom_metadata"].(map[string]interface{}); ok {
+		for k, v := range m {
+			custom[k] = v
+		}
+	}
+	if m, ok := data[kv.VaultMetadataDataKey].(map[string]interface{}); ok {
+		for k, v := range m {
+			custom[k] = v
+		}
+		delete(data, kv.VaultMetadataDataKey)
+	}
+	if len(custom) > 0 {
+		res.CustomMetadata = make(map[string]string, len(custom))
+		for k, v := range custom {
+			res.CustomMetadata[k] = fmt.Sprintf("%v", v)
+		}
+	}
+
+	return res
+}
+
+func newClient(opts *Options) (*api.Client, error) {
+	// Use environment settings as defaults
+	cfg := api.DefaultConfig()
+	if cfg.Error != nil {
+		return nil, cfg.Error
+	}
+	if opts.Address != "" {
+		cfg.Address = opts.Address
+	}
+
+	// Apply TLS settings
+	if opts.CACert != "" || opts.ClientCert != "" || opts.ClientKey != "" || opts.ServerName != "" || opts.Insecure {
+		if err := cfg.ConfigureTLS(&api.TLSConfig{
+			CACert:        opts.CACert,
+			ClientCert:    opts.ClientCert,
+			ClientKey:     opts.ClientKey,
+			TLSServerName: opts.ServerName,
+			Insecure:      opts.Insecure,
+		}); err != nil {
+			return nil, fmt.Errorf("unable to configure tls: %w", err)
+		}
+	}
+
+	// Create client
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Don't use environment token with explicit authentication method
+	if opts.Auth != authEnv {
+		client.ClearToken()
+	}
+	if opts.Namespace != "" {
+		client.SetNamespace(opts.Namespace)
+	}
+
+	// No error
+	return client, nil
 }
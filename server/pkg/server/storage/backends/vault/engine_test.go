@@ -0,0 +1,116 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package vault
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeVault serves the token lookup, renewal and mount discovery endpoints.
+func fakeVault(t *testing.T, mounts http.HandlerFunc) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/auth/token/lookup-self", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data":{"id":"test-token","renewable":true,"ttl":3600}}`)
+	})
+	mux.HandleFunc("/v1/auth/token/renew-self", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"auth":{"client_token":"test-token","renewable":true,"lease_duration":3600}}`)
+	})
+	mux.HandleFunc("/v1/sys/internal/ui/mounts/", mounts)
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func tokenOptions(t *testing.T, address string) *Options {
+	t.Helper()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("test-token"), 0o600); err != nil {
+		t.Fatalf("unable to write token file: %v", err)
+	}
+
+	return &Options{
+		Address:   address,
+		Auth:      authToken,
+		TokenFile: tokenFile,
+		BasePath:  "secret/app",
+	}
+}
+
+func TestEngine_Close(t *testing.T) {
+	srv := fakeVault(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data":{"path":"secret/","type":"kv","options":{"version":"2"}}}`)
+	})
+
+	e, err := New(context.Background(), tokenOptions(t, srv.URL))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	closer, ok := e.(io.Closer)
+	if !ok {
+		t.Fatal("expected engine to be closable")
+	}
+
+	// Token renewal is stopped
+	closed := make(chan error, 1)
+	go func() {
+		closed <- closer.Close()
+	}()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("token renewal not stopped on close")
+	}
+}
+
+func TestNew_StopsRenewalOnError(t *testing.T) {
+	srv := fakeVault(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := New(context.Background(), tokenOptions(t, srv.URL))
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "kv service") {
+			t.Fatalf("expected kv service error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("token renewal not stopped on error")
+	}
+}
@@ -0,0 +1,144 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package vault
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	authEnv        = ""
+	authToken      = "token"
+	authAppRole    = "approle"
+	authKubernetes = "kubernetes"
+	authAWS        = "aws"
+
+	defaultKubernetesJWTPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+)
+
+// Options defines engine settings.
+type Options struct {
+	// Connection settings
+	Address    string
+	Namespace  string
+	CACert     string
+	ClientCert string
+	ClientKey  string
+	ServerName string
+	Insecure   bool
+
+	// Authentication settings
+	Auth      string
+	AuthMount string
+	TokenFile string
+	RoleID    string
+	SecretID  string
+	Role      string
+	JWTFile   string
+	AWSRegion string
+	AWSHeader string
+
+	// Read settings
+	BasePath       string
+	Version        uint32
+	Field          string
+	CustomMetadata bool
+}
+
+func parseOptions(u *url.URL) (*Options, error) {
+	q := u.Query()
+
+	opts := &Options{
+		Address:    q.Get("address"),
+		Namespace:  q.Get("namespace"),
+		CACert:     q.Get("ca-cert"),
+		ClientCert: q.Get("client-cert"),
+		ClientKey:  q.Get("client-key"),
+		ServerName: q.Get("server-name"),
+		Insecure:   q.Get("insecure") == "true",
+		Auth:       q.Get("auth"),
+		AuthMount:  q.Get("auth-mount"),
+		TokenFile:  q.Get("token-file"),
+		RoleID:     q.Get("role-id"),
+		SecretID:   q.Get("secret-id"),
+		Role:       q.Get("role"),
+		JWTFile:    q.Get("jwt-file"),
+		AWSRegion:  q.Get("aws-region"),
+		AWSHeader:  q.Get("aws-header"),
+		BasePath:   strings.Trim(u.Host+u.Path, "/"),
+		Field:      q.Get("field"),
+
+		CustomMetadata: q.Get("custom-metadata") == "true",
+	}
+
+	// The URL host is the first base path segment (vault://<path>), the
+	// server address is only given by the address parameter.
+	if opts.Address != "" && !strings.Contains(opts.Address, "://") {
+		return nil, fmt.Errorf("address '%s' must be an URL (https://<host:port>)", opts.Address)
+	}
+
+	// Version pinning
+	if raw := q.Get("version"); raw != "" {
+		v, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version value")
+		}
+		opts.Version = uint32(v)
+	}
+
+	// Validate authentication settings
+	switch opts.Auth {
+	case authEnv:
+	case authToken:
+		if opts.TokenFile == "" {
+			return nil, fmt.Errorf("token-file is mandatory for token authentication")
+		}
+	case authAppRole:
+		if opts.RoleID == "" {
+			return nil, fmt.Errorf("role-id is mandatory for approle authentication")
+		}
+		opts.AuthMount = valueOrDefault(opts.AuthMount, "approle")
+	case authKubernetes:
+		if opts.Role == "" {
+			return nil, fmt.Errorf("role is mandatory for kubernetes authentication")
+		}
+		opts.JWTFile = valueOrDefault(opts.JWTFile, defaultKubernetesJWTPath)
+		opts.AuthMount = valueOrDefault(opts.AuthMount, "kubernetes")
+	case authAWS:
+		if opts.Role == "" {
+			return nil, fmt.Errorf("role is mandatory for aws authentication")
+		}
+		opts.AuthMount = valueOrDefault(opts.AuthMount, "aws")
+	default:
+		return nil, fmt.Errorf("unsupported authentication method '%s'", opts.Auth)
+	}
+
+	// No error
+	return opts, nil
+}
+
+func valueOrDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+
+	return value
+}
@@ -0,0 +1,114 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package path
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/elastic/harp-plugins/server/pkg/server/storage"
+)
+
+// Mapper returns a path mapping decorator.
+func Mapper(m *Mapping) func(storage.Engine) storage.Engine {
+	// Return decorator constructor
+	return func(engine storage.Engine) storage.Engine {
+		d := &mapperDecorator{
+			next:    engine,
+			mapping: m,
+		}
+
+		// Forward listing when backend paths could be mapped back
+		if l, ok := engine.(storage.Lister); ok && m.Reversible() {
+			return &listerDecorator{
+				mapperDecorator: d,
+				lister:          l,
+			}
+		}
+
+		return d
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+type mapperDecorator struct {
+	next    storage.Engine
+	mapping *Mapping
+}
+
+func (d *mapperDecorator) Get(ctx context.Context, id string) ([]byte, error) {
+	// Check path access
+	if !d.mapping.Allowed(id) {
+		return nil, fmt.Errorf("path '%s' is not allowed: %w", id, storage.ErrSecretNotFound)
+	}
+
+	// Delegate to original storage engine
+	return d.next.Get(ctx, d.mapping.Apply(id))
+}
+
+func (d *mapperDecorator) GetWithMetadata(ctx context.Context, id string) (*storage.Secret, error) {
+	// Check path access
+	if !d.mapping.Allowed(id) {
+		return nil, fmt.Errorf("path '%s' is not allowed: %w", id, storage.ErrSecretNotFound)
+	}
+
+	// Delegate to original storage engine
+	return storage.GetWithMetadata(ctx, d.next, d.mapping.Apply(id))
+}
+
+// Unwrap returns the decorated engine.
+func (d *mapperDecorator) Unwrap() storage.Engine {
+	return d.next
+}
+
+// -----------------------------------------------------------------------------
+
+type listerDecorator struct {
+	*mapperDecorator
+	lister storage.Lister
+}
+
+func (d *listerDecorator) List(ctx context.Context, prefix string) ([]string, error) {
+	// Restrict backend listing to the mapped tree
+	backendPrefix := ""
+	if d.mapping.AddPrefix != "" {
+		backendPrefix = d.mapping.AddPrefix + "/"
+	}
+
+	// Delegate to original storage engine
+	ids, err := d.lister.List(ctx, backendPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix = strings.TrimPrefix(prefix, "/")
+	res := []string{}
+	for _, id := range ids {
+		// Map backend path to client path
+		p, ok := d.mapping.Revert(id)
+		if !ok || !strings.HasPrefix(p, prefix) || !d.mapping.Allowed(p) {
+			continue
+		}
+		res = append(res, p)
+	}
+
+	// No error
+	return res, nil
+}
@@ -0,0 +1,264 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package path
+
+import (
+	"fmt"
+	"net/url"
+	pathutil "path"
+	"regexp"
+	"strings"
+
+	"github.com/gobwas/glob"
+)
+
+const (
+	paramStrip    = "path_strip"
+	paramPrefix   = "path_prefix"
+	paramRewrite  = "path_rewrite"
+	paramTemplate = "path_template"
+	paramAllow    = "path_allow"
+	paramDeny     = "path_deny"
+	paramVar      = "path_var_"
+
+	rewriteSeparator = "=>"
+)
+
+var placeholderRegex = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_]+)\s*\}\}`)
+
+// Rule describes a regexp based path rewrite rule.
+type Rule struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// Mapping describes the path transformation pipeline applied before
+// delegating to the wrapped engine.
+//
+// Allow / Deny globs are evaluated against the client path, then the prefix
+// is stripped, rewrite rules, template and prefix are applied in order.
+type Mapping struct {
+	StripPrefix string
+	AddPrefix   string
+	Rules       []Rule
+	Template    string
+	Vars        map[string]string
+	Allow       []glob.Glob
+	Deny        []glob.Glob
+}
+
+// FromQuery builds a mapping from backend URL query parameters. It returns
+// nil when no path mapping parameter is defined.
+func FromQuery(q url.Values) (*Mapping, error) {
+	m := &Mapping{
+		StripPrefix: clean(q.Get(paramStrip)),
+		AddPrefix:   clean(q.Get(paramPrefix)),
+		Template:    q.Get(paramTemplate),
+		Vars:        map[string]string{},
+	}
+
+	// Parse rewrite rules
+	for _, raw := range q[paramRewrite] {
+		parts := strings.SplitN(raw, rewriteSeparator, 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("path: invalid rewrite rule '%s', expected '<regexp>%s<replacement>'", raw, rewriteSeparator)
+		}
+
+		pattern, err := regexp.Compile(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("path: invalid rewrite rule pattern '%s': %w", parts[0], err)
+		}
+
+		m.Rules = append(m.Rules, Rule{
+			Pattern:     pattern,
+			Replacement: parts[1],
+		})
+	}
+
+	// Parse allow / deny globs
+	var err error
+	if m.Allow, err = compileGlobs(q[paramAllow]); err != nil {
+		return nil, err
+	}
+	if m.Deny, err = compileGlobs(q[paramDeny]); err != nil {
+		return nil, err
+	}
+
+	// Extract template variables
+	for k, v := range q {
+		if strings.HasPrefix(k, paramVar) && len(v) > 0 {
+			m.Vars[strings.TrimPrefix(k, paramVar)] = v[0]
+		}
+	}
+
+	// Check template placeholders
+	for _, match := range placeholderRegex.FindAllStringSubmatch(m.Template, -1) {
+		if _, ok := m.Vars[match[1]]; !ok && match[1] != "path" {
+			return nil, fmt.Errorf("path: template variable '%s' is not defined, use '%s%s' parameter", match[1], paramVar, match[1])
+		}
+	}
+
+	// Nothing to apply
+	if m.StripPrefix == "" && m.AddPrefix == "" && m.Template == "" && len(m.Rules) == 0 && len(m.Allow) == 0 && len(m.Deny) == 0 {
+		return nil, nil
+	}
+
+	// No error
+	return m, nil
+}
+
+// Allowed returns true if the given client path is accessible.
+func (m *Mapping) Allowed(p string) bool {
+	// Reject parent directory references
+	if hasDotDot(p) {
+		return false
+	}
+	p = clean(p)
+
+	for _, g := range m.Deny {
+		if g.Match(p) {
+			return false
+		}
+	}
+
+	if len(m.Allow) == 0 {
+		return true
+	}
+	for _, g := range m.Allow {
+		if g.Match(p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Apply returns the backend path from the given client path.
+func (m *Mapping) Apply(p string) string {
+	// Preserve leading slash usage of the caller
+	rooted := strings.HasPrefix(p, "/")
+	p = clean(p)
+
+	// Strip prefix
+	if m.StripPrefix != "" {
+		p = stripPrefix(p, m.StripPrefix)
+	}
+
+	// Apply rewrite rules
+	for _, r := range m.Rules {
+		p = r.Pattern.ReplaceAllString(p, r.Replacement)
+	}
+
+	// Apply template
+	if m.Template != "" {
+		p = placeholderRegex.ReplaceAllStringFunc(m.Template, func(s string) string {
+			name := placeholderRegex.FindStringSubmatch(s)[1]
+			if name == "path" {
+				return p
+			}
+			return m.Vars[name]
+		})
+	}
+
+	// Add prefix
+	if m.AddPrefix != "" {
+		p = pathutil.Join(m.AddPrefix, p)
+	}
+
+	p = clean(p)
+	if rooted {
+		return "/" + p
+	}
+
+	return p
+}
+
+// Reversible returns true if backend paths could be mapped back to client
+// paths, rewrite rules and templates are not reversible.
+func (m *Mapping) Reversible() bool {
+	return len(m.Rules) == 0 && m.Template == ""
+}
+
+// Revert returns the client path from the given backend path. It returns false
+// if the backend path is not reachable through this mapping.
+func (m *Mapping) Revert(p string) (string, bool) {
+	p = clean(p)
+
+	// Remove added prefix
+	if m.AddPrefix != "" {
+		if p != m.AddPrefix && !strings.HasPrefix(p, m.AddPrefix+"/") {
+			return "", false
+		}
+		p = stripPrefix(p, m.AddPrefix)
+	}
+
+	// Restore stripped prefix
+	if m.StripPrefix != "" {
+		p = clean(pathutil.Join(m.StripPrefix, p))
+	}
+
+	return p, true
+}
+
+// -----------------------------------------------------------------------------
+
+func clean(p string) string {
+	return strings.Trim(pathutil.Clean("/"+p), "/")
+}
+
+func hasDotDot(p string) bool {
+	for _, part := range strings.Split(p, "/") {
+		if part == ".." {
+			return true
+		}
+	}
+
+	return false
+}
+
+// stripPrefix removes the prefix from the cleaned path only on a segment
+// boundary.
+func stripPrefix(p, prefix string) string {
+	switch {
+	case p == prefix:
+		return ""
+	case strings.HasPrefix(p, prefix+"/"):
+		return strings.TrimPrefix(p, prefix+"/")
+	default:
+		return p
+	}
+}
+
+func compileGlobs(patterns []string) ([]glob.Glob, error) {
+	res := []glob.Glob{}
+	for _, raw := range patterns {
+		pattern := clean(raw)
+		g, err := glob.Compile(pattern, '/')
+		if err != nil {
+			return nil, fmt.Errorf("path: invalid glob '%s': %w", raw, err)
+		}
+		res = append(res, g)
+
+		// A leading '**/' also matches root level paths
+		if strings.HasPrefix(pattern, "**/") {
+			res = append(res, glob.MustCompile(strings.TrimPrefix(pattern, "**/"), '/'))
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,125 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package path
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/elastic/harp-plugins/server/pkg/server/storage"
+)
+
+func mustMapping(t *testing.T, raw string) *Mapping {
+	t.Helper()
+
+	q, err := url.ParseQuery(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := FromQuery(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return m
+}
+
+func TestMapping_Allowed(t *testing.T) {
+	m := mustMapping(t, "path_allow=public/**&path_deny=**/private/**")
+
+	for p, want := range map[string]bool{
+		"public/app/key":         true,
+		"/public/app/key":        true,
+		"public/../private/key":  false,
+		"public/app/../../x":     false,
+		"public/private/key":     false,
+		"private/key":            false,
+		"other/key":              false,
+		"public//app/./key":      true,
+		"public/app/private/key": false,
+	} {
+		if got := m.Allowed(p); got != want {
+			t.Errorf("Allowed(%q) = %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestMapping_Apply(t *testing.T) {
+	m := mustMapping(t, "path_strip=app&path_prefix=secrets")
+
+	for p, want := range map[string]string{
+		"app/db":        "secrets/db",
+		"/app/db":       "/secrets/db",
+		"app":           "secrets",
+		"application/x": "secrets/application/x",
+	} {
+		if got := m.Apply(p); got != want {
+			t.Errorf("Apply(%q) = %q, want %q", p, got, want)
+		}
+	}
+}
+
+type listEngine map[string][]byte
+
+func (e listEngine) Get(_ context.Context, id string) ([]byte, error) {
+	v, ok := e[id]
+	if !ok {
+		return nil, storage.ErrSecretNotFound
+	}
+	return v, nil
+}
+
+func (e listEngine) List(_ context.Context, _ string) ([]string, error) {
+	return []string{"secrets/db", "secrets/private/key", "other/db"}, nil
+}
+
+func TestMapper_List(t *testing.T) {
+	engine := Mapper(mustMapping(t, "path_strip=app&path_prefix=secrets&path_deny=**/private/**"))(listEngine{
+		"secrets/db": []byte("value"),
+	})
+
+	l, ok := engine.(storage.Lister)
+	if !ok {
+		t.Fatal("decorated engine should implement storage.Lister")
+	}
+	ids, err := l.List(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"app/db"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("List() = %v, want %v", ids, want)
+	}
+
+	// Listed identifiers are readable
+	if _, err := engine.Get(context.Background(), ids[0]); err != nil {
+		t.Errorf("Get(%q) failed: %v", ids[0], err)
+	}
+	if _, err := engine.Get(context.Background(), "app/../private/key"); !errors.Is(err, storage.ErrSecretNotFound) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}
+
+func TestMapper_ListNotReversible(t *testing.T) {
+	engine := Mapper(mustMapping(t, "path_template={{path}}.json"))(listEngine{})
+	if _, ok := engine.(storage.Lister); ok {
+		t.Error("template mapping should not expose listing")
+	}
+}
@@ -29,11 +29,21 @@ import (
 func Transformer(transformer value.Transformer, revert bool) func(storage.Engine) storage.Engine {
 	// Return decorator constructor
 	return func(engine storage.Engine) storage.Engine {
-		return &transformerDecorator{
+		d := &transformerDecorator{
 			next:        engine,
 			transformer: transformer,
 			revert:      revert,
 		}
+
+		// Identifiers are not transformed, forward listing
+		if l, ok := engine.(storage.Lister); ok {
+			return &listerDecorator{
+				transformerDecorator: d,
+				lister:               l,
+			}
+		}
+
+		return d
 	}
 }
 
@@ -52,6 +62,48 @@ func (d *transformerDecorator) Get(ctx context.Context, id string) ([]byte, erro
 		return nil, fmt.Errorf("unable to retrieve secret '%s': %w", id, err)
 	}
 
+	return d.transform(ctx, secret)
+}
+
+func (d *transformerDecorator) GetWithMetadata(ctx context.Context, id string) (*storage.Secret, error) {
+	// Delegate to original storage engine
+	secret, err := storage.GetWithMetadata(ctx, d.next, id)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve secret '%s': %w", id, err)
+	}
+
+	// Transform value
+	out, err := d.transform(ctx, secret.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	// Content type is not preserved by transformation
+	secret.Value = out
+	secret.Metadata.ContentType = ""
+
+	// No error
+	return secret, nil
+}
+
+// Unwrap returns the decorated engine.
+func (d *transformerDecorator) Unwrap() storage.Engine {
+	return d.next
+}
+
+type listerDecorator struct {
+	*transformerDecorator
+	lister storage.Lister
+}
+
+func (d *listerDecorator) List(ctx context.Context, prefix string) ([]string, error) {
+	// Delegate to original storage engine
+	return d.lister.List(ctx, prefix)
+}
+
+// -----------------------------------------------------------------------------
+
+func (d *transformerDecorator) transform(ctx context.Context, secret []byte) ([]byte, error) {
 	if d.revert {
 		// Apply reverse fonction
 		return d.transformer.From(ctx, secret)
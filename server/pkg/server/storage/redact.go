@@ -0,0 +1,84 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package storage
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// Query parameters holding secret values.
+var sensitiveParams = []string{"cid", "unlock", "key", "password", "secret", "token", "sig", "connection-string"}
+
+// RedactURL returns the given backend URL with secret values masked so that
+// it can be logged or included in error messages.
+func RedactURL(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		// Don't leak unparsable URLs
+		return "<invalid url>"
+	}
+
+	// Mask user password
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "redacted")
+	}
+
+	// Mask sensitive parameters
+	q := u.Query()
+	for k, values := range q {
+		if isSensitive(k) {
+			q.Set(k, "redacted")
+			continue
+		}
+
+		// Nested backend URLs could contain secret values too
+		for i, v := range values {
+			if strings.Contains(v, "://") {
+				values[i] = RedactURL(v)
+			}
+		}
+	}
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
+
+// -----------------------------------------------------------------------------
+
+func isSensitive(param string) bool {
+	param = strings.ToLower(param)
+	for _, s := range sensitiveParams {
+		if param == s || strings.Contains(param, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// urlError removes the URL from url.Parse errors.
+func urlError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+
+	return err
+}
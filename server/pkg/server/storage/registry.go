@@ -57,12 +57,18 @@ func MustRegister(name string, factory EngineFactoryFunc) {
 	}
 }
 
+// IsRegistered returns true if a storage engine is registered for the scheme.
+func IsRegistered(scheme string) bool {
+	_, ok := engines[scheme]
+	return ok
+}
+
 // Build an engine instance with given URL.
 func Build(uri string) (Engine, error) {
 	// Parse URL
 	u, err := url.Parse(uri)
 	if err != nil {
-		return nil, fmt.Errorf("engine: unable to instantiate a storage engine: %w", err)
+		return nil, fmt.Errorf("engine: unable to instantiate a storage engine: %w", urlError(err))
 	}
 
 	// Retrieve a factory accorting to url scheme